@@ -3,19 +3,28 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/niltonkummer/fizzbuzz-api/config"
+	httpIn "github.com/niltonkummer/fizzbuzz-api/internal/adapters/inbound/http"
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/logging"
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/metrics"
 	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/repository"
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/tracing"
 	"github.com/niltonkummer/fizzbuzz-api/internal/application"
 	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
 	"github.com/niltonkummer/fizzbuzz-api/internal/application/services/fizzbuzz"
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/services/stats"
 	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
 )
 
@@ -29,34 +38,281 @@ func init() {
 	conf = config.LoadConfig(os.Getenv("CONFIG_PATH"))
 }
 
-func Setup(mainCtx context.Context) {
+// redisUsers returns the configured adapters that require Redis
+func redisUsers(conf config.Config) []string {
+	var users []string
+	if repository.StorageType(conf.StorageType) == repository.StorageTypeRedis {
+		users = append(users, "statistics repository (STORAGE_TYPE=redis)")
+	}
+	if conf.UseFizzbuzzCache && repository.StorageType(conf.FizzbuzzCacheType) != repository.StorageTypeInMemory {
+		users = append(users, "fizzbuzz cache (FIZZBUZZ_CACHE_TYPE=redis)")
+	}
+	if conf.RateLimitEnabled && repository.StorageType(conf.RateLimitType) == repository.StorageTypeRedis {
+		users = append(users, "rate limiter (RATE_LIMIT_TYPE=redis)")
+	}
+	if repository.StorageType(conf.APIKeysStore) == repository.StorageTypeRedis {
+		users = append(users, "API key store (API_KEYS_STORE=redis)")
+	}
+	return users
+}
+
+// connectRedis connects to Redis when a configured adapter requires it, nil otherwise
+func connectRedis(ctx context.Context, conf config.Config) (*redis.Client, error) {
+	users := redisUsers(conf)
+	if len(users) == 0 {
+		return nil, nil
+	}
 
 	client := redis.NewClient(&redis.Options{
 		Addr:     conf.RedisAddress,
 		Password: conf.RedisPassword,
 	})
-	err := client.Ping(client.Context()).Err()
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis at %q required by the %s: %w",
+			conf.RedisAddress, strings.Join(users, " and the "), err)
+	}
+	return client, nil
+}
+
+// commaList returns the items of a comma separated list
+func commaList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// trustedProxies returns the IP ranges of a comma separated list of CIDRs
+func trustedProxies(list string) ([]*net.IPNet, error) {
+	var ranges []*net.IPNet
+	for _, cidr := range commaList(list) {
+		_, ipRange, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy range %q: %w", cidr, err)
+		}
+		ranges = append(ranges, ipRange)
+	}
+	return ranges, nil
+}
+
+// statsRecorders creates the recorders writing the hits of the stats repositories asynchronously,
+// the global one and the ones of the API keys, and shuts them down together
+type statsRecorders struct {
+	opts []repository.RecorderOption
+
+	mu        sync.Mutex
+	recorders []*repository.StatsRecorder
+}
+
+// add returns a new recorder writing to repo
+func (r *statsRecorders) add(repo adapters.StatsRepository) *repository.StatsRecorder {
+	recorder := repository.NewStatsRecorder(repo, r.opts...)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorders = append(r.recorders, recorder)
+	return recorder
+}
+
+// shutdown shuts the recorders down, waiting for their queued hits to be written
+func (r *statsRecorders) shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for _, recorder := range r.recorders {
+		errs = append(errs, recorder.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func Setup(mainCtx context.Context) {
+
+	shutdownTracing, err := tracing.Setup(mainCtx, tracing.Exporter(conf.TracingExporter),
+		tracing.WithOTLPEndpoint(conf.TracingOTLPEndpoint),
+		tracing.WithFile(conf.TracingFile),
+		tracing.WithSampleRatio(conf.TracingSampleRatio))
 	if err != nil {
-		panic("Failed to connect to Redis: " + err.Error())
+		panic("Failed to setup tracing: " + err.Error())
+	}
+
+	client, err := connectRedis(mainCtx, conf)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	// The Redis adapters are protected by circuit breakers, reported on the health endpoint,
+	// and the dependencies in use are checked by the readiness probe
+	var healthReporters []adapters.HealthReporter
+	var readinessCheckers []adapters.ReadinessChecker
+	breakerOpts := []repository.BreakerOption{
+		repository.WithBreakerFailures(conf.RedisBreakerFailures),
+		repository.WithBreakerOpenTimeout(conf.RedisBreakerOpenTimeout),
+		repository.WithStatsBufferSize(conf.StatsBufferSize),
+	}
+
+	proxies, err := trustedProxies(conf.TrustedProxies)
+	if err != nil {
+		panic(err.Error())
+	}
+	routerOpts := []httpIn.Option{
+		httpIn.WithAdminToken(conf.AdminToken),
+		httpIn.WithTrustedProxies(proxies...),
+		httpIn.WithCORS(commaList(conf.CORSAllowedOrigins)...),
+		httpIn.WithBodyLimit(conf.MaxBodyBytes),
+		httpIn.WithRequestTimeout(conf.RequestTimeout),
+		httpIn.WithServerTimeouts(conf.ServerReadTimeout, conf.ServerWriteTimeout, conf.ServerIdleTimeout),
+	}
+	if conf.SecureHeaders {
+		routerOpts = append(routerOpts, httpIn.WithSecureHeaders(conf.HSTSMaxAge))
+	}
+	serviceOpts := []fizzbuzz.Option{
+		fizzbuzz.WithBatchWorkers(conf.FizzbuzzBatchWorkers),
+		fizzbuzz.WithBatchBudget(conf.FizzbuzzBatchMaxItems, conf.FizzbuzzBatchMaxWork),
+	}
+	instrument := func(repo adapters.StatsRepository) adapters.StatsRepository { return repo }
+	if conf.MetricsEnabled {
+		promMetrics := metrics.NewPrometheus()
+		if client != nil {
+			promMetrics.RegisterRedisPool(client)
+		}
+		routerOpts = append(routerOpts, httpIn.WithMetrics(promMetrics))
+		serviceOpts = append(serviceOpts, fizzbuzz.WithMetrics(promMetrics))
+		instrument = func(repo adapters.StatsRepository) adapters.StatsRepository {
+			return repository.NewInstrumentedStatsRepository(repo, promMetrics)
+		}
+	}
+
+	if conf.RateLimitEnabled {
+		rateLimitOpts := []repository.RateLimitOption{
+			repository.WithRateLimitCapacity(conf.RateLimitCapacity),
+			repository.WithRateLimitRate(conf.RateLimitRate),
+		}
+		var limiter adapters.RateLimiter = repository.NewInMemoryRateLimiter(rateLimitOpts...)
+		if repository.StorageType(conf.RateLimitType) == repository.StorageTypeRedis {
+			redisLimiter := repository.NewRedisRateLimiter(client,
+				append(rateLimitOpts, repository.WithRateLimitTimeout(conf.RedisTimeout))...)
+			readinessCheckers = append(readinessCheckers, redisLimiter)
+			limiter = redisLimiter
+		}
+		routerOpts = append(routerOpts,
+			httpIn.WithRateLimit(limiter),
+			httpIn.WithRateLimitCostUnit(conf.RateLimitCostUnit),
+			httpIn.WithRateLimitBatchMaxWork(conf.FizzbuzzBatchMaxWork),
+			httpIn.WithRateLimitKey(httpIn.RateLimitKey(conf.RateLimitKey)))
+	}
+
+	recorders := &statsRecorders{opts: []repository.RecorderOption{
+		repository.WithRecorderQueueSize(conf.StatsQueueSize),
+		repository.WithRecorderBatchSize(conf.StatsBatchSize),
+		repository.WithRecorderFlushInterval(conf.StatsFlushInterval),
+	}}
+
+	// The requests authenticated with an API key are counted in the statistics of the key as well
+	var statsOpts []stats.Option
+	switch repository.StorageType(conf.APIKeysStore) {
+	case repository.StorageTypeFile, repository.StorageTypeRedis:
+		var store adapters.APIKeyStore
+		if repository.StorageType(conf.APIKeysStore) == repository.StorageTypeFile {
+			fileStore, err := repository.NewFileAPIKeyStore(conf.APIKeysFile)
+			if err != nil {
+				panic("Failed to load the API keys: " + err.Error())
+			}
+			store = fileStore
+		} else {
+			redisStore := repository.NewRedisAPIKeyStore(client, conf.RedisTimeout)
+			readinessCheckers = append(readinessCheckers, redisStore)
+			store = redisStore
+		}
+
+		var keyStatsOpts []repository.APIKeyStatsOption
+		if repository.StorageType(conf.StorageType) == repository.StorageTypeRedis {
+			keyStatsOpts = append(keyStatsOpts, repository.WithAPIKeyStatsReset(func(ctx context.Context) error {
+				return repository.ResetRedisStatsNamespaces(ctx, client, repository.RedisKeyStatsAPIKeyPrefix)
+			}), repository.WithAPIKeyStatsRemove(func(ctx context.Context, request model.FizzBuzzRequest) error {
+				return repository.RemoveRedisStatsNamespacesRequest(ctx, client, repository.RedisKeyStatsAPIKeyPrefix, request)
+			}))
+		}
+		keyStats := repository.NewAPIKeyStats(func(key string) adapters.StatsRepository {
+			var repo adapters.StatsRepository
+			if repository.StorageType(conf.StorageType) == repository.StorageTypeRedis {
+				repo = repository.NewStatsBreaker(instrument(repository.NewRedisStatsRepository(client,
+					repository.WithNamespace(repository.RedisKeyStatsAPIKeyPrefix+key),
+					repository.WithRetention(conf.StatsRetention),
+					repository.WithTimeout(conf.RedisTimeout))), breakerOpts...)
+			} else {
+				repo = instrument(repository.NewInMemoryStatsRepository(repository.WithRetention(conf.StatsRetention)))
+			}
+			if conf.StatsAsync {
+				return recorders.add(repo)
+			}
+			return repo
+		}, keyStatsOpts...)
+		routerOpts = append(routerOpts, httpIn.WithAPIKeys(store))
+		serviceOpts = append(serviceOpts, fizzbuzz.WithAPIKeyStats(keyStats))
+		statsOpts = append(statsOpts, stats.WithAPIKeyStats(keyStats))
+	case "", "none":
+	default:
+		panic("Unknown API key store " + conf.APIKeysStore)
 	}
 
 	ongoingCtx, stopGracefully := context.WithCancel(context.Background())
 	statsRepo := repository.GetStatsRepository(func() adapters.StatsRepository {
 		if repository.StorageType(conf.StorageType) == repository.StorageTypeRedis {
-			return repository.NewRedisStatsRepository(client)
+			redisRepo := repository.NewRedisStatsRepository(client,
+				repository.WithRetention(conf.StatsRetention),
+				repository.WithTimeout(conf.RedisTimeout))
+			if err := redisRepo.MigrateStatsMembers(mainCtx); err != nil {
+				panic("Failed to migrate Redis statistics: " + err.Error())
+			}
+			statsBreaker := repository.NewStatsBreaker(instrument(redisRepo), breakerOpts...)
+			healthReporters = append(healthReporters, statsBreaker)
+			readinessCheckers = append(readinessCheckers, redisRepo)
+			return statsBreaker
 		}
-		return repository.NewInMemoryStatsRepository(make(map[model.FizzBuzzRequest]int))
+		memoryRepo := repository.NewInMemoryStatsRepository(repository.WithRetention(conf.StatsRetention))
+		readinessCheckers = append(readinessCheckers, memoryRepo)
+		return instrument(memoryRepo)
 	})
 
-	router := application.InitServices(ongoingCtx, statsRepo,
-		fizzbuzz.WithCache(func() adapters.CacheFizzbuzz {
-			if conf.UseFizzbuzzCache {
-				return repository.NewCacheRedis(client)
-			}
+	var recorder *repository.StatsRecorder
+	if conf.StatsAsync {
+		recorder = recorders.add(statsRepo)
+		healthReporters = append(healthReporters, recorder)
+		statsRepo = recorder
+	}
+
+	cache := func() adapters.CacheFizzbuzz {
+		if !conf.UseFizzbuzzCache {
 			return repository.NewCacheFizzbuzzNoOp()
-		}()))
+		}
+		if repository.StorageType(conf.FizzbuzzCacheType) == repository.StorageTypeInMemory {
+			return repository.NewCacheMemory(conf.FizzbuzzCacheMaxBytes)
+		}
+		redisCache := repository.NewCacheRedis(client,
+			repository.WithCacheTTL(conf.FizzbuzzCacheTTL),
+			repository.WithCacheTimeout(conf.RedisTimeout),
+			repository.WithCacheKeyPrefix(conf.FizzbuzzCacheKeyPrefix),
+			repository.WithCacheMaxValueSize(conf.FizzbuzzCacheMaxValueSize))
+		cacheBreaker := repository.NewCacheBreaker(redisCache, breakerOpts...)
+		healthReporters = append(healthReporters, cacheBreaker)
+		readinessCheckers = append(readinessCheckers, redisCache)
+		return cacheBreaker
+	}()
+
+	router := application.InitServices(ongoingCtx, statsRepo, routerOpts,
+		[]httpIn.HandlerOption{
+			httpIn.WithHealthReporters(healthReporters...),
+			httpIn.WithReadinessCheckers(readinessCheckers...),
+			httpIn.WithCacheMaxAge(conf.HTTPCacheMaxAge),
+		},
+		statsOpts,
+		append(serviceOpts, fizzbuzz.WithCache(cache))...)
 
 	go func() {
+		log.InfoContext(mainCtx, "HTTP server listening", "address", conf.HTTPServerHost)
 		if err := router.Start(conf.HTTPServerHost); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			panic("Failed to start HTTP server: " + err.Error())
 		}
@@ -65,6 +321,11 @@ func Setup(mainCtx context.Context) {
 	// Wait for shutdown signal
 	<-mainCtx.Done()
 
+	// The readiness probe fails first, so the load balancers drain the instance before the server stops
+	log.InfoContext(mainCtx, "Draining server...", "delay", conf.ShutdownDrainDelay)
+	router.GetHandler().Drain()
+	time.Sleep(conf.ShutdownDrainDelay)
+
 	// Shutdown gracefully
 	log.InfoContext(mainCtx, "Shutting down server...")
 
@@ -75,10 +336,29 @@ func Setup(mainCtx context.Context) {
 	}
 	stopGracefully()
 
-	err = client.Close()
-	if err != nil {
-		log.ErrorContext(mainCtx, "Failed to close Redis client", "error", err)
-		return
+	if recorder != nil {
+		if err := recorders.shutdown(stopTimeCtx); err != nil {
+			log.ErrorContext(mainCtx, "Failed to write the queued statistics", "error", err)
+		}
+		stats := recorder.Stats()
+		log.InfoContext(mainCtx, "Statistics recorder", "flushed", stats.Flushed, "dropped", stats.Dropped)
+	}
+
+	if memoryCache, ok := cache.(*repository.CacheMemory); ok {
+		stats := memoryCache.Stats()
+		log.InfoContext(mainCtx, "Cache statistics", "hits", stats.Hits, "misses", stats.Misses,
+			"evictions", stats.Evictions, "entries", stats.Entries, "bytes", stats.Bytes)
+	}
+
+	if err := shutdownTracing(stopTimeCtx); err != nil {
+		log.ErrorContext(mainCtx, "Failed to export the pending spans", "error", err)
+	}
+
+	if client != nil {
+		if err := client.Close(); err != nil {
+			log.ErrorContext(mainCtx, "Failed to close Redis client", "error", err)
+			return
+		}
 	}
 
 	log.InfoContext(mainCtx, "Server shutdown complete")
@@ -89,5 +369,13 @@ func main() {
 	mainCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	// The logs are written as JSON, with the request ID of the records logged while serving a request
+	level, err := logging.ParseLevel(conf.LogLevel)
+	if err != nil {
+		panic("Invalid LOG_LEVEL: " + err.Error())
+	}
+	slog.SetDefault(logging.New(os.Stdout, level))
+	log = slog.Default()
+
 	Setup(mainCtx)
 }
@@ -3,24 +3,121 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	HTTPServerHost   string `mapstructure:"HTTP_SERVER_HOST"`
-	RedisAddress     string `mapstructure:"REDIS_ADDRESS"`
-	RedisPassword    string `mapstructure:"REDIS_PASSWORD"`
-	StorageType      string `mapstructure:"STORAGE_TYPE"`
-	UseFizzbuzzCache bool   `mapstructure:"USE_FIZZBUZZ_CACHE"`
+	HTTPServerHost            string        `mapstructure:"HTTP_SERVER_HOST"`
+	RedisAddress              string        `mapstructure:"REDIS_ADDRESS"`
+	RedisPassword             string        `mapstructure:"REDIS_PASSWORD"`
+	RedisTimeout              time.Duration `mapstructure:"REDIS_TIMEOUT"`
+	StorageType               string        `mapstructure:"STORAGE_TYPE"`
+	UseFizzbuzzCache          bool          `mapstructure:"USE_FIZZBUZZ_CACHE"`
+	FizzbuzzCacheType         string        `mapstructure:"FIZZBUZZ_CACHE_TYPE"`
+	FizzbuzzCacheMaxBytes     int64         `mapstructure:"FIZZBUZZ_CACHE_MAX_BYTES"`
+	FizzbuzzCacheTTL          time.Duration `mapstructure:"FIZZBUZZ_CACHE_TTL"`
+	FizzbuzzCacheKeyPrefix    string        `mapstructure:"FIZZBUZZ_CACHE_KEY_PREFIX"`
+	FizzbuzzCacheMaxValueSize int           `mapstructure:"FIZZBUZZ_CACHE_MAX_VALUE_SIZE"`
+	AdminToken                string        `mapstructure:"ADMIN_TOKEN"`
+	StatsRetention            time.Duration `mapstructure:"STATS_RETENTION"`
+	RedisBreakerFailures      uint32        `mapstructure:"REDIS_BREAKER_FAILURES"`
+	RedisBreakerOpenTimeout   time.Duration `mapstructure:"REDIS_BREAKER_OPEN_TIMEOUT"`
+	StatsBufferSize           int           `mapstructure:"STATS_BUFFER_SIZE"`
+	StatsAsync                bool          `mapstructure:"STATS_ASYNC"`
+	StatsQueueSize            int           `mapstructure:"STATS_QUEUE_SIZE"`
+	StatsBatchSize            int           `mapstructure:"STATS_BATCH_SIZE"`
+	StatsFlushInterval        time.Duration `mapstructure:"STATS_FLUSH_INTERVAL"`
+	MetricsEnabled            bool          `mapstructure:"METRICS_ENABLED"`
+	TracingExporter           string        `mapstructure:"TRACING_EXPORTER"`
+	TracingOTLPEndpoint       string        `mapstructure:"TRACING_OTLP_ENDPOINT"`
+	TracingFile               string        `mapstructure:"TRACING_FILE"`
+	TracingSampleRatio        float64       `mapstructure:"TRACING_SAMPLE_RATIO"`
+	ShutdownDrainDelay        time.Duration `mapstructure:"SHUTDOWN_DRAIN_DELAY"`
+	RateLimitEnabled          bool          `mapstructure:"RATE_LIMIT_ENABLED"`
+	RateLimitType             string        `mapstructure:"RATE_LIMIT_TYPE"`
+	RateLimitCapacity         int           `mapstructure:"RATE_LIMIT_CAPACITY"`
+	RateLimitRate             float64       `mapstructure:"RATE_LIMIT_RATE"`
+	RateLimitCostUnit         int           `mapstructure:"RATE_LIMIT_COST_UNIT"`
+	RateLimitKey              string        `mapstructure:"RATE_LIMIT_KEY"`
+	TrustedProxies            string        `mapstructure:"TRUSTED_PROXIES"`
+	APIKeysStore              string        `mapstructure:"API_KEYS_STORE"`
+	APIKeysFile               string        `mapstructure:"API_KEYS_FILE"`
+	CORSAllowedOrigins        string        `mapstructure:"CORS_ALLOWED_ORIGINS"`
+	SecureHeaders             bool          `mapstructure:"SECURE_HEADERS"`
+	HSTSMaxAge                int           `mapstructure:"HSTS_MAX_AGE"`
+	MaxBodyBytes              int64         `mapstructure:"MAX_BODY_BYTES"`
+	RequestTimeout            time.Duration `mapstructure:"REQUEST_TIMEOUT"`
+	ServerReadTimeout         time.Duration `mapstructure:"SERVER_READ_TIMEOUT"`
+	ServerWriteTimeout        time.Duration `mapstructure:"SERVER_WRITE_TIMEOUT"`
+	ServerIdleTimeout         time.Duration `mapstructure:"SERVER_IDLE_TIMEOUT"`
+	LogLevel                  string        `mapstructure:"LOG_LEVEL"`
+	HTTPCacheMaxAge           time.Duration `mapstructure:"HTTP_CACHE_MAX_AGE"`
+	FizzbuzzBatchWorkers      int           `mapstructure:"FIZZBUZZ_BATCH_WORKERS"`
+	FizzbuzzBatchMaxItems     int           `mapstructure:"FIZZBUZZ_BATCH_MAX_ITEMS"`
+	FizzbuzzBatchMaxWork      int           `mapstructure:"FIZZBUZZ_BATCH_MAX_WORK"`
+}
+
+// defaults registers the settings that may be omitted from the config file,
+// so they can still be set from the environment
+var defaults = map[string]any{
+	"REDIS_TIMEOUT":                 "1s",
+	"STORAGE_TYPE":                  "in-memory",
+	"USE_FIZZBUZZ_CACHE":            false,
+	"FIZZBUZZ_CACHE_TYPE":           "redis",
+	"FIZZBUZZ_CACHE_MAX_BYTES":      64 << 20,
+	"FIZZBUZZ_CACHE_TTL":            "24h",
+	"FIZZBUZZ_CACHE_KEY_PREFIX":     "fizzbuzz:cache:",
+	"FIZZBUZZ_CACHE_MAX_VALUE_SIZE": 0,
+	"ADMIN_TOKEN":                   "",
+	"STATS_RETENTION":               "168h",
+	"REDIS_BREAKER_FAILURES":        5,
+	"REDIS_BREAKER_OPEN_TIMEOUT":    "30s",
+	"STATS_BUFFER_SIZE":             10000,
+	"STATS_ASYNC":                   false,
+	"STATS_QUEUE_SIZE":              10000,
+	"STATS_BATCH_SIZE":              500,
+	"STATS_FLUSH_INTERVAL":          "1s",
+	"METRICS_ENABLED":               false,
+	"TRACING_EXPORTER":              "none",
+	"TRACING_OTLP_ENDPOINT":         "",
+	"TRACING_FILE":                  "",
+	"TRACING_SAMPLE_RATIO":          1.0,
+	"SHUTDOWN_DRAIN_DELAY":          "5s",
+	"RATE_LIMIT_ENABLED":            false,
+	"RATE_LIMIT_TYPE":               "in-memory",
+	"RATE_LIMIT_CAPACITY":           1000,
+	"RATE_LIMIT_RATE":               100,
+	"RATE_LIMIT_COST_UNIT":          1000,
+	"RATE_LIMIT_KEY":                "ip",
+	"TRUSTED_PROXIES":               "",
+	"API_KEYS_STORE":                "none",
+	"API_KEYS_FILE":                 "",
+	"CORS_ALLOWED_ORIGINS":          "",
+	"SECURE_HEADERS":                true,
+	"HSTS_MAX_AGE":                  0,
+	"MAX_BODY_BYTES":                1 << 20,
+	"REQUEST_TIMEOUT":               "30s",
+	"SERVER_READ_TIMEOUT":           "10s",
+	"SERVER_WRITE_TIMEOUT":          "60s",
+	"SERVER_IDLE_TIMEOUT":           "120s",
+	"LOG_LEVEL":                     "info",
+	"HTTP_CACHE_MAX_AGE":            "24h",
+	"FIZZBUZZ_BATCH_WORKERS":        8,
+	"FIZZBUZZ_BATCH_MAX_ITEMS":      1000,
+	"FIZZBUZZ_BATCH_MAX_WORK":       1000000,
 }
 
 func LoadConfig(path string) Config {
-	
+
 	var config Config
 	viper.AddConfigPath(path)
 	viper.SetConfigName(fmt.Sprintf("server.%s.env", os.Getenv("ENV")))
 	viper.SetConfigType("env")
+	for key, value := range defaults {
+		viper.SetDefault(key, value)
+	}
 	viper.AutomaticEnv()
 	err := viper.ReadInConfig()
 	if err != nil {
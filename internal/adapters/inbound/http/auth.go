@@ -0,0 +1,74 @@
+package http
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+const bearerPrefix = "Bearer "
+
+// apiKeyAuth returns the middleware authenticating the requests with an API key granted scope.
+// The API key is added to the request context, for the statistics and the rate limits of its client.
+func (r *Router) apiKeyAuth(scope model.Scope) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			credential := apiKeyCredential(ctx.Request())
+			if credential == "" {
+				return model.ErrInvalidAPIKey
+			}
+			return r.authenticate(ctx, credential, scope, next)
+		}
+	}
+}
+
+// adminAuth returns the middleware authenticating the admin routes with the admin bearer token,
+// or with an API key granted the admin scope
+func (r *Router) adminAuth() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			credential := apiKeyCredential(ctx.Request())
+			if r.adminToken != "" && credential != "" &&
+				subtle.ConstantTimeCompare([]byte(credential), []byte(r.adminToken)) == 1 {
+				return next(ctx)
+			}
+			if r.apiKeys != nil && credential != "" {
+				return r.authenticate(ctx, credential, model.ScopeAdmin, next)
+			}
+			return model.ErrInvalidAPIKey.WithMessage("Invalid or missing admin token")
+		}
+	}
+}
+
+// authenticate looks up the API key sent by the client and calls next with the key in the request context,
+// when the key is granted scope
+func (r *Router) authenticate(ctx echo.Context, credential string, scope model.Scope, next echo.HandlerFunc) error {
+	req := ctx.Request()
+	apiKey, err := r.apiKeys.GetAPIKey(req.Context(), credential)
+	if err != nil {
+		slog.WarnContext(req.Context(), "API key store unavailable", "error", err)
+		return model.ErrAuthUnavailable
+	}
+	if apiKey == nil {
+		return model.ErrInvalidAPIKey
+	}
+	if !apiKey.HasScope(scope) {
+		return model.ErrForbidden.WithMessage("The API key lacks the " + string(scope) + " scope")
+	}
+
+	ctx.SetRequest(req.WithContext(model.ContextWithAPIKey(req.Context(), *apiKey)))
+	return next(ctx)
+}
+
+// apiKeyCredential returns the key sent by the client, as a bearer token or in the X-API-Key header
+func apiKeyCredential(req *http.Request) string {
+	if auth := req.Header.Get(echo.HeaderAuthorization); len(auth) > len(bearerPrefix) &&
+		strings.EqualFold(auth[:len(bearerPrefix)], bearerPrefix) {
+		return auth[len(bearerPrefix):]
+	}
+	return req.Header.Get(HeaderAPIKey)
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+// MIMEApplicationProblemJSON is the media type of the error responses
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// problemStatuses maps the codes of the error catalog to the status of their responses, the other codes
+// are answered with a 500
+var problemStatuses = map[string]int{
+	model.ErrInvalidPayload.Code:   http.StatusBadRequest,
+	model.ErrInvalidRequest.Code:   http.StatusBadRequest,
+	model.ErrInvalidFormat.Code:    http.StatusBadRequest,
+	model.ErrInvalidAPIKey.Code:    http.StatusUnauthorized,
+	model.ErrForbidden.Code:        http.StatusForbidden,
+	model.ErrNoRequestsFound.Code:  http.StatusNotFound,
+	model.ErrRequestNotFound.Code:  http.StatusNotFound,
+	model.ErrRouteNotFound.Code:    http.StatusNotFound,
+	model.ErrMethodNotAllowed.Code: http.StatusMethodNotAllowed,
+	model.ErrPayloadTooLarge.Code:  http.StatusRequestEntityTooLarge,
+	model.ErrBatchTooLarge.Code:    http.StatusRequestEntityTooLarge,
+	model.ErrRateLimited.Code:      http.StatusTooManyRequests,
+	model.ErrInternal.Code:         http.StatusInternalServerError,
+	model.ErrStatsUnavailable.Code: http.StatusServiceUnavailable,
+	model.ErrCacheUnavailable.Code: http.StatusServiceUnavailable,
+	model.ErrAuthUnavailable.Code:  http.StatusServiceUnavailable,
+	model.ErrRequestTimeout.Code:   http.StatusServiceUnavailable,
+}
+
+// handleError is the error handler of the router, answering the errors returned by the handlers and the
+// middleware with an application/problem+json body
+func handleError(err error, ctx echo.Context) {
+	if ctx.Response().Committed {
+		return
+	}
+
+	apiErr, status := apiError(err)
+	ctx.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+	var writeErr error
+	if ctx.Request().Method == http.MethodHead {
+		writeErr = ctx.NoContent(status)
+	} else {
+		writeErr = ctx.JSON(status, model.NewProblem(apiErr, status, ctx.Request().URL.Path))
+	}
+	if writeErr != nil {
+		slog.WarnContext(ctx.Request().Context(), "Failed to write the error response", "error", writeErr)
+	}
+}
+
+// apiError returns the catalog error of err and the status of its response
+func apiError(err error) (*model.Error, int) {
+	var (
+		apiErr      *model.Error
+		httpErr     *echo.HTTPError
+		maxBytesErr *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.As(err, &maxBytesErr):
+		apiErr = model.ErrPayloadTooLarge
+	case errors.Is(err, context.DeadlineExceeded):
+		apiErr = model.ErrRequestTimeout
+	case errors.As(err, &httpErr):
+		return echoError(httpErr)
+	default:
+		apiErr = model.ErrInternal
+	}
+
+	status, found := problemStatuses[apiErr.Code]
+	if !found {
+		status = http.StatusInternalServerError
+	}
+	return apiErr, status
+}
+
+// echoError returns the catalog error of an error of Echo or of its middleware, which keeps its status
+func echoError(err *echo.HTTPError) (*model.Error, int) {
+	switch err.Code {
+	case http.StatusNotFound:
+		return model.ErrRouteNotFound, err.Code
+	case http.StatusMethodNotAllowed:
+		return model.ErrMethodNotAllowed, err.Code
+	case http.StatusRequestEntityTooLarge:
+		return model.ErrPayloadTooLarge, err.Code
+	}
+
+	message, ok := err.Message.(string)
+	if !ok {
+		message = http.StatusText(err.Code)
+	}
+	if err.Code >= http.StatusInternalServerError {
+		return model.ErrInternal.WithMessage(message), err.Code
+	}
+	return model.ErrInvalidRequest.WithMessage(message), err.Code
+}
+
+// invalidPayload returns the error of a request whose parameters cannot be bound
+func invalidPayload(err error) error {
+	var (
+		maxBytesErr *http.MaxBytesError
+		httpErr     *echo.HTTPError
+	)
+	if errors.As(err, &maxBytesErr) {
+		return model.ErrPayloadTooLarge
+	}
+	// The errors of the binder carry their status and cause, only the message is meant for the client
+	if errors.As(err, &httpErr) {
+		if message, ok := httpErr.Message.(string); ok {
+			return model.ErrInvalidPayload.WithMessage(message)
+		}
+	}
+	return model.ErrInvalidPayload.WithMessage(err.Error())
+}
+
+// invalidRequest returns the error of a request whose parameters are invalid, with the failed validations
+// of the fields when the validator reports them
+func invalidRequest(err error) error {
+	var apiErr *model.Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return model.ErrInvalidRequest.WithMessage(err.Error())
+}
+
+// internalError returns the internal error detailed by message and err
+func internalError(message string, err error) error {
+	return model.ErrInternal.WithMessage(message + ": " + err.Error())
+}
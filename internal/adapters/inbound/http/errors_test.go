@@ -0,0 +1,175 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRouter_ErrorHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		wantStatusCode int
+		wantProblem    *model.Problem
+	}{
+		{
+			name:           "invalid parameters",
+			method:         http.MethodPost,
+			path:           "/fizzbuzz",
+			body:           `{"int1":0,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantProblem: &model.Problem{
+				Type:     "urn:fizzbuzz-api:problem:invalid_request",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "int1 must be greater than 1",
+				Instance: "/fizzbuzz",
+				Code:     "invalid_request",
+				Message:  "int1 must be greater than 1",
+				Errors: []model.FieldError{
+					{Field: "int1", Rule: "min", Param: "1", Message: "int1 must be greater than 1"},
+				},
+			},
+		},
+		{
+			name:           "malformed body",
+			method:         http.MethodPost,
+			path:           "/fizzbuzz",
+			body:           `{bad`,
+			wantStatusCode: http.StatusBadRequest,
+			wantProblem: &model.Problem{
+				Type:     "urn:fizzbuzz-api:problem:invalid_payload",
+				Title:    "Invalid payload",
+				Status:   http.StatusBadRequest,
+				Detail:   "Syntax error: offset=2, error=invalid character 'b' looking for beginning of object key string",
+				Instance: "/fizzbuzz",
+				Code:     "invalid_payload",
+				Message:  "Syntax error: offset=2, error=invalid character 'b' looking for beginning of object key string",
+			},
+		},
+		{
+			name:           "unknown route",
+			method:         http.MethodGet,
+			path:           "/unknown",
+			wantStatusCode: http.StatusNotFound,
+			wantProblem: &model.Problem{
+				Type:     "urn:fizzbuzz-api:problem:not_found",
+				Title:    "Not found",
+				Status:   http.StatusNotFound,
+				Detail:   "No route matches the request path",
+				Instance: "/unknown",
+				Code:     "not_found",
+				Message:  "No route matches the request path",
+			},
+		},
+		{
+			name:           "method not allowed",
+			method:         http.MethodPut,
+			path:           "/fizzbuzz",
+			wantStatusCode: http.StatusMethodNotAllowed,
+			wantProblem: &model.Problem{
+				Type:     "urn:fizzbuzz-api:problem:method_not_allowed",
+				Title:    "Method not allowed",
+				Status:   http.StatusMethodNotAllowed,
+				Detail:   "The route does not allow the request method",
+				Instance: "/fizzbuzz",
+				Code:     "method_not_allowed",
+				Message:  "The route does not allow the request method",
+			},
+		},
+		{
+			name:           "HEAD of an unknown route",
+			method:         http.MethodHead,
+			path:           "/unknown",
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "error outside the catalog",
+			method:         http.MethodGet,
+			path:           "/failure",
+			wantStatusCode: http.StatusInternalServerError,
+			wantProblem: &model.Problem{
+				Type:     "urn:fizzbuzz-api:problem:internal_error",
+				Title:    "Internal error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "Internal server error",
+				Instance: "/failure",
+				Code:     "internal_error",
+				Message:  "Internal server error",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewRouter(context.Background())
+			router.RegisterRoutes(NewHandler(adapters.NewMockFizzBuzzService(ctrl), nil))
+			router.GetApp().GET("/failure", func(echo.Context) error { return errors.New("boom") })
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			router.GetApp().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("expected %d, got %d: %s", tt.wantStatusCode, rec.Code, rec.Body.String())
+			}
+			if contentType := rec.Header().Get(echo.HeaderContentType); contentType != MIMEApplicationProblemJSON {
+				t.Errorf("expected the content type %q, got %q", MIMEApplicationProblemJSON, contentType)
+			}
+			if tt.wantProblem == nil {
+				if rec.Body.Len() != 0 {
+					t.Errorf("expected no body, got %s", rec.Body.String())
+				}
+				return
+			}
+			var problem model.Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("failed to decode the problem %s: %v", rec.Body.String(), err)
+			}
+			if !reflect.DeepEqual(&problem, tt.wantProblem) {
+				t.Errorf("expected the problem %+v, got %+v", tt.wantProblem, problem)
+			}
+		})
+	}
+}
+
+func TestApiError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   string
+		wantStatus int
+	}{
+		{"catalog error", model.ErrStatsUnavailable, model.ErrStatsUnavailable.Code, http.StatusServiceUnavailable},
+		{"occurrence of a catalog error", model.ErrForbidden.WithMessage("denied"), model.ErrForbidden.Code, http.StatusForbidden},
+		{"body too large", &http.MaxBytesError{Limit: 16}, model.ErrPayloadTooLarge.Code, http.StatusRequestEntityTooLarge},
+		{"deadline exceeded", context.DeadlineExceeded, model.ErrRequestTimeout.Code, http.StatusServiceUnavailable},
+		{"echo error", echo.NewHTTPError(http.StatusBadRequest, "bad"), model.ErrInvalidRequest.Code, http.StatusBadRequest},
+		{"other error", errors.New("boom"), model.ErrInternal.Code, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr, status := apiError(tt.err)
+			if apiErr.Code != tt.wantCode || status != tt.wantStatus {
+				t.Errorf("apiError() = %s %d, want %s %d", apiErr.Code, status, tt.wantCode, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+const (
+	headerETag         = "ETag"
+	headerIfNoneMatch  = "If-None-Match"
+	headerCacheControl = "Cache-Control"
+
+	// etagVersion changes the ETags of every response, it is increased when the body of a response changes
+	// for the same parameters
+	etagVersion = "1"
+)
+
+// fizzBuzzETag returns the strong ETag of the response to request in format. FizzBuzz is a pure function,
+// so the parameters identify the response without generating it.
+func fizzBuzzETag(request model.FizzBuzzRequest, format responseFormat) (string, error) {
+	// The parameters are hashed in JSON, the words may contain the separators of the request key
+	params, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(etagVersion + "\n" + string(format) + "\n"))
+	hash.Write(params)
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`, nil
+}
+
+// etagMatches reports whether the If-None-Match header lists etag. The ETags are compared with the weak
+// comparison, as required for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControl returns the Cache-Control header of a GET /fizzbuzz response. The responses to the requests
+// authenticated with an API key are only cached by the client.
+func (h *Handler) cacheControl(ctx echo.Context) string {
+	visibility := "public"
+	if _, ok := model.APIKeyFromContext(ctx.Request().Context()); ok {
+		visibility = "private"
+	}
+	if h.cacheMaxAge <= 0 {
+		return visibility + ", no-cache"
+	}
+	return visibility + ", max-age=" + strconv.FormatInt(int64(h.cacheMaxAge/time.Second), 10)
+}
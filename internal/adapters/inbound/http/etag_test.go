@@ -0,0 +1,59 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+func TestFizzBuzzETag(t *testing.T) {
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	etag, err := fizzBuzzETag(request, formatString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := fizzBuzzETag(request, formatString); again != etag {
+		t.Errorf("expected the same ETag for the same parameters, got %s and %s", etag, again)
+	}
+
+	others := []struct {
+		name    string
+		request model.FizzBuzzRequest
+		format  responseFormat
+	}{
+		{"other limit", model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 16, Str1: "Fizz", Str2: "Buzz"}, formatString},
+		{"other format", request, formatArray},
+		{"words sharing the separators of the key", model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz,Buzz", Str2: ""}, formatString},
+	}
+	for _, tt := range others {
+		t.Run(tt.name, func(t *testing.T) {
+			if other, _ := fizzBuzzETag(tt.request, tt.format); other == etag {
+				t.Errorf("expected another ETag than %s", etag)
+			}
+		})
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        bool
+	}{
+		{"no header", "", false},
+		{"same ETag", `"abc"`, true},
+		{"weak ETag", `W/"abc"`, true},
+		{"listed ETag", `"xyz", "abc"`, true},
+		{"any ETag", "*", true},
+		{"other ETag", `"xyz"`, false},
+		{"unquoted ETag", "abc", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagMatches(tt.ifNoneMatch, etag); got != tt.want {
+				t.Errorf("etagMatches(%q) = %v, want %v", tt.ifNoneMatch, got, tt.want)
+			}
+		})
+	}
+}
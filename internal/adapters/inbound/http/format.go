@@ -0,0 +1,170 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// responseFormat is a representation of the FizzBuzz response
+type responseFormat string
+
+const (
+	// formatString is the default {"response": "1,2,Fizz"} representation
+	formatString responseFormat = "string"
+	// formatArray is the {"response": ["1","2","Fizz"]} representation
+	formatArray responseFormat = "array"
+	// formatCSV is a single CSV record of terms
+	formatCSV responseFormat = "csv"
+	// formatText is plain text with one term per line
+	formatText responseFormat = "text"
+	// formatNDJSON is newline delimited JSON with one term per line
+	formatNDJSON responseFormat = "ndjson"
+
+	mimeTextCSV           = "text/csv"
+	mimeApplicationNDJSON = "application/x-ndjson"
+)
+
+// termEncoder writes the terms of a FizzBuzz sequence in a response format
+type termEncoder struct {
+	contentType string
+	prefix      string
+	separator   string
+	suffix      string
+	writeTerm   func(w *bufio.Writer, term string) error
+}
+
+var termEncoders = map[responseFormat]termEncoder{
+	formatString: {
+		contentType: echo.MIMEApplicationJSON,
+		prefix:      `{"response":"`,
+		separator:   ",",
+		suffix:      `"}` + "\n",
+		writeTerm:   writeJSONStringContent,
+	},
+	formatArray: {
+		contentType: echo.MIMEApplicationJSON,
+		prefix:      `{"response":[`,
+		separator:   ",",
+		suffix:      "]}\n",
+		writeTerm:   writeJSONString,
+	},
+	formatCSV: {
+		contentType: mimeTextCSV + "; charset=UTF-8",
+		separator:   ",",
+		suffix:      "\n",
+		writeTerm:   writeCSVField,
+	},
+	formatText: {
+		contentType: echo.MIMETextPlainCharsetUTF8,
+		suffix:      "\n",
+		separator:   "\n",
+		writeTerm:   writeString,
+	},
+	formatNDJSON: {
+		contentType: mimeApplicationNDJSON,
+		separator:   "\n",
+		suffix:      "\n",
+		writeTerm:   writeJSONString,
+	},
+}
+
+// mediaTypeFormats maps the media types of the Accept header to response formats
+var mediaTypeFormats = map[string]responseFormat{
+	"*/*":                    formatString,
+	"application/*":          formatString,
+	echo.MIMEApplicationJSON: formatString,
+	mimeTextCSV:              formatCSV,
+	"text/*":                 formatText,
+	echo.MIMETextPlain:       formatText,
+	mimeApplicationNDJSON:    formatNDJSON,
+}
+
+// negotiateFormat returns the response format requested by the format query parameter or,
+// when it is absent, by the Accept header. The default format is used when nothing matches.
+func negotiateFormat(ctx echo.Context) (responseFormat, error) {
+	if param := ctx.QueryParam("format"); param != "" {
+		format := responseFormat(param)
+		if _, ok := termEncoders[format]; !ok {
+			return "", fmt.Errorf("format must be one of string, array, csv, text, ndjson")
+		}
+		return format, nil
+	}
+
+	type acceptedType struct {
+		mediaType string
+		quality   float64
+	}
+	var accepted []acceptedType
+	for _, value := range strings.Split(ctx.Request().Header.Get(echo.HeaderAccept), ",") {
+		mediaType, params, err := mime.ParseMediaType(value)
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		accepted = append(accepted, acceptedType{mediaType: mediaType, quality: quality})
+	}
+	slices.SortStableFunc(accepted, func(a, b acceptedType) int {
+		switch {
+		case a.quality > b.quality:
+			return -1
+		case a.quality < b.quality:
+			return 1
+		}
+		return 0
+	})
+
+	for _, a := range accepted {
+		if format, ok := mediaTypeFormats[a.mediaType]; ok && a.quality > 0 {
+			return format, nil
+		}
+	}
+	return formatString, nil
+}
+
+// writeString writes s as is
+func writeString(w *bufio.Writer, s string) error {
+	_, err := w.WriteString(s)
+	return err
+}
+
+// writeJSONString writes s as a JSON string
+func writeJSONString(w *bufio.Writer, s string) error {
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(encoded)
+	return err
+}
+
+// writeJSONStringContent writes s escaped as the content of a JSON string, without the quotes
+func writeJSONStringContent(w *bufio.Writer, s string) error {
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(encoded[1 : len(encoded)-1])
+	return err
+}
+
+// writeCSVField writes s as a CSV field, quoting it when needed
+func writeCSVField(w *bufio.Writer, s string) error {
+	if s == "" || (!strings.ContainsAny(s, "\",\r\n") && s[0] != ' ') {
+		_, err := w.WriteString(s)
+		return err
+	}
+	_, err := w.WriteString(`"` + strings.ReplaceAll(s, `"`, `""`) + `"`)
+	return err
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		accept  string
+		want    responseFormat
+		wantErr bool
+	}{
+		{name: "no accept header", path: "/fizzbuzz", want: formatString},
+		{name: "json", path: "/fizzbuzz", accept: "application/json", want: formatString},
+		{name: "csv", path: "/fizzbuzz", accept: "text/csv", want: formatCSV},
+		{name: "plain text", path: "/fizzbuzz", accept: "text/plain; charset=utf-8", want: formatText},
+		{name: "ndjson", path: "/fizzbuzz", accept: "application/x-ndjson", want: formatNDJSON},
+		{name: "quality order", path: "/fizzbuzz", accept: "application/json;q=0.5, text/csv", want: formatCSV},
+		{name: "unsupported media type", path: "/fizzbuzz", accept: "application/xml", want: formatString},
+		{name: "format parameter wins", path: "/fizzbuzz?format=array", accept: "text/csv", want: formatArray},
+		{name: "invalid format parameter", path: "/fizzbuzz?format=xml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := newEchoContext(http.MethodPost, tt.path, nil, nil)
+			if tt.accept != "" {
+				ctx.Request().Header.Set(echo.HeaderAccept, tt.accept)
+			}
+			got, err := negotiateFormat(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("negotiateFormat() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("negotiateFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFizzBuzzStream(t *testing.T) {
+	terms := []string{"1", "2", "a,b", `"q"`}
+	tests := []struct {
+		format          responseFormat
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			format:          formatString,
+			wantContentType: echo.MIMEApplicationJSON,
+			wantBody:        `{"response":"1,2,a,b,\"q\""}` + "\n",
+		},
+		{
+			format:          formatArray,
+			wantContentType: echo.MIMEApplicationJSON,
+			wantBody:        `{"response":["1","2","a,b","\"q\""]}` + "\n",
+		},
+		{
+			format:          formatCSV,
+			wantContentType: "text/csv; charset=UTF-8",
+			wantBody:        `1,2,"a,b","""q"""` + "\n",
+		},
+		{
+			format:          formatText,
+			wantContentType: echo.MIMETextPlainCharsetUTF8,
+			wantBody:        "1\n2\na,b\n\"q\"\n",
+		},
+		{
+			format:          formatNDJSON,
+			wantContentType: "application/x-ndjson",
+			wantBody:        "\"1\"\n\"2\"\n\"a,b\"\n\"\\\"q\\\"\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			ctx, rec := newEchoContext(http.MethodPost, "/fizzbuzz", nil, nil)
+			if err := writeFizzBuzzStream(ctx, termEncoders[tt.format], slices.Values(terms)); err != nil {
+				t.Fatalf("writeFizzBuzzStream() error = %v", err)
+			}
+			if got := rec.Header().Get(echo.HeaderContentType); got != tt.wantContentType {
+				t.Errorf("expected content type %s, got %s", tt.wantContentType, got)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("expected body %s, got %s", tt.wantBody, got)
+			}
+		})
+	}
+}
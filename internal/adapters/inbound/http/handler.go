@@ -1,24 +1,75 @@
 package http
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
 	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
 )
 
+const (
+	// defaultTopRequests is the number of requests returned by /stats/top by default
+	defaultTopRequests = 10
+	// defaultPageSize is the number of requests per page returned by /stats/requests by default
+	defaultPageSize = 20
+	// readinessTimeout bounds the readiness checks of the dependencies
+	readinessTimeout = 2 * time.Second
+	// defaultCacheMaxAge is the time a GET /fizzbuzz response may be reused by default
+	defaultCacheMaxAge = 24 * time.Hour
+)
+
 type Handler struct {
 	fizzBuzzService adapters.FizzBuzzService
 	statsService    adapters.StatsService
+	healthReporters []adapters.HealthReporter
+	readiness       []adapters.ReadinessChecker
+	// cacheMaxAge is the time the clients and the shared caches may reuse a GET /fizzbuzz response
+	cacheMaxAge time.Duration
+	// draining is set once the service shuts down, the readiness probe fails from then on
+	draining atomic.Bool
+}
+
+type HandlerOption func(*Handler)
+
+// WithHealthReporters adds components to the health of the service
+func WithHealthReporters(reporters ...adapters.HealthReporter) HandlerOption {
+	return func(h *Handler) {
+		h.healthReporters = append(h.healthReporters, reporters...)
+	}
+}
+
+// WithReadinessCheckers adds dependencies checked by the readiness probe
+func WithReadinessCheckers(checkers ...adapters.ReadinessChecker) HandlerOption {
+	return func(h *Handler) {
+		h.readiness = append(h.readiness, checkers...)
+	}
 }
 
-func NewHandler(fizzBuzz adapters.FizzBuzzService, sts adapters.StatsService) *Handler {
-	return &Handler{
+// WithCacheMaxAge sets the time the clients and the shared caches may reuse a GET /fizzbuzz response,
+// the responses are revalidated with their ETag on every request when maxAge is zero
+func WithCacheMaxAge(maxAge time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.cacheMaxAge = maxAge
+	}
+}
+
+func NewHandler(fizzBuzz adapters.FizzBuzzService, sts adapters.StatsService, opts ...HandlerOption) *Handler {
+	handler := &Handler{
 		fizzBuzzService: fizzBuzz,
 		statsService:    sts,
+		cacheMaxAge:     defaultCacheMaxAge,
 	}
+	for _, opt := range opts {
+		opt(handler)
+	}
+	return handler
 }
 
 // HandleFizzBuzzRequest handles the FizzBuzz request
@@ -26,25 +77,68 @@ func (h *Handler) HandleFizzBuzzRequest(ctx echo.Context) error {
 
 	var request model.FizzBuzzRequest
 	if err := ctx.Bind(&request); err != nil {
-		return ctx.JSON(http.StatusBadRequest, echo.Map{
-			"message": err.Error(),
-			"code":    "invalid_payload",
-		})
+		return invalidPayload(err)
+	}
+
+	if err := ctx.Validate(request); err != nil {
+		return invalidRequest(err)
+	}
+
+	format, err := negotiateFormat(ctx)
+	if err != nil {
+		return model.ErrInvalidFormat.WithMessage(err.Error())
+	}
+	ctx.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+
+	return h.writeFizzBuzz(ctx, request, format)
+}
+
+// HandleGetFizzBuzz handles the FizzBuzz request of the query parameters. The response is cacheable, its ETag
+// is derived from the parameters, so a request matching the ETag of the client is answered 304 without
+// generating the sequence.
+func (h *Handler) HandleGetFizzBuzz(ctx echo.Context) error {
+	var request model.FizzBuzzRequest
+	if err := ctx.Bind(&request); err != nil {
+		return invalidPayload(err)
 	}
 
 	if err := ctx.Validate(request); err != nil {
-		return ctx.JSON(http.StatusBadRequest, echo.Map{
-			"message": err.Error(),
-			"code":    "invalid_request",
-		})
+		return invalidRequest(err)
+	}
+
+	format, err := negotiateFormat(ctx)
+	if err != nil {
+		return model.ErrInvalidFormat.WithMessage(err.Error())
+	}
+
+	etag, err := fizzBuzzETag(request, format)
+	if err != nil {
+		return internalError("Failed to compute the ETag", err)
+	}
+	header := ctx.Response().Header()
+	header.Add(echo.HeaderVary, echo.HeaderAccept)
+	header.Set(headerETag, etag)
+	header.Set(headerCacheControl, h.cacheControl(ctx))
+	if etagMatches(ctx.Request().Header.Get(headerIfNoneMatch), etag) {
+		return ctx.NoContent(http.StatusNotModified)
+	}
+
+	return h.writeFizzBuzz(ctx, request, format)
+}
+
+// writeFizzBuzz writes the FizzBuzz response of a validated request in the given format
+func (h *Handler) writeFizzBuzz(ctx echo.Context, request model.FizzBuzzRequest, format responseFormat) error {
+	// Only the default format is cached, the other ones are written from the sequence terms
+	if stream, _ := strconv.ParseBool(ctx.QueryParam("stream")); stream || format != formatString {
+		return h.streamFizzBuzz(ctx, request, format)
 	}
 
-	response, err := h.fizzBuzzService.GenerateFizzBuzz(request.Int1, request.Int2, request.Limit, request.Str1, request.Str2)
+	response, err := h.fizzBuzzService.GenerateFizzBuzz(ctx.Request().Context(), request)
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, echo.Map{
-			"message": "Failed to generate FizzBuzz response: " + err.Error(),
-			"code":    "internal_error",
-		})
+		if errors.Is(err, context.DeadlineExceeded) {
+			return model.ErrRequestTimeout
+		}
+		return internalError("Failed to generate FizzBuzz response", err)
 	}
 
 	resp := model.FizzBuzzResponse{
@@ -54,28 +148,283 @@ func (h *Handler) HandleFizzBuzzRequest(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, resp)
 }
 
+// HandleFizzBuzzBatch handles a batch of FizzBuzz requests. Every request is answered in the results with the
+// status it would have been answered alone, the invalid ones are not generated.
+func (h *Handler) HandleFizzBuzzBatch(ctx echo.Context) error {
+	var requests []model.FizzBuzzRequest
+	if err := ctx.Bind(&requests); err != nil {
+		return invalidPayload(err)
+	}
+	if len(requests) == 0 {
+		return model.ErrInvalidRequest.WithMessage("The batch must have at least one request")
+	}
+
+	items := make([]model.FizzBuzzBatchItem, len(requests))
+	valid := make([]model.FizzBuzzRequest, 0, len(requests))
+	validIndexes := make([]int, 0, len(requests))
+	for i, request := range requests {
+		if err := ctx.Validate(request); err != nil {
+			items[i] = batchItemError(i, invalidRequest(err))
+			continue
+		}
+		valid = append(valid, request)
+		validIndexes = append(validIndexes, i)
+	}
+
+	results, err := h.fizzBuzzService.GenerateFizzBuzzBatch(ctx.Request().Context(), valid)
+	if err != nil {
+		if errors.Is(err, model.ErrBatchTooLarge) {
+			return err
+		}
+		return internalError("Failed to generate FizzBuzz batch", err)
+	}
+	for j, result := range results {
+		i := validIndexes[j]
+		if result.Err != nil {
+			items[i] = batchItemError(i, result.Err)
+			continue
+		}
+		items[i] = model.FizzBuzzBatchItem{Index: i, Status: http.StatusOK, Response: result.Response}
+	}
+
+	return ctx.JSON(http.StatusOK, model.NewFizzBuzzBatchResponse(items))
+}
+
+// batchItemError returns the response to the request of a batch at index which failed with err
+func batchItemError(index int, err error) model.FizzBuzzBatchItem {
+	var apiErr *model.Error
+	if !errors.As(err, &apiErr) && !errors.Is(err, context.DeadlineExceeded) {
+		err = model.ErrInternal.WithMessage("Failed to generate FizzBuzz response: " + err.Error())
+	}
+	apiErr, status := apiError(err)
+	problem := model.NewProblem(apiErr, status, "")
+	return model.FizzBuzzBatchItem{Index: index, Status: status, Error: &problem}
+}
+
+// streamFizzBuzz writes the FizzBuzz response in the given format as chunks while the sequence is generated
+func (h *Handler) streamFizzBuzz(ctx echo.Context, request model.FizzBuzzRequest, format responseFormat) error {
+	terms, err := h.fizzBuzzService.StreamFizzBuzz(ctx.Request().Context(), request)
+	if err != nil {
+		return internalError("Failed to generate FizzBuzz response", err)
+	}
+
+	return writeFizzBuzzStream(ctx, termEncoders[format], terms)
+}
+
 // HandleGetStats handles the statistics request
 func (h *Handler) HandleGetStats(ctx echo.Context) error {
-	sts, err := h.statsService.GetStats()
+	var request model.StatsRequest
+	if err := ctx.Bind(&request); err != nil {
+		return invalidPayload(err)
+	}
+
+	if err := ctx.Validate(request); err != nil {
+		return invalidRequest(err)
+	}
+
+	// The statistics of a key are read by its own client, or by an admin
+	if request.Key != "" {
+		apiKey, ok := model.APIKeyFromContext(ctx.Request().Context())
+		if !ok {
+			return model.ErrInvalidRequest.WithMessage("The statistics of a key require API key authentication")
+		}
+		if apiKey.Name != request.Key && !apiKey.HasScope(model.ScopeAdmin) {
+			return model.ErrForbidden.WithMessage("The API key may only read its own statistics")
+		}
+	}
+
+	var sts *model.StatsResult
+	var err error
+	if request.IsWindowed() {
+		from, to, rangeErr := request.Range(time.Now())
+		if rangeErr != nil {
+			return model.ErrInvalidRequest.WithMessage(rangeErr.Error())
+		}
+		if request.Key != "" {
+			sts, err = h.statsService.GetKeyStatsBetween(ctx.Request().Context(), request.Key, from, to)
+		} else {
+			sts, err = h.statsService.GetStatsBetween(ctx.Request().Context(), from, to)
+		}
+	} else if request.Key != "" {
+		sts, err = h.statsService.GetKeyStats(ctx.Request().Context(), request.Key)
+	} else {
+		sts, err = h.statsService.GetStats(ctx.Request().Context())
+	}
 	if err != nil {
-		if errors.Is(err, model.ErrNoRequestsFound) {
-			return ctx.JSON(http.StatusNotFound, err)
+		if errors.Is(err, model.ErrNoRequestsFound) || errors.Is(err, model.ErrStatsUnavailable) {
+			return err
 		}
+		return internalError("Failed to retrieve statistics", err)
+	}
+
+	statsResponse := newStatsResponse(sts)
+
+	return ctx.JSON(http.StatusOK, statsResponse)
+}
+
+// HandleGetTopStats handles the request of the most frequent requests
+func (h *Handler) HandleGetTopStats(ctx echo.Context) error {
+	request := model.StatsTopRequest{N: defaultTopRequests}
+	if err := ctx.Bind(&request); err != nil {
+		return invalidPayload(err)
+	}
+
+	if err := ctx.Validate(request); err != nil {
+		return invalidRequest(err)
+	}
+
+	page, err := h.statsService.GetTopRequests(ctx.Request().Context(), request.N)
+	if err != nil {
+		if errors.Is(err, model.ErrStatsUnavailable) {
+			return err
+		}
+		return internalError("Failed to retrieve statistics", err)
+	}
+
+	return ctx.JSON(http.StatusOK, newStatsListResponse(page))
+}
+
+// HandleListStats handles the paginated list of requests ordered by hits
+func (h *Handler) HandleListStats(ctx echo.Context) error {
+	request := model.StatsPageRequest{Page: 1, PageSize: defaultPageSize}
+	if err := ctx.Bind(&request); err != nil {
+		return invalidPayload(err)
+	}
+
+	if err := ctx.Validate(request); err != nil {
+		return invalidRequest(err)
+	}
+
+	page, err := h.statsService.GetRequests(ctx.Request().Context(), request.Page, request.PageSize)
+	if err != nil {
+		if errors.Is(err, model.ErrStatsUnavailable) {
+			return err
+		}
+		return internalError("Failed to retrieve statistics", err)
+	}
 
-		return ctx.JSON(http.StatusInternalServerError, echo.Map{
-			"message": "Failed to retrieve statistics: " + err.Error(),
-			"code":    "internal_error",
-		})
+	resp := newStatsListResponse(page)
+	resp.Page = request.Page
+	resp.PageSize = request.PageSize
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// HandleResetStats handles the reset of all the statistics
+func (h *Handler) HandleResetStats(ctx echo.Context) error {
+	if err := h.statsService.ResetStats(ctx.Request().Context()); err != nil {
+		if errors.Is(err, model.ErrStatsUnavailable) {
+			return err
+		}
+		return internalError("Failed to reset statistics", err)
 	}
 
-	statsResponse := model.StatsResponse{
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// HandleRemoveStatsRequest handles the removal of the statistics of one request parameters
+func (h *Handler) HandleRemoveStatsRequest(ctx echo.Context) error {
+	var request model.FizzBuzzRequest
+	if err := ctx.Bind(&request); err != nil {
+		return invalidPayload(err)
+	}
+
+	if err := ctx.Validate(request); err != nil {
+		return invalidRequest(err)
+	}
+
+	if err := h.statsService.RemoveRequest(ctx.Request().Context(), request); err != nil {
+		if errors.Is(err, model.ErrRequestNotFound) || errors.Is(err, model.ErrStatsUnavailable) {
+			return err
+		}
+		return internalError("Failed to remove statistics", err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// HandleFlushCache handles the removal of all the cached FizzBuzz responses
+func (h *Handler) HandleFlushCache(ctx echo.Context) error {
+	if err := h.fizzBuzzService.FlushCache(ctx.Request().Context()); err != nil {
+		if errors.Is(err, model.ErrCacheUnavailable) {
+			return err
+		}
+		return internalError("Failed to flush cache", err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// HandleHealth handles the health request, reporting the components working in a degraded state
+func (h *Handler) HandleHealth(ctx echo.Context) error {
+	components := make([]model.ComponentHealth, 0, len(h.healthReporters))
+	for _, reporter := range h.healthReporters {
+		components = append(components, reporter.Health())
+	}
+
+	return ctx.JSON(http.StatusOK, model.NewHealthResponse(components))
+}
+
+// HandleLiveness reports that the process is alive and serves HTTP requests
+func (h *Handler) HandleLiveness(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, model.NewHealthResponse(nil))
+}
+
+// HandleReadiness checks the dependencies in use, it answers 503 when one of them is unavailable
+// or once the service shuts down
+func (h *Handler) HandleReadiness(ctx echo.Context) error {
+	if h.draining.Load() {
+		return ctx.JSON(http.StatusServiceUnavailable, model.NewReadinessResponse([]model.ComponentHealth{{
+			Name:   "server",
+			Status: model.HealthStatusUnavailable,
+			Error:  "shutting down",
+		}}))
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx.Request().Context(), readinessTimeout)
+	defer cancel()
+
+	components := make([]model.ComponentHealth, len(h.readiness))
+	var wg sync.WaitGroup
+	for i, checker := range h.readiness {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			components[i] = checker.CheckReadiness(checkCtx)
+		}()
+	}
+	wg.Wait()
+
+	resp := model.NewReadinessResponse(components)
+	if resp.Status != model.HealthStatusOK {
+		return ctx.JSON(http.StatusServiceUnavailable, resp)
+	}
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// Drain makes the readiness probe fail, so the load balancers stop sending requests before the server shuts down
+func (h *Handler) Drain() {
+	h.draining.Store(true)
+}
+
+func newStatsResponse(sts *model.StatsResult) model.StatsResponse {
+	return model.StatsResponse{
 		Int1:  sts.Int1,
 		Int2:  sts.Int2,
 		Limit: sts.Limit,
 		Str1:  sts.Str1,
 		Str2:  sts.Str2,
+		Rules: sts.Rules,
 		Hits:  sts.Hits,
 	}
+}
 
-	return ctx.JSON(http.StatusOK, statsResponse)
+func newStatsListResponse(page *model.StatsPage) model.StatsListResponse {
+	resp := model.StatsListResponse{
+		Requests: make([]model.StatsResponse, 0, len(page.Results)),
+		Total:    page.Total,
+	}
+	for i := range page.Results {
+		resp.Requests = append(resp.Requests, newStatsResponse(&page.Results[i]))
+	}
+	return resp
 }
@@ -2,12 +2,16 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
@@ -18,6 +22,7 @@ import (
 func newEchoContext(method, path string, body io.Reader, validator echo.Validator) (echo.Context, *httptest.ResponseRecorder) {
 	e := echo.New()
 	e.Validator = validator
+	e.HTTPErrorHandler = handleError
 	req := httptest.NewRequest(method, path, body)
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
@@ -25,6 +30,13 @@ func newEchoContext(method, path string, body io.Reader, validator echo.Validato
 	return ctx, rec
 }
 
+// serve calls handler with ctx, answering the error it returns as the router does
+func serve(ctx echo.Context, handler echo.HandlerFunc) {
+	if err := handler(ctx); err != nil {
+		ctx.Error(err)
+	}
+}
+
 type errorValidator struct{}
 
 func (v *errorValidator) Validate(i interface{}) error {
@@ -37,6 +49,8 @@ func TestHandler_HandleFizzBuzzRequest(t *testing.T) {
 
 	validReq := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
 	validReqBody, _ := json.Marshal(validReq)
+	rulesReq := model.FizzBuzzRequest{Limit: 7, Rules: []model.Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 5, Word: "Buzz"}, {Divisor: 7, Word: "Bazz"}}}
+	rulesReqBody, _ := json.Marshal(rulesReq)
 
 	tests := []struct {
 		name           string
@@ -48,12 +62,21 @@ func TestHandler_HandleFizzBuzzRequest(t *testing.T) {
 		{
 			name: "success",
 			mockService: func(m *adapters.MockFizzBuzzService) {
-				m.EXPECT().GenerateFizzBuzz(3, 5, 15, "Fizz", "Buzz").Return("1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz", nil)
+				m.EXPECT().GenerateFizzBuzz(gomock.Any(), validReq).Return("1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz", nil)
 			},
 			validator:      NewValidator(),
 			body:           validReqBody,
 			wantStatusCode: http.StatusOK,
 		},
+		{
+			name: "rule list success",
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().GenerateFizzBuzz(gomock.Any(), rulesReq).Return("1,2,Fizz,4,Buzz,Fizz,Bazz", nil)
+			},
+			validator:      NewValidator(),
+			body:           rulesReqBody,
+			wantStatusCode: http.StatusOK,
+		},
 		{
 			name:           "invalid json",
 			mockService:    nil,
@@ -71,7 +94,7 @@ func TestHandler_HandleFizzBuzzRequest(t *testing.T) {
 		{
 			name: "service error",
 			mockService: func(m *adapters.MockFizzBuzzService) {
-				m.EXPECT().GenerateFizzBuzz(3, 5, 15, "Fizz", "Buzz").Return("", errors.New("service fail"))
+				m.EXPECT().GenerateFizzBuzz(gomock.Any(), validReq).Return("", errors.New("service fail"))
 			},
 			validator:      NewValidator(),
 			body:           validReqBody,
@@ -88,7 +111,7 @@ func TestHandler_HandleFizzBuzzRequest(t *testing.T) {
 
 			h := NewHandler(mockFizzBuzz, nil)
 			ctx, rec := newEchoContext(http.MethodPost, "/fizzbuzz", bytes.NewReader(tt.body), tt.validator)
-			_ = h.HandleFizzBuzzRequest(ctx)
+			serve(ctx, h.HandleFizzBuzzRequest)
 
 			if rec.Code != tt.wantStatusCode {
 				t.Errorf("expected %d, got %d", tt.wantStatusCode, rec.Code)
@@ -97,6 +120,242 @@ func TestHandler_HandleFizzBuzzRequest(t *testing.T) {
 	}
 }
 
+func TestHandler_HandleGetFizzBuzz(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	validReq := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	validQuery := "/fizzbuzz?int1=3&int2=5&limit=15&str1=Fizz&str2=Buzz"
+	etag, err := fizzBuzzETag(validReq, formatString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		mockService      func(*adapters.MockFizzBuzzService)
+		opts             []HandlerOption
+		target           string
+		headers          map[string]string
+		apiKey           bool
+		wantStatusCode   int
+		wantETag         string
+		wantCacheControl string
+	}{
+		{
+			name: "success",
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().GenerateFizzBuzz(gomock.Any(), validReq).Return("1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz", nil)
+			},
+			target:           validQuery,
+			wantStatusCode:   http.StatusOK,
+			wantETag:         etag,
+			wantCacheControl: "public, max-age=86400",
+		},
+		{
+			name:             "matching ETag is not modified",
+			target:           validQuery,
+			headers:          map[string]string{headerIfNoneMatch: etag},
+			wantStatusCode:   http.StatusNotModified,
+			wantETag:         etag,
+			wantCacheControl: "public, max-age=86400",
+		},
+		{
+			name:             "ETag listed as a weak ETag",
+			target:           validQuery,
+			headers:          map[string]string{headerIfNoneMatch: `"other", W/` + etag},
+			wantStatusCode:   http.StatusNotModified,
+			wantETag:         etag,
+			wantCacheControl: "public, max-age=86400",
+		},
+		{
+			name: "ETag of other parameters",
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().GenerateFizzBuzz(gomock.Any(), validReq).Return("1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz", nil)
+			},
+			target:           validQuery,
+			headers:          map[string]string{headerIfNoneMatch: `"other"`},
+			wantStatusCode:   http.StatusOK,
+			wantETag:         etag,
+			wantCacheControl: "public, max-age=86400",
+		},
+		{
+			name: "request authenticated with an API key",
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().GenerateFizzBuzz(gomock.Any(), validReq).Return("1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz", nil)
+			},
+			opts:             []HandlerOption{WithCacheMaxAge(time.Hour)},
+			target:           validQuery,
+			apiKey:           true,
+			wantStatusCode:   http.StatusOK,
+			wantETag:         etag,
+			wantCacheControl: "private, max-age=3600",
+		},
+		{
+			name:             "revalidated on every request without max age",
+			opts:             []HandlerOption{WithCacheMaxAge(0)},
+			target:           validQuery,
+			headers:          map[string]string{headerIfNoneMatch: etag},
+			wantStatusCode:   http.StatusNotModified,
+			wantETag:         etag,
+			wantCacheControl: "public, no-cache",
+		},
+		{
+			name:           "invalid parameters",
+			target:         "/fizzbuzz?int1=0&int2=5&limit=15&str1=Fizz&str2=Buzz",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "parameter which is not a number",
+			target:         "/fizzbuzz?int1=three&int2=5&limit=15&str1=Fizz&str2=Buzz",
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFizzBuzz := adapters.NewMockFizzBuzzService(ctrl)
+			if tt.mockService != nil {
+				tt.mockService(mockFizzBuzz)
+			}
+
+			h := NewHandler(mockFizzBuzz, nil, tt.opts...)
+			ctx, rec := newEchoContext(http.MethodGet, tt.target, nil, NewValidator())
+			for header, value := range tt.headers {
+				ctx.Request().Header.Set(header, value)
+			}
+			if tt.apiKey {
+				req := ctx.Request()
+				ctx.SetRequest(req.WithContext(model.ContextWithAPIKey(req.Context(), model.APIKey{Name: "acme"})))
+			}
+			serve(ctx, h.HandleGetFizzBuzz)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("expected %d, got %d: %s", tt.wantStatusCode, rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get(headerETag); got != tt.wantETag {
+				t.Errorf("expected the ETag %q, got %q", tt.wantETag, got)
+			}
+			if got := rec.Header().Get(headerCacheControl); got != tt.wantCacheControl {
+				t.Errorf("expected the Cache-Control %q, got %q", tt.wantCacheControl, got)
+			}
+			if tt.wantStatusCode == http.StatusNotModified && rec.Body.Len() != 0 {
+				t.Errorf("expected no body, got %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_HandleFizzBuzzBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	validReq := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	otherReq := model.FizzBuzzRequest{Int1: 2, Int2: 3, Limit: 6, Str1: "Foo", Str2: "Bar"}
+	invalidReq := model.FizzBuzzRequest{Int1: 0, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	batchBody, _ := json.Marshal([]model.FizzBuzzRequest{validReq, invalidReq, otherReq})
+
+	tests := []struct {
+		name           string
+		mockService    func(*adapters.MockFizzBuzzService)
+		body           string
+		wantStatusCode int
+		wantResponse   *model.FizzBuzzBatchResponse
+	}{
+		{
+			name: "status of each request",
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().GenerateFizzBuzzBatch(gomock.Any(), []model.FizzBuzzRequest{validReq, otherReq}).Return(
+					[]model.FizzBuzzBatchResult{
+						{Response: "1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz"},
+						{Err: model.ErrStatsUnavailable},
+					}, nil)
+			},
+			body:           string(batchBody),
+			wantStatusCode: http.StatusOK,
+			wantResponse: &model.FizzBuzzBatchResponse{
+				Results: []model.FizzBuzzBatchItem{
+					{Index: 0, Status: http.StatusOK, Response: "1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz"},
+					{Index: 1, Status: http.StatusBadRequest, Error: &model.Problem{
+						Type:    "urn:fizzbuzz-api:problem:invalid_request",
+						Title:   "Invalid request",
+						Status:  http.StatusBadRequest,
+						Detail:  "int1 must be greater than 1",
+						Code:    "invalid_request",
+						Message: "int1 must be greater than 1",
+						Errors: []model.FieldError{
+							{Field: "int1", Rule: "min", Param: "1", Message: "int1 must be greater than 1"},
+						},
+					}},
+					{Index: 2, Status: http.StatusServiceUnavailable, Error: &model.Problem{
+						Type:    "urn:fizzbuzz-api:problem:stats_unavailable",
+						Title:   "Statistics unavailable",
+						Status:  http.StatusServiceUnavailable,
+						Detail:  "Statistics are temporarily unavailable",
+						Code:    "stats_unavailable",
+						Message: "Statistics are temporarily unavailable",
+					}},
+				},
+				Succeeded: 1,
+				Failed:    2,
+			},
+		},
+		{
+			name:           "empty batch",
+			body:           "[]",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "batch which is not an array",
+			body:           `{"int1":3}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "batch over the budget",
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().GenerateFizzBuzzBatch(gomock.Any(), gomock.Any()).Return(nil, model.ErrBatchTooLarge)
+			},
+			body:           string(batchBody),
+			wantStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "service error",
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().GenerateFizzBuzzBatch(gomock.Any(), gomock.Any()).Return(nil, errors.New("service fail"))
+			},
+			body:           string(batchBody),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFizzBuzz := adapters.NewMockFizzBuzzService(ctrl)
+			if tt.mockService != nil {
+				tt.mockService(mockFizzBuzz)
+			}
+
+			h := NewHandler(mockFizzBuzz, nil)
+			ctx, rec := newEchoContext(http.MethodPost, "/fizzbuzz/batch", bytes.NewBufferString(tt.body), NewValidator())
+			serve(ctx, h.HandleFizzBuzzBatch)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("expected %d, got %d: %s", tt.wantStatusCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantResponse == nil {
+				return
+			}
+			var resp model.FizzBuzzBatchResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode the response %s: %v", rec.Body.String(), err)
+			}
+			if !reflect.DeepEqual(&resp, tt.wantResponse) {
+				t.Errorf("expected the response %+v, got %+v", tt.wantResponse, resp)
+			}
+		})
+	}
+}
+
 func TestHandler_HandleGetStats(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -105,30 +364,102 @@ func TestHandler_HandleGetStats(t *testing.T) {
 
 	tests := []struct {
 		name           string
+		path           string
 		mockService    func(*adapters.MockStatsService)
 		wantStatusCode int
 	}{
 		{
 			name: "success",
+			path: "/stats",
 			mockService: func(m *adapters.MockStatsService) {
-				m.EXPECT().GetStats().Return(statResult, nil)
+				m.EXPECT().GetStats(gomock.Any()).Return(statResult, nil)
 			},
 			wantStatusCode: http.StatusOK,
 		},
 		{
 			name: "no stats found",
+			path: "/stats",
 			mockService: func(m *adapters.MockStatsService) {
-				m.EXPECT().GetStats().Return(nil, model.ErrNoRequestsFound)
+				m.EXPECT().GetStats(gomock.Any()).Return(nil, model.ErrNoRequestsFound)
 			},
 			wantStatusCode: http.StatusNotFound,
 		},
 		{
 			name: "service error",
+			path: "/stats",
 			mockService: func(m *adapters.MockStatsService) {
-				m.EXPECT().GetStats().Return(nil, errors.New("db fail"))
+				m.EXPECT().GetStats(gomock.Any()).Return(nil, errors.New("db fail"))
 			},
 			wantStatusCode: http.StatusInternalServerError,
 		},
+		{
+			name: "stats unavailable",
+			path: "/stats",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().GetStats(gomock.Any()).Return(nil, model.ErrStatsUnavailable)
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			name: "window",
+			path: "/stats?window=1h",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().GetStatsBetween(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, from, to time.Time) (*model.StatsResult, error) {
+					if to.Sub(from) != time.Hour {
+						t.Errorf("expected a one hour range, got %v", to.Sub(from))
+					}
+					return statResult, nil
+				})
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name: "from and to",
+			path: "/stats?from=2024-05-09T10:00:00Z&to=2024-05-09T12:00:00Z",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().GetStatsBetween(gomock.Any(),
+					time.Date(2024, 5, 9, 10, 0, 0, 0, time.UTC),
+					time.Date(2024, 5, 9, 12, 0, 0, 0, time.UTC),
+				).Return(nil, model.ErrNoRequestsFound)
+			},
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "from and to with fractional seconds",
+			path: "/stats?from=2025-01-01T00:00:00.5Z&to=2025-01-01T01:00:00.25Z",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().GetStatsBetween(gomock.Any(),
+					time.Date(2025, 1, 1, 0, 0, 0, 500000000, time.UTC),
+					time.Date(2025, 1, 1, 1, 0, 0, 250000000, time.UTC),
+				).Return(nil, model.ErrNoRequestsFound)
+			},
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "invalid window",
+			path:           "/stats?window=2h",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid from",
+			path:           "/stats?from=yesterday",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "window combined with from",
+			path:           "/stats?window=1h&from=2024-05-09T10:00:00Z",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "from after to",
+			path:           "/stats?from=2024-05-09T12:00:00Z&to=2024-05-09T10:00:00Z",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "key without API key authentication",
+			path:           "/stats?key=acme",
+			wantStatusCode: http.StatusBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
@@ -138,12 +469,519 @@ func TestHandler_HandleGetStats(t *testing.T) {
 				tt.mockService(mockStats)
 			}
 			h := NewHandler(nil, mockStats)
-			ctx, rec := newEchoContext(http.MethodGet, "/stats", nil, nil)
-			_ = h.HandleGetStats(ctx)
+			ctx, rec := newEchoContext(http.MethodGet, tt.path, nil, NewValidator())
+			serve(ctx, h.HandleGetStats)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("expected %d, got %d", tt.wantStatusCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_HandleFizzBuzzRequest_Stream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	validReq := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 5, Str1: `"Fizz"`, Str2: "Buzz"}
+	validReqBody, _ := json.Marshal(validReq)
+
+	tests := []struct {
+		name           string
+		path           string
+		mockService    func(*adapters.MockFizzBuzzService)
+		wantStatusCode int
+		wantBody       string
+	}{
+		{
+			name: "success",
+			path: "/fizzbuzz?stream=true",
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().StreamFizzBuzz(gomock.Any(), validReq).Return(slices.Values([]string{"1", "2", `"Fizz"`, "4", "Buzz"}), nil)
+			},
+			wantStatusCode: http.StatusOK,
+			wantBody:       `{"response":"1,2,\"Fizz\",4,Buzz"}` + "\n",
+		},
+		{
+			name: "csv format",
+			path: "/fizzbuzz?format=csv",
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().StreamFizzBuzz(gomock.Any(), validReq).Return(slices.Values([]string{"1", "2", `"Fizz"`, "4", "Buzz"}), nil)
+			},
+			wantStatusCode: http.StatusOK,
+			wantBody:       `1,2,"""Fizz""",4,Buzz` + "\n",
+		},
+		{
+			name:           "invalid format",
+			path:           "/fizzbuzz?format=xml",
+			mockService:    func(m *adapters.MockFizzBuzzService) {},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "service error",
+			path: "/fizzbuzz?stream=true",
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().StreamFizzBuzz(gomock.Any(), validReq).Return(nil, errors.New("service fail"))
+			},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFizzBuzz := adapters.NewMockFizzBuzzService(ctrl)
+			tt.mockService(mockFizzBuzz)
+
+			h := NewHandler(mockFizzBuzz, nil)
+			ctx, rec := newEchoContext(http.MethodPost, tt.path, bytes.NewReader(validReqBody), NewValidator())
+			serve(ctx, h.HandleFizzBuzzRequest)
 
 			if rec.Code != tt.wantStatusCode {
 				t.Errorf("expected %d, got %d", tt.wantStatusCode, rec.Code)
 			}
+			if tt.wantBody == "" {
+				return
+			}
+			if !rec.Flushed {
+				t.Errorf("expected the response to be flushed")
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("expected body %s, got %s", tt.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestHandler_HandleGetTopStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	page := &model.StatsPage{
+		Results: []model.StatsResult{{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 10}},
+		Total:   1,
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		mockService    func(*adapters.MockStatsService)
+		wantStatusCode int
+	}{
+		{
+			name: "default n",
+			path: "/stats/top",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().GetTopRequests(gomock.Any(), 10).Return(page, nil)
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name: "custom n",
+			path: "/stats/top?n=3",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().GetTopRequests(gomock.Any(), 3).Return(page, nil)
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "invalid n",
+			path:           "/stats/top?n=1000",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "malformed n",
+			path:           "/stats/top?n=abc",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "service error",
+			path: "/stats/top",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().GetTopRequests(gomock.Any(), 10).Return(nil, errors.New("db fail"))
+			},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStats := adapters.NewMockStatsService(ctrl)
+			if tt.mockService != nil {
+				tt.mockService(mockStats)
+			}
+			h := NewHandler(nil, mockStats)
+			ctx, rec := newEchoContext(http.MethodGet, tt.path, nil, NewValidator())
+			serve(ctx, h.HandleGetTopStats)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("expected %d, got %d", tt.wantStatusCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_HandleListStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	page := &model.StatsPage{
+		Results: []model.StatsResult{{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 10}},
+		Total:   41,
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		mockService    func(*adapters.MockStatsService)
+		wantStatusCode int
+		wantBody       string
+	}{
+		{
+			name: "default page",
+			path: "/stats/requests",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().GetRequests(gomock.Any(), 1, 20).Return(page, nil)
+			},
+			wantStatusCode: http.StatusOK,
+			wantBody:       `{"requests":[{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz","hits":10}],"total":41,"page":1,"page_size":20}` + "\n",
+		},
+		{
+			name: "custom page",
+			path: "/stats/requests?page=3&page_size=5",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().GetRequests(gomock.Any(), 3, 5).Return(page, nil)
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "invalid page",
+			path:           "/stats/requests?page=0",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "service error",
+			path: "/stats/requests",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().GetRequests(gomock.Any(), 1, 20).Return(nil, errors.New("db fail"))
+			},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStats := adapters.NewMockStatsService(ctrl)
+			if tt.mockService != nil {
+				tt.mockService(mockStats)
+			}
+			h := NewHandler(nil, mockStats)
+			ctx, rec := newEchoContext(http.MethodGet, tt.path, nil, NewValidator())
+			serve(ctx, h.HandleListStats)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("expected %d, got %d", tt.wantStatusCode, rec.Code)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("expected body %s, got %s", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_HandleResetStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name           string
+		mockService    func(*adapters.MockStatsService)
+		wantStatusCode int
+	}{
+		{
+			name: "success",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().ResetStats(gomock.Any()).Return(nil)
+			},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name: "service error",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().ResetStats(gomock.Any()).Return(errors.New("db fail"))
+			},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStats := adapters.NewMockStatsService(ctrl)
+			tt.mockService(mockStats)
+			h := NewHandler(nil, mockStats)
+			ctx, rec := newEchoContext(http.MethodDelete, "/admin/stats", nil, nil)
+			serve(ctx, h.HandleResetStats)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("expected %d, got %d", tt.wantStatusCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_HandleFlushCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name           string
+		mockService    func(*adapters.MockFizzBuzzService)
+		wantStatusCode int
+	}{
+		{
+			name: "success",
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().FlushCache(gomock.Any()).Return(nil)
+			},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name: "cache error",
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().FlushCache(gomock.Any()).Return(errors.New("redis fail"))
+			},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name: "cache unavailable",
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().FlushCache(gomock.Any()).Return(model.ErrCacheUnavailable)
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFizzBuzz := adapters.NewMockFizzBuzzService(ctrl)
+			tt.mockService(mockFizzBuzz)
+			h := NewHandler(mockFizzBuzz, nil)
+			ctx, rec := newEchoContext(http.MethodDelete, "/admin/cache", nil, nil)
+			serve(ctx, h.HandleFlushCache)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("expected %d, got %d", tt.wantStatusCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_HandleRemoveStatsRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	validReq := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	validReqBody, _ := json.Marshal(validReq)
+
+	tests := []struct {
+		name           string
+		mockService    func(*adapters.MockStatsService)
+		body           []byte
+		wantStatusCode int
+	}{
+		{
+			name: "success",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().RemoveRequest(gomock.Any(), validReq).Return(nil)
+			},
+			body:           validReqBody,
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "invalid request",
+			body:           []byte(`{"int1":0,"int2":5,"limit":15}`),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "request not found",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().RemoveRequest(gomock.Any(), validReq).Return(model.ErrRequestNotFound)
+			},
+			body:           validReqBody,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "service error",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().RemoveRequest(gomock.Any(), validReq).Return(errors.New("db fail"))
+			},
+			body:           validReqBody,
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name: "stats unavailable",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().RemoveRequest(gomock.Any(), validReq).Return(model.ErrStatsUnavailable)
+			},
+			body:           validReqBody,
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStats := adapters.NewMockStatsService(ctrl)
+			if tt.mockService != nil {
+				tt.mockService(mockStats)
+			}
+			h := NewHandler(nil, mockStats)
+			ctx, rec := newEchoContext(http.MethodDelete, "/admin/stats/requests", bytes.NewReader(tt.body), NewValidator())
+			serve(ctx, h.HandleRemoveStatsRequest)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("expected %d, got %d", tt.wantStatusCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_HandleHealth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name       string
+		components []model.ComponentHealth
+		wantStatus model.HealthStatus
+	}{
+		{
+			name:       "no components",
+			wantStatus: model.HealthStatusOK,
+		},
+		{
+			name: "components ok",
+			components: []model.ComponentHealth{
+				{Name: "stats", Status: model.HealthStatusOK, Breaker: "closed"},
+				{Name: "cache", Status: model.HealthStatusOK, Breaker: "closed"},
+			},
+			wantStatus: model.HealthStatusOK,
+		},
+		{
+			name: "stats degraded",
+			components: []model.ComponentHealth{
+				{Name: "stats", Status: model.HealthStatusDegraded, Breaker: "open", BufferedRequests: 3},
+				{Name: "cache", Status: model.HealthStatusOK, Breaker: "closed"},
+			},
+			wantStatus: model.HealthStatusDegraded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reporters []adapters.HealthReporter
+			for _, component := range tt.components {
+				reporter := adapters.NewMockHealthReporter(ctrl)
+				reporter.EXPECT().Health().Return(component)
+				reporters = append(reporters, reporter)
+			}
+			h := NewHandler(nil, nil, WithHealthReporters(reporters...))
+			ctx, rec := newEchoContext(http.MethodGet, "/health", nil, nil)
+			serve(ctx, h.HandleHealth)
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("expected %d, got %d", http.StatusOK, rec.Code)
+			}
+			var got model.HealthResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode the response: %v", err)
+			}
+			if got.Status != tt.wantStatus || len(got.Components) != len(tt.components) {
+				t.Errorf("expected status %s with %d components, got %+v", tt.wantStatus, len(tt.components), got)
+			}
+		})
+	}
+}
+
+func TestHandler_HandleLiveness(t *testing.T) {
+	h := NewHandler(nil, nil)
+	ctx, rec := newEchoContext(http.MethodGet, "/healthz", nil, nil)
+	serve(ctx, h.HandleLiveness)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestHandler_HandleReadiness(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name           string
+		components     []model.ComponentHealth
+		draining       bool
+		wantStatusCode int
+		wantStatus     model.HealthStatus
+		wantComponents []string
+	}{
+		{
+			name: "dependencies ready",
+			components: []model.ComponentHealth{
+				{Name: "stats-redis", Status: model.HealthStatusOK},
+				{Name: "cache-redis", Status: model.HealthStatusOK},
+			},
+			wantStatusCode: http.StatusOK,
+			wantStatus:     model.HealthStatusOK,
+			wantComponents: []string{"stats-redis", "cache-redis"},
+		},
+		{
+			name: "redis unavailable",
+			components: []model.ComponentHealth{
+				{Name: "stats-redis", Status: model.HealthStatusUnavailable, Error: "connection refused"},
+				{Name: "cache-redis", Status: model.HealthStatusOK},
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantStatus:     model.HealthStatusUnavailable,
+			wantComponents: []string{"stats-redis", "cache-redis"},
+		},
+		{
+			name:           "shutting down",
+			components:     []model.ComponentHealth{{Name: "stats-memory", Status: model.HealthStatusOK}},
+			draining:       true,
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantStatus:     model.HealthStatusUnavailable,
+			wantComponents: []string{"server"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var checkers []adapters.ReadinessChecker
+			for _, component := range tt.components {
+				checker := adapters.NewMockReadinessChecker(ctrl)
+				checker.EXPECT().CheckReadiness(gomock.Any()).Return(component).MaxTimes(1)
+				checkers = append(checkers, checker)
+			}
+			h := NewHandler(nil, nil, WithReadinessCheckers(checkers...))
+			if tt.draining {
+				h.Drain()
+			}
+			ctx, rec := newEchoContext(http.MethodGet, "/readyz", nil, nil)
+			serve(ctx, h.HandleReadiness)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("expected %d, got %d", tt.wantStatusCode, rec.Code)
+			}
+			var got model.HealthResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode the response: %v", err)
+			}
+			var names []string
+			for _, component := range got.Components {
+				names = append(names, component.Name)
+			}
+			if got.Status != tt.wantStatus || !reflect.DeepEqual(names, tt.wantComponents) {
+				t.Errorf("expected status %s with %v, got %+v", tt.wantStatus, tt.wantComponents, got)
+			}
 		})
 	}
 }
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/logging"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+// requestIDPattern matches the request IDs given by the callers which are kept, other ones are replaced
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// probeRoutes are the routes whose requests are logged at the debug level, as they are polled
+var probeRoutes = map[string]bool{"/health": true, "/healthz": true, "/readyz": true, "/metrics": true}
+
+// requestIDMiddleware returns the middleware identifying each request by the X-Request-ID header of the caller,
+// or by a new random ID. The ID is added to the request context and sent back in the response header.
+func requestIDMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			id := req.Header.Get(echo.HeaderXRequestID)
+			if !requestIDPattern.MatchString(id) {
+				id = newRequestID()
+			}
+			ctx.Response().Header().Set(echo.HeaderXRequestID, id)
+			ctx.SetRequest(req.WithContext(logging.ContextWithRequestID(req.Context(), id)))
+			return next(ctx)
+		}
+	}
+}
+
+// newRequestID returns a random request ID of 32 hex digits
+func newRequestID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// accessLogMiddleware returns the middleware logging each request once served, with the attributes added by
+// the handlers and the services. The server errors are logged at the error level, the client ones at warn.
+func accessLogMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			start := time.Now()
+			ctx.SetRequest(ctx.Request().WithContext(logging.ContextWithAccessLog(ctx.Request().Context())))
+
+			err := next(ctx)
+			if err != nil {
+				ctx.Error(err)
+			}
+
+			req, res := ctx.Request(), ctx.Response()
+			route := ctx.Path()
+			if route == "" || isNotFound(err) {
+				route = "unmatched"
+			}
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("route", route),
+				slog.String("path", req.URL.Path),
+				slog.Int("status", res.Status),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.Int64("bytes_out", res.Size),
+				slog.String("remote_ip", ctx.RealIP()),
+			}
+			if apiKey, ok := model.APIKeyFromContext(req.Context()); ok {
+				attrs = append(attrs, slog.String("api_key", apiKey.Name))
+			}
+			attrs = append(attrs, logging.AccessAttrs(req.Context())...)
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+
+			slog.LogAttrs(req.Context(), accessLogLevel(route, res.Status), "HTTP request", attrs...)
+			return nil
+		}
+	}
+}
+
+// accessLogLevel returns the level of the access log of a request to route answered with status
+func accessLogLevel(route string, status int) slog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return slog.LevelError
+	case status >= http.StatusBadRequest:
+		return slog.LevelWarn
+	case probeRoutes[route]:
+		return slog.LevelDebug
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// isNotFound reports whether err is the error of a request to an unknown route
+func isNotFound(err error) bool {
+	var httpErr *echo.HTTPError
+	return errors.As(err, &httpErr) && httpErr.Code == http.StatusNotFound
+}
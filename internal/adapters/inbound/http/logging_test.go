@@ -0,0 +1,148 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/logging"
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/metrics"
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRouter_AccessLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name          string
+		opts          []Option
+		method        string
+		path          string
+		body          string
+		requestID     string
+		mockService   func(*adapters.MockFizzBuzzService)
+		wantRequestID string
+		wantLog       map[string]any
+	}{
+		{
+			name:          "request ID of the caller",
+			method:        http.MethodPost,
+			path:          "/fizzbuzz",
+			body:          `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`,
+			requestID:     "caller-42",
+			wantRequestID: "caller-42",
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().GenerateFizzBuzz(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, request model.FizzBuzzRequest) (string, error) {
+						logging.AddAccessAttrs(ctx, slog.Int("limit", request.Limit), slog.Bool("cache_hit", true))
+						return "1,2,Fizz", nil
+					})
+			},
+			wantLog: map[string]any{
+				"level": "INFO", "method": "POST", "route": "/fizzbuzz", "status": float64(200),
+				"limit": float64(15), "cache_hit": true,
+			},
+		},
+		{
+			name:      "service error",
+			method:    http.MethodPost,
+			path:      "/fizzbuzz",
+			body:      `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`,
+			requestID: "caller-43",
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().GenerateFizzBuzz(gomock.Any(), gomock.Any()).Return("", context.Canceled)
+			},
+			wantRequestID: "caller-43",
+			wantLog:       map[string]any{"level": "ERROR", "status": float64(500), "error": "Failed to generate FizzBuzz response: context canceled"},
+		},
+		{
+			name:      "client error",
+			method:    http.MethodPost,
+			path:      "/fizzbuzz",
+			body:      `{"int1":`,
+			requestID: "caller-44",
+			wantLog: map[string]any{
+				"level": "WARN", "status": float64(400), "error": "unexpected EOF",
+			},
+		},
+		{
+			name:      "client error with metrics",
+			opts:      []Option{WithMetrics(metrics.NewPrometheus())},
+			method:    http.MethodPost,
+			path:      "/fizzbuzz",
+			body:      `{"int1":`,
+			requestID: "caller-45",
+			wantLog: map[string]any{
+				"level": "WARN", "status": float64(400), "error": "unexpected EOF",
+			},
+		},
+		{
+			name:          "invalid request ID replaced",
+			method:        http.MethodGet,
+			path:          "/unknown",
+			requestID:     "bad id\n",
+			wantRequestID: "",
+			wantLog:       map[string]any{"level": "WARN", "route": "unmatched", "status": float64(404)},
+		},
+		{
+			name:    "probes logged at the debug level",
+			method:  http.MethodGet,
+			path:    "/healthz",
+			wantLog: map[string]any{"level": "DEBUG", "route": "/healthz", "status": float64(200)},
+		},
+	}
+
+	defaultLogger := slog.Default()
+	defer slog.SetDefault(defaultLogger)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			slog.SetDefault(logging.New(&buf, slog.LevelDebug))
+
+			mockFizzBuzz := adapters.NewMockFizzBuzzService(ctrl)
+			if tt.mockService != nil {
+				tt.mockService(mockFizzBuzz)
+			}
+			router := NewRouter(context.Background(), tt.opts...)
+			router.RegisterRoutes(NewHandler(mockFizzBuzz, nil))
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.requestID != "" {
+				req.Header.Set(echo.HeaderXRequestID, tt.requestID)
+			}
+			rec := httptest.NewRecorder()
+			router.GetApp().ServeHTTP(rec, req)
+
+			requestID := rec.Header().Get(echo.HeaderXRequestID)
+			if tt.wantRequestID != "" && requestID != tt.wantRequestID {
+				t.Errorf("expected the request ID %q, got %q", tt.wantRequestID, requestID)
+			}
+			if !requestIDPattern.MatchString(requestID) {
+				t.Errorf("invalid request ID %q", requestID)
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("expected a single JSON access log, got %q: %v", buf.String(), err)
+			}
+			if got["request_id"] != requestID {
+				t.Errorf("expected the request ID %q in the access log, got %v", requestID, got["request_id"])
+			}
+			for key, want := range tt.wantLog {
+				if got[key] != want {
+					t.Errorf("expected %s %v in the access log, got %v", key, want, got[key])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+// corsMaxAge is the time, in seconds, the browsers may cache the answer of a preflight request
+const corsMaxAge = 600
+
+// recoverMiddleware returns the middleware answering a 500 with a problem when a handler panics,
+// instead of dropping the connection. The panic and its stack are logged.
+func recoverMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) (err error) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				// The server aborts the response on its own
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+				slog.ErrorContext(ctx.Request().Context(), "Panic serving the request",
+					"panic", recovered, "stack", string(debug.Stack()))
+				err = model.ErrInternal
+			}()
+			return next(ctx)
+		}
+	}
+}
+
+// corsMiddleware returns the middleware allowing the browsers of origins to call the API,
+// any origin being allowed by "*"
+func corsMiddleware(origins []string) echo.MiddlewareFunc {
+	return middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins: origins,
+		AllowMethods: []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodDelete},
+		AllowHeaders: []string{
+			echo.HeaderAuthorization, echo.HeaderContentType, echo.HeaderAccept, HeaderAPIKey, echo.HeaderXRequestID,
+			headerIfNoneMatch,
+		},
+		ExposeHeaders: []string{
+			headerRateLimitLimit, headerRateLimitRemaining, headerRateLimitReset, echo.HeaderRetryAfter,
+			echo.HeaderXRequestID, headerETag,
+		},
+		MaxAge: corsMaxAge,
+	})
+}
+
+// secureHeadersMiddleware returns the middleware setting the security headers of the responses. The API only
+// serves JSON and text, so no content may be loaded or framed. HSTS is sent over TLS when hstsMaxAge is set.
+func secureHeadersMiddleware(hstsMaxAge int) echo.MiddlewareFunc {
+	return middleware.SecureWithConfig(middleware.SecureConfig{
+		XSSProtection:         "0",
+		ContentTypeNosniff:    "nosniff",
+		XFrameOptions:         "DENY",
+		HSTSMaxAge:            hstsMaxAge,
+		ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+		ReferrerPolicy:        "no-referrer",
+	})
+}
+
+// bodyLimitMiddleware returns the middleware refusing the request bodies larger than limit bytes with a 413,
+// either from their Content-Length or once limit bytes are read
+func bodyLimitMiddleware(limit int64) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			if req.ContentLength > limit {
+				return model.ErrPayloadTooLarge
+			}
+			if req.Body != nil {
+				req.Body = http.MaxBytesReader(ctx.Response(), req.Body, limit)
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// requestTimeoutMiddleware returns the middleware cancelling the context of the requests after timeout,
+// the requests which did not answer yet get a 503
+func requestTimeoutMiddleware(timeout time.Duration) echo.MiddlewareFunc {
+	return middleware.ContextTimeoutWithConfig(middleware.ContextTimeoutConfig{
+		Timeout: timeout,
+		ErrorHandler: func(err error, ctx echo.Context) error {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return model.ErrRequestTimeout
+			}
+			return err
+		},
+	})
+}
@@ -0,0 +1,198 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRouter_Middleware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	body := `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`
+	tests := []struct {
+		name           string
+		opts           []Option
+		method         string
+		path           string
+		body           string
+		chunked        bool
+		headers        map[string]string
+		mockService    func(*adapters.MockFizzBuzzService)
+		wantStatusCode int
+		wantCode       string
+		wantHeaders    map[string]string
+	}{
+		{
+			name:           "CORS preflight of an allowed origin",
+			opts:           []Option{WithCORS("https://app.example.com")},
+			method:         http.MethodOptions,
+			path:           "/fizzbuzz",
+			headers:        map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "POST"},
+			wantStatusCode: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Headers": "Authorization,Content-Type,Accept,X-API-Key,X-Request-Id,If-None-Match",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name:           "CORS preflight of another origin",
+			opts:           []Option{WithCORS("https://app.example.com")},
+			method:         http.MethodOptions,
+			path:           "/fizzbuzz",
+			headers:        map[string]string{"Origin": "https://evil.example.com", "Access-Control-Request-Method": "POST"},
+			wantStatusCode: http.StatusNoContent,
+			wantHeaders:    map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:           "security headers",
+			opts:           []Option{WithSecureHeaders(0)},
+			method:         http.MethodGet,
+			path:           "/healthz",
+			wantStatusCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"X-Content-Type-Options":    "nosniff",
+				"X-Frame-Options":           "DENY",
+				"Content-Security-Policy":   "default-src 'none'; frame-ancestors 'none'",
+				"Referrer-Policy":           "no-referrer",
+				"Strict-Transport-Security": "",
+			},
+		},
+		{
+			name:           "HSTS behind a TLS proxy",
+			opts:           []Option{WithSecureHeaders(31536000)},
+			method:         http.MethodGet,
+			path:           "/healthz",
+			headers:        map[string]string{"X-Forwarded-Proto": "https"},
+			wantStatusCode: http.StatusOK,
+			wantHeaders:    map[string]string{"Strict-Transport-Security": "max-age=31536000; includeSubdomains"},
+		},
+		{
+			name:           "body larger than its Content-Length allows",
+			opts:           []Option{WithBodyLimit(16)},
+			method:         http.MethodPost,
+			path:           "/fizzbuzz",
+			body:           body,
+			wantStatusCode: http.StatusRequestEntityTooLarge,
+			wantCode:       model.ErrPayloadTooLarge.Code,
+		},
+		{
+			name:           "chunked body larger than the limit",
+			opts:           []Option{WithBodyLimit(16)},
+			method:         http.MethodPost,
+			path:           "/fizzbuzz",
+			body:           body,
+			chunked:        true,
+			wantStatusCode: http.StatusRequestEntityTooLarge,
+			wantCode:       model.ErrPayloadTooLarge.Code,
+		},
+		{
+			name:           "chunked body peeked by the rate limiter",
+			opts:           []Option{WithBodyLimit(16), WithRateLimit(allowAll{}), WithRateLimitCostUnit(10)},
+			method:         http.MethodPost,
+			path:           "/fizzbuzz",
+			body:           body,
+			chunked:        true,
+			wantStatusCode: http.StatusRequestEntityTooLarge,
+			wantCode:       model.ErrPayloadTooLarge.Code,
+		},
+		{
+			name:   "body within the limit",
+			opts:   []Option{WithBodyLimit(1024)},
+			method: http.MethodPost,
+			path:   "/fizzbuzz",
+			body:   body,
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().GenerateFizzBuzz(gomock.Any(), gomock.Any()).Return("1,2,Fizz", nil)
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:   "request timeout",
+			opts:   []Option{WithRequestTimeout(10 * time.Millisecond)},
+			method: http.MethodPost,
+			path:   "/fizzbuzz",
+			body:   body,
+			mockService: func(m *adapters.MockFizzBuzzService) {
+				m.EXPECT().GenerateFizzBuzz(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, _ model.FizzBuzzRequest) (string, error) {
+						<-ctx.Done()
+						return "", ctx.Err()
+					})
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantCode:       model.ErrRequestTimeout.Code,
+		},
+		{
+			name:           "panic recovered",
+			method:         http.MethodGet,
+			path:           "/panic",
+			wantStatusCode: http.StatusInternalServerError,
+			wantCode:       model.ErrInternal.Code,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFizzBuzz := adapters.NewMockFizzBuzzService(ctrl)
+			if tt.mockService != nil {
+				tt.mockService(mockFizzBuzz)
+			}
+			router := NewRouter(context.Background(), tt.opts...)
+			router.RegisterRoutes(NewHandler(mockFizzBuzz, nil))
+			router.GetApp().GET("/panic", func(echo.Context) error { panic("boom") })
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.chunked {
+				req = httptest.NewRequest(tt.method, tt.path, iotest.HalfReader(strings.NewReader(tt.body)))
+				req.ContentLength = -1
+			}
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			for header, value := range tt.headers {
+				req.Header.Set(header, value)
+			}
+			rec := httptest.NewRecorder()
+			router.GetApp().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("expected %d, got %d: %s", tt.wantStatusCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantCode != "" && !strings.Contains(rec.Body.String(), `"code":"`+tt.wantCode+`"`) {
+				t.Errorf("expected the code %q, got %s", tt.wantCode, rec.Body.String())
+			}
+			for header, want := range tt.wantHeaders {
+				if got := rec.Header().Get(header); got != want {
+					t.Errorf("expected the header %s %q, got %q", header, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWithServerTimeouts(t *testing.T) {
+	router := NewRouter(context.Background(), WithServerTimeouts(time.Second, 2*time.Second, 3*time.Second))
+	server := router.GetApp().Server
+	if server.ReadTimeout != time.Second || server.ReadHeaderTimeout != time.Second ||
+		server.WriteTimeout != 2*time.Second || server.IdleTimeout != 3*time.Second {
+		t.Errorf("unexpected server timeouts: read %v, read header %v, write %v, idle %v",
+			server.ReadTimeout, server.ReadHeaderTimeout, server.WriteTimeout, server.IdleTimeout)
+	}
+}
+
+// allowAll is a rate limiter letting every request through
+type allowAll struct{}
+
+func (allowAll) Take(context.Context, string, int) (model.RateLimit, error) {
+	return model.RateLimit{Allowed: true}, nil
+}
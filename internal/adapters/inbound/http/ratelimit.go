@@ -0,0 +1,164 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+// RateLimitKey selects the bucket the requests are taken from
+type RateLimitKey string
+
+const (
+	// RateLimitKeyIP takes the requests from a bucket per client IP
+	RateLimitKeyIP RateLimitKey = "ip"
+	// RateLimitKeyAPIKey takes the requests authenticated with an API key from a bucket per key,
+	// and the other requests from a bucket per client IP
+	RateLimitKeyAPIKey RateLimitKey = "api-key"
+
+	// HeaderAPIKey is the header holding the API key of a client
+	HeaderAPIKey = "X-API-Key"
+
+	// maxFizzBuzzLimit is the largest limit of a valid FizzBuzz request
+	maxFizzBuzzLimit = 500000
+	// defaultBatchMaxWork is the largest sum of the limits of a batch by default
+	defaultBatchMaxWork = 1000000
+
+	headerRateLimitLimit     = "RateLimit-Limit"
+	headerRateLimitRemaining = "RateLimit-Remaining"
+	headerRateLimitReset     = "RateLimit-Reset"
+)
+
+// rateLimitMiddleware returns the middleware taking cost tokens from the bucket of the client of each request.
+// The requests are refused with a 429 once the bucket is empty, and let through when the limiter fails.
+func (r *Router) rateLimitMiddleware(cost func(echo.Context) int) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			limit, err := r.rateLimiter.Take(ctx.Request().Context(), r.rateLimitKey(ctx), cost(ctx))
+			if err != nil {
+				slog.WarnContext(ctx.Request().Context(), "Rate limiter unavailable, request let through", "error", err)
+				return next(ctx)
+			}
+
+			header := ctx.Response().Header()
+			header.Set(headerRateLimitLimit, strconv.Itoa(limit.Limit))
+			header.Set(headerRateLimitRemaining, strconv.Itoa(limit.Remaining))
+			header.Set(headerRateLimitReset, seconds(limit.Reset))
+			if !limit.Allowed {
+				header.Set(echo.HeaderRetryAfter, seconds(limit.RetryAfter))
+				return model.ErrRateLimited
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// rateLimitKey returns the bucket of the client of a request. Only an authenticated API key identifies
+// a client, the X-API-Key header is chosen by the client which would get a new bucket with every value.
+func (r *Router) rateLimitKey(ctx echo.Context) string {
+	if r.rateLimitKeyBy != RateLimitKeyAPIKey {
+		return "ip:" + ctx.RealIP()
+	}
+	if apiKey, ok := model.APIKeyFromContext(ctx.Request().Context()); ok {
+		return "client:" + apiKey.Name
+	}
+	return "ip:" + ctx.RealIP()
+}
+
+// fizzBuzzCost returns the cost of a FizzBuzz request, a token per cost unit of its limit. The request is
+// bound as the handler binds it, from the query of a GET or else from the JSON body, which is peeked and left
+// for the handler. A body which cannot be bound as JSON costs as much as the largest limit.
+func (r *Router) fizzBuzzCost(ctx echo.Context) int {
+	if r.rateLimitCostUnit <= 0 {
+		return 1
+	}
+
+	var request model.FizzBuzzRequest
+	req := ctx.Request()
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		if err := (&echo.DefaultBinder{}).BindQueryParams(ctx, &request); err != nil {
+			return r.limitCost(maxFizzBuzzLimit)
+		}
+		return r.limitCost(request.Limit)
+	}
+
+	body := peekBody(req)
+	if len(body) == 0 {
+		return r.limitCost(0)
+	}
+	if !strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) ||
+		json.Unmarshal(body, &request) != nil {
+		return r.limitCost(maxFizzBuzzLimit)
+	}
+	return r.limitCost(request.Limit)
+}
+
+// fizzBuzzBatchCost returns the cost of a batch of FizzBuzz requests, the cost of the sum of their limits.
+// A body which cannot be bound as JSON costs as much as the largest batch.
+func (r *Router) fizzBuzzBatchCost(ctx echo.Context) int {
+	if r.rateLimitCostUnit <= 0 {
+		return 1
+	}
+
+	req := ctx.Request()
+	body := peekBody(req)
+	if len(body) == 0 {
+		return r.limitCost(0)
+	}
+	var requests []model.FizzBuzzRequest
+	if !strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) ||
+		json.Unmarshal(body, &requests) != nil {
+		return r.limitCost(r.rateLimitBatchMaxWork)
+	}
+	work := 0
+	for _, request := range requests {
+		work += max(request.Limit, 0)
+	}
+	return r.limitCost(work)
+}
+
+// limitCost returns the cost of generating limit terms, a token per started cost unit
+func (r *Router) limitCost(limit int) int {
+	return (limit + r.rateLimitCostUnit - 1) / r.rateLimitCostUnit
+}
+
+// peekBody returns the body of req, which is left to be read again by the handler. It returns nil when
+// the body could not be read fully.
+func peekBody(req *http.Request) []byte {
+	if req.Body == nil {
+		return nil
+	}
+	// The body is read again from the peeked bytes, then from the original body, which returns
+	// the same error when it could not be read fully
+	body, err := io.ReadAll(req.Body)
+	req.Body = readCloser{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+// readCloser reads from Reader and closes Closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// requestCost is the cost of the requests which are not weighted
+func requestCost(echo.Context) int {
+	return 1
+}
+
+// seconds formats a duration as a number of seconds, rounded up
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
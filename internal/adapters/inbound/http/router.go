@@ -3,27 +3,178 @@ package http
 import (
 	"context"
 	"net"
+	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
 )
 
 type Router struct {
-	app     *echo.Echo
-	handler *Handler
+	app        *echo.Echo
+	handler    *Handler
+	adminToken string
+	metrics    MetricsExporter
+	apiKeys    adapters.APIKeyStore
+
+	rateLimiter       adapters.RateLimiter
+	rateLimitCostUnit int
+	rateLimitKeyBy    RateLimitKey
+	// rateLimitBatchMaxWork is the sum of the limits of the largest batch, the cost of the unreadable ones
+	rateLimitBatchMaxWork int
+
+	corsOrigins    []string
+	secureHeaders  bool
+	hstsMaxAge     int
+	bodyLimit      int64
+	requestTimeout time.Duration
+}
+
+// MetricsExporter records the HTTP requests and exposes the metrics of the service
+type MetricsExporter interface {
+	// Middleware returns the middleware recording the HTTP requests
+	Middleware() echo.MiddlewareFunc
+	// Handler returns the HTTP handler exposing the metrics
+	Handler() http.Handler
+}
+
+type Option func(*Router)
+
+// WithAdminToken enables the admin routes, authenticated with the given bearer token
+func WithAdminToken(token string) Option {
+	return func(r *Router) {
+		r.adminToken = token
+	}
+}
+
+// WithAPIKeys requires an API key from store on the FizzBuzz and the statistics routes,
+// the keys granted the admin scope are allowed on the admin routes as well
+func WithAPIKeys(store adapters.APIKeyStore) Option {
+	return func(r *Router) {
+		r.apiKeys = store
+	}
+}
+
+// WithMetrics records the HTTP requests and exposes the metrics on /metrics
+func WithMetrics(exporter MetricsExporter) Option {
+	return func(r *Router) {
+		r.metrics = exporter
+	}
+}
+
+// WithRateLimit limits the requests of each client to the tokens of its bucket in limiter
+func WithRateLimit(limiter adapters.RateLimiter) Option {
+	return func(r *Router) {
+		r.rateLimiter = limiter
+	}
+}
+
+// WithRateLimitCostUnit weights the FizzBuzz requests, taking a token per unit of their limit.
+// Every request takes a single token when unit is 0.
+func WithRateLimitCostUnit(unit int) Option {
+	return func(r *Router) {
+		r.rateLimitCostUnit = unit
+	}
+}
+
+// WithRateLimitBatchMaxWork sets the largest sum of the limits of a batch, the batches which cannot be read
+// are charged as much
+func WithRateLimitBatchMaxWork(work int) Option {
+	return func(r *Router) {
+		if work > 0 {
+			r.rateLimitBatchMaxWork = work
+		}
+	}
+}
+
+// WithRateLimitKey selects the bucket the requests of a client are taken from, by IP by default
+func WithRateLimitKey(key RateLimitKey) Option {
+	return func(r *Router) {
+		r.rateLimitKeyBy = key
+	}
+}
+
+// WithTrustedProxies takes the client IP from the X-Forwarded-For header of the requests sent by
+// the proxies of ranges. Without proxies the client IP is the peer address, as the header is
+// set by the clients.
+func WithTrustedProxies(ranges ...*net.IPNet) Option {
+	return func(r *Router) {
+		if len(ranges) == 0 {
+			return
+		}
+		trust := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+		for _, ipRange := range ranges {
+			trust = append(trust, echo.TrustIPRange(ipRange))
+		}
+		r.app.IPExtractor = echo.ExtractIPFromXFFHeader(trust...)
+	}
+}
+
+// WithCORS allows the browsers of origins to call the API, any origin being allowed by "*".
+// Cross-origin requests are not allowed when origins is empty.
+func WithCORS(origins ...string) Option {
+	return func(r *Router) {
+		r.corsOrigins = origins
+	}
+}
+
+// WithSecureHeaders sets the security headers of the responses, with HSTS over TLS when hstsMaxAge,
+// in seconds, is set
+func WithSecureHeaders(hstsMaxAge int) Option {
+	return func(r *Router) {
+		r.secureHeaders = true
+		r.hstsMaxAge = hstsMaxAge
+	}
+}
+
+// WithBodyLimit refuses the request bodies larger than limit bytes, the bodies are not limited when limit is 0
+func WithBodyLimit(limit int64) Option {
+	return func(r *Router) {
+		r.bodyLimit = limit
+	}
+}
+
+// WithRequestTimeout cancels the requests which are not served after timeout, they are not cancelled when
+// timeout is 0
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(r *Router) {
+		r.requestTimeout = timeout
+	}
+}
+
+// WithServerTimeouts bounds the time the HTTP server waits to read a request, to write its response, and
+// for the next request of an idle connection. A timeout of 0 disables it.
+func WithServerTimeouts(read, write, idle time.Duration) Option {
+	return func(r *Router) {
+		r.app.Server.ReadTimeout = read
+		r.app.Server.ReadHeaderTimeout = read
+		r.app.Server.WriteTimeout = write
+		r.app.Server.IdleTimeout = idle
+	}
 }
 
-func NewRouter(ctx context.Context) *Router {
+func NewRouter(ctx context.Context, opts ...Option) *Router {
 
 	app := echo.New()
+	app.HideBanner = true
+	app.HidePort = true
 	app.Validator = NewValidator()
+	app.HTTPErrorHandler = handleError
+	app.IPExtractor = echo.ExtractIPDirect()
 	app.Server.BaseContext = func(_ net.Listener) context.Context {
 		return ctx
 	}
 
-	return &Router{
-		app: app,
+	router := &Router{
+		app:                   app,
+		rateLimitBatchMaxWork: defaultBatchMaxWork,
 	}
+	for _, opt := range opts {
+		opt(router)
+	}
+
+	return router
 }
 
 // GetApp returns the Echo application instance
@@ -36,10 +187,58 @@ func (r *Router) RegisterRoutes(handler *Handler) {
 
 	r.handler = handler
 
-	r.app.Use(middleware.Logger())
+	r.app.Use(requestIDMiddleware())
+	r.app.Use(accessLogMiddleware())
+	r.app.Use(tracingMiddleware())
+	if r.metrics != nil {
+		r.app.Use(r.metrics.Middleware())
+		r.app.GET("/metrics", echo.WrapHandler(r.metrics.Handler()))
+	}
+	// The panics are recovered within the tracing and the metrics middleware, so they record the 500
+	r.app.Use(recoverMiddleware())
+	if len(r.corsOrigins) > 0 {
+		r.app.Use(corsMiddleware(r.corsOrigins))
+	}
+	if r.secureHeaders {
+		r.app.Use(secureHeadersMiddleware(r.hstsMaxAge))
+	}
+	if r.bodyLimit > 0 {
+		r.app.Use(bodyLimitMiddleware(r.bodyLimit))
+	}
+	if r.requestTimeout > 0 {
+		r.app.Use(requestTimeoutMiddleware(r.requestTimeout))
+	}
+
+	// The probes and the metrics are neither authenticated nor rate limited. The clients are authenticated
+	// first, so their requests are taken from the bucket of their API key.
+	var fizzBuzzLimit, batchLimit, statsLimit []echo.MiddlewareFunc
+	if r.apiKeys != nil {
+		fizzBuzzLimit = append(fizzBuzzLimit, r.apiKeyAuth(model.ScopeFizzBuzzGenerate))
+		batchLimit = append(batchLimit, r.apiKeyAuth(model.ScopeFizzBuzzGenerate))
+		statsLimit = append(statsLimit, r.apiKeyAuth(model.ScopeStatsRead))
+	}
+	if r.rateLimiter != nil {
+		fizzBuzzLimit = append(fizzBuzzLimit, r.rateLimitMiddleware(r.fizzBuzzCost))
+		batchLimit = append(batchLimit, r.rateLimitMiddleware(r.fizzBuzzBatchCost))
+		statsLimit = append(statsLimit, r.rateLimitMiddleware(requestCost))
+	}
+
+	r.app.POST("/fizzbuzz", handler.HandleFizzBuzzRequest, fizzBuzzLimit...)
+	r.app.GET("/fizzbuzz", handler.HandleGetFizzBuzz, fizzBuzzLimit...)
+	r.app.POST("/fizzbuzz/batch", handler.HandleFizzBuzzBatch, batchLimit...)
+	r.app.GET("/stats", handler.HandleGetStats, statsLimit...)
+	r.app.GET("/stats/top", handler.HandleGetTopStats, statsLimit...)
+	r.app.GET("/stats/requests", handler.HandleListStats, statsLimit...)
+	r.app.GET("/health", handler.HandleHealth)
+	r.app.GET("/healthz", handler.HandleLiveness)
+	r.app.GET("/readyz", handler.HandleReadiness)
 
-	r.app.POST("/fizzbuzz", handler.HandleFizzBuzzRequest)
-	r.app.GET("/stats", handler.HandleGetStats)
+	if r.adminToken != "" || r.apiKeys != nil {
+		admin := r.app.Group("/admin", r.adminAuth())
+		admin.DELETE("/stats", handler.HandleResetStats)
+		admin.DELETE("/stats/requests", handler.HandleRemoveStatsRequest)
+		admin.DELETE("/cache", handler.HandleFlushCache)
+	}
 }
 
 func (r *Router) GetHandler() *Handler {
@@ -0,0 +1,675 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRouter_AdminRoutes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name           string
+		opts           []Option
+		method         string
+		path           string
+		body           string
+		authorization  string
+		mockService    func(*adapters.MockStatsService)
+		wantStatusCode int
+	}{
+		{
+			name:           "admin routes disabled without token",
+			method:         http.MethodDelete,
+			path:           "/admin/stats",
+			authorization:  "Bearer secret",
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "missing token",
+			opts:           []Option{WithAdminToken("secret")},
+			method:         http.MethodDelete,
+			path:           "/admin/stats",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "wrong token",
+			opts:           []Option{WithAdminToken("secret")},
+			method:         http.MethodDelete,
+			path:           "/admin/stats",
+			authorization:  "Bearer guess",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:          "reset stats",
+			opts:          []Option{WithAdminToken("secret")},
+			method:        http.MethodDelete,
+			path:          "/admin/stats",
+			authorization: "Bearer secret",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().ResetStats(gomock.Any()).Return(nil)
+			},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:          "remove one request",
+			opts:          []Option{WithAdminToken("secret")},
+			method:        http.MethodDelete,
+			path:          "/admin/stats/requests",
+			body:          `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`,
+			authorization: "Bearer secret",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().RemoveRequest(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantStatusCode: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStats := adapters.NewMockStatsService(ctrl)
+			if tt.mockService != nil {
+				tt.mockService(mockStats)
+			}
+			router := NewRouter(context.Background(), tt.opts...)
+			router.RegisterRoutes(NewHandler(nil, mockStats))
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.authorization != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			router.GetApp().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("expected %d, got %d", tt.wantStatusCode, rec.Code)
+			}
+		})
+	}
+}
+
+// stubMetrics counts the requests going through its middleware
+type stubMetrics struct {
+	requests int
+}
+
+func (m *stubMetrics) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			m.requests++
+			return next(ctx)
+		}
+	}
+}
+
+func (m *stubMetrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("fizzbuzz_http_requests_total 1\n"))
+	})
+}
+
+func TestRouter_Metrics(t *testing.T) {
+	tests := []struct {
+		name           string
+		metrics        *stubMetrics
+		wantStatusCode int
+	}{
+		{
+			name:           "metrics disabled",
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "metrics enabled",
+			metrics:        &stubMetrics{},
+			wantStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.metrics != nil {
+				opts = append(opts, WithMetrics(tt.metrics))
+			}
+			router := NewRouter(context.Background(), opts...)
+			router.RegisterRoutes(NewHandler(nil, nil))
+
+			rec := httptest.NewRecorder()
+			router.GetApp().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("expected %d, got %d", tt.wantStatusCode, rec.Code)
+			}
+			if tt.metrics != nil && tt.metrics.requests != 1 {
+				t.Errorf("expected the request recorded, got %d", tt.metrics.requests)
+			}
+		})
+	}
+}
+
+func TestRouter_Tracing(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	tests := []struct {
+		name         string
+		path         string
+		traceparent  string
+		wantSpan     string
+		wantTraceID  string
+		wantParentID string
+		wantStatus   int64
+	}{
+		{
+			name:       "new trace",
+			path:       "/health",
+			wantSpan:   "GET /health",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:         "trace continued from the traceparent header",
+			path:         "/health",
+			traceparent:  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantSpan:     "GET /health",
+			wantTraceID:  "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantParentID: "00f067aa0ba902b7",
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:       "unknown route",
+			path:       "/unknown",
+			wantSpan:   "GET unmatched",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			router := NewRouter(context.Background())
+			router.RegisterRoutes(NewHandler(nil, nil))
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			router.GetApp().ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := recorder.Ended()
+			if len(spans) != 1 || spans[0].Name() != tt.wantSpan {
+				t.Fatalf("expected the span %q, got %v", tt.wantSpan, spans)
+			}
+			span := spans[0]
+			if span.SpanKind() != trace.SpanKindServer {
+				t.Errorf("expected a server span, got %v", span.SpanKind())
+			}
+			if tt.wantTraceID != "" && span.SpanContext().TraceID().String() != tt.wantTraceID {
+				t.Errorf("expected the trace %s, got %s", tt.wantTraceID, span.SpanContext().TraceID())
+			}
+			if tt.wantParentID != "" && (span.Parent().SpanID().String() != tt.wantParentID || !span.Parent().IsRemote()) {
+				t.Errorf("expected the remote parent %s, got %s", tt.wantParentID, span.Parent().SpanID())
+			}
+			if tt.wantParentID == "" && span.Parent().IsValid() {
+				t.Errorf("expected a root span, got the parent %s", span.Parent().SpanID())
+			}
+			for _, attr := range span.Attributes() {
+				if attr.Key == "http.response.status_code" && attr.Value.AsInt64() != tt.wantStatus {
+					t.Errorf("expected the status %d, got %d", tt.wantStatus, attr.Value.AsInt64())
+				}
+			}
+		})
+	}
+}
+
+func TestRouter_RateLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name           string
+		opts           []Option
+		method         string
+		path           string
+		body           string
+		contentType    string
+		forwardedFor   string
+		apiKey         string
+		wantKey        string
+		wantCost       int
+		limit          model.RateLimit
+		limitErr       error
+		wantStatusCode int
+		wantHeaders    map[string]string
+	}{
+		{
+			name:           "request allowed",
+			method:         http.MethodGet,
+			path:           "/stats/top",
+			wantKey:        "ip:192.0.2.1",
+			wantCost:       1,
+			limit:          model.RateLimit{Allowed: true, Limit: 100, Remaining: 99, Reset: 500 * time.Millisecond},
+			wantStatusCode: http.StatusOK,
+			wantHeaders:    map[string]string{"RateLimit-Limit": "100", "RateLimit-Remaining": "99", "RateLimit-Reset": "1"},
+		},
+		{
+			name:           "request refused",
+			method:         http.MethodPost,
+			path:           "/fizzbuzz",
+			body:           `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`,
+			wantKey:        "ip:192.0.2.1",
+			wantCost:       1,
+			limit:          model.RateLimit{Allowed: false, Limit: 100, Remaining: 0, Reset: 10 * time.Second, RetryAfter: 2100 * time.Millisecond},
+			wantStatusCode: http.StatusTooManyRequests,
+			wantHeaders:    map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": "10", "Retry-After": "3"},
+		},
+		{
+			name:           "cost weighted by the limit",
+			opts:           []Option{WithRateLimitCostUnit(1000)},
+			method:         http.MethodPost,
+			path:           "/fizzbuzz",
+			body:           `{"int1":3,"int2":5,"limit":250001,"str1":"Fizz","str2":"Buzz"}`,
+			wantKey:        "ip:192.0.2.1",
+			wantCost:       251,
+			limit:          model.RateLimit{Allowed: false, Limit: 1000, RetryAfter: time.Second},
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:           "cost weighted by the limit of the query",
+			opts:           []Option{WithRateLimitCostUnit(1000)},
+			method:         http.MethodGet,
+			path:           "/fizzbuzz?int1=3&int2=5&limit=2500&str1=Fizz&str2=Buzz",
+			wantKey:        "ip:192.0.2.1",
+			wantCost:       3,
+			limit:          model.RateLimit{Allowed: false, Limit: 1000, RetryAfter: time.Second},
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:           "form body costs the largest limit",
+			opts:           []Option{WithRateLimitCostUnit(1000)},
+			method:         http.MethodPost,
+			path:           "/fizzbuzz",
+			body:           "int1=3&int2=5&limit=500000&str1=Fizz&str2=Buzz",
+			contentType:    echo.MIMEApplicationForm,
+			wantKey:        "ip:192.0.2.1",
+			wantCost:       500,
+			limit:          model.RateLimit{Allowed: false, Limit: 1000, RetryAfter: time.Second},
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:           "malformed body costs the largest limit",
+			opts:           []Option{WithRateLimitCostUnit(1000)},
+			method:         http.MethodPost,
+			path:           "/fizzbuzz",
+			body:           `{"limit":"500000"}`,
+			wantKey:        "ip:192.0.2.1",
+			wantCost:       500,
+			limit:          model.RateLimit{Allowed: false, Limit: 1000, RetryAfter: time.Second},
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:           "query of a POST is not charged",
+			opts:           []Option{WithRateLimitCostUnit(1000)},
+			method:         http.MethodPost,
+			path:           "/fizzbuzz?limit=500000",
+			body:           `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`,
+			wantKey:        "ip:192.0.2.1",
+			wantCost:       1,
+			limit:          model.RateLimit{Allowed: false, Limit: 1000, RetryAfter: time.Second},
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:           "batch cost weighted by the sum of the limits",
+			opts:           []Option{WithRateLimitCostUnit(1000)},
+			method:         http.MethodPost,
+			path:           "/fizzbuzz/batch",
+			body:           `[{"int1":3,"int2":5,"limit":1500,"str1":"Fizz","str2":"Buzz"},{"int1":3,"int2":5,"limit":1000,"str1":"Fizz","str2":"Buzz"}]`,
+			wantKey:        "ip:192.0.2.1",
+			wantCost:       3,
+			limit:          model.RateLimit{Allowed: false, Limit: 1000, RetryAfter: time.Second},
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:           "XML batch costs the largest batch",
+			opts:           []Option{WithRateLimitCostUnit(1000)},
+			method:         http.MethodPost,
+			path:           "/fizzbuzz/batch",
+			body:           `<requests><request><int1>3</int1><int2>5</int2><limit>500000</limit><str1>Fizz</str1><str2>Buzz</str2></request></requests>`,
+			contentType:    echo.MIMEApplicationXML,
+			wantKey:        "ip:192.0.2.1",
+			wantCost:       1000,
+			limit:          model.RateLimit{Allowed: false, Limit: 1000, RetryAfter: time.Second},
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:           "malformed batch costs the configured largest batch",
+			opts:           []Option{WithRateLimitCostUnit(1000), WithRateLimitBatchMaxWork(20000)},
+			method:         http.MethodPost,
+			path:           "/fizzbuzz/batch",
+			body:           `[{"limit":"500000"}]`,
+			wantKey:        "ip:192.0.2.1",
+			wantCost:       20,
+			limit:          model.RateLimit{Allowed: false, Limit: 1000, RetryAfter: time.Second},
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:           "unauthenticated API key keyed by IP",
+			opts:           []Option{WithRateLimitKey(RateLimitKeyAPIKey)},
+			method:         http.MethodGet,
+			path:           "/stats/top",
+			apiKey:         "secret",
+			wantKey:        "ip:192.0.2.1",
+			wantCost:       1,
+			limit:          model.RateLimit{Allowed: false, Limit: 100, RetryAfter: time.Second},
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name: "bucket per authenticated API key",
+			opts: []Option{
+				WithRateLimitKey(RateLimitKeyAPIKey),
+				WithAPIKeys(stubAPIKeys{keys: map[string]model.APIKey{"secret": {Name: "acme", Scopes: []model.Scope{model.ScopeStatsRead}}}}),
+			},
+			method:         http.MethodGet,
+			path:           "/stats/top",
+			apiKey:         "secret",
+			wantKey:        "client:acme",
+			wantCost:       1,
+			limit:          model.RateLimit{Allowed: false, Limit: 100, RetryAfter: time.Second},
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:           "forwarded IP of a trusted proxy",
+			opts:           []Option{WithTrustedProxies(&net.IPNet{IP: net.IPv4(192, 0, 2, 0), Mask: net.CIDRMask(24, 32)})},
+			method:         http.MethodGet,
+			path:           "/stats/top",
+			forwardedFor:   "203.0.113.7",
+			wantKey:        "ip:203.0.113.7",
+			wantCost:       1,
+			limit:          model.RateLimit{Allowed: false, Limit: 100, RetryAfter: time.Second},
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:           "forwarded IP of an untrusted peer ignored",
+			opts:           []Option{WithTrustedProxies(&net.IPNet{IP: net.IPv4(198, 51, 100, 0), Mask: net.CIDRMask(24, 32)})},
+			method:         http.MethodGet,
+			path:           "/stats/top",
+			forwardedFor:   "203.0.113.7",
+			wantKey:        "ip:192.0.2.1",
+			wantCost:       1,
+			limit:          model.RateLimit{Allowed: false, Limit: 100, RetryAfter: time.Second},
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:           "limiter failure lets the request through",
+			method:         http.MethodGet,
+			path:           "/stats/top",
+			wantKey:        "ip:192.0.2.1",
+			wantCost:       1,
+			limitErr:       errors.New("redis down"),
+			wantStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := adapters.NewMockRateLimiter(ctrl)
+			limiter.EXPECT().Take(gomock.Any(), tt.wantKey, tt.wantCost).Return(tt.limit, tt.limitErr)
+			mockStats := adapters.NewMockStatsService(ctrl)
+			mockStats.EXPECT().GetTopRequests(gomock.Any(), gomock.Any()).Return(&model.StatsPage{}, nil).AnyTimes()
+			router := NewRouter(context.Background(), append(tt.opts, WithRateLimit(limiter))...)
+			router.RegisterRoutes(NewHandler(nil, mockStats))
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			contentType := echo.MIMEApplicationJSON
+			if tt.contentType != "" {
+				contentType = tt.contentType
+			}
+			req.Header.Set(echo.HeaderContentType, contentType)
+			if tt.apiKey != "" {
+				req.Header.Set(HeaderAPIKey, tt.apiKey)
+			}
+			if tt.forwardedFor != "" {
+				req.Header.Set(echo.HeaderXForwardedFor, tt.forwardedFor)
+			}
+			rec := httptest.NewRecorder()
+			router.GetApp().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("expected %d, got %d", tt.wantStatusCode, rec.Code)
+			}
+			for header, want := range tt.wantHeaders {
+				if got := rec.Header().Get(header); got != want {
+					t.Errorf("expected the header %s %q, got %q", header, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRouter_RateLimit_SpoofedForwardedFor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Without trusted proxies the forwarding headers are set by the client, every request takes from its peer bucket
+	limiter := adapters.NewMockRateLimiter(ctrl)
+	limiter.EXPECT().Take(gomock.Any(), "ip:192.0.2.1", 1).Return(model.RateLimit{Allowed: false, Limit: 100}, nil).Times(3)
+	router := NewRouter(context.Background(), WithRateLimit(limiter))
+	router.RegisterRoutes(NewHandler(nil, adapters.NewMockStatsService(ctrl)))
+
+	for _, forwardedFor := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+		req := httptest.NewRequest(http.MethodGet, "/stats/top", nil)
+		req.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+		req.Header.Set(echo.HeaderXRealIP, forwardedFor)
+		rec := httptest.NewRecorder()
+		router.GetApp().ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusTooManyRequests {
+			t.Errorf("expected %d with X-Forwarded-For %s, got %d", http.StatusTooManyRequests, forwardedFor, rec.Code)
+		}
+	}
+}
+
+// stubAPIKeys holds the API keys by key, failing every lookup when err is set
+type stubAPIKeys struct {
+	keys map[string]model.APIKey
+	err  error
+}
+
+func (s stubAPIKeys) GetAPIKey(_ context.Context, key string) (*model.APIKey, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if apiKey, found := s.keys[key]; found {
+		return &apiKey, nil
+	}
+	return nil, nil
+}
+
+func TestRouter_APIKeyAuth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	keys := stubAPIKeys{keys: map[string]model.APIKey{
+		"reader": {Name: "acme", Scopes: []model.Scope{model.ScopeStatsRead}},
+		"root":   {Name: "ops", Scopes: []model.Scope{model.ScopeAdmin}},
+	}}
+	stats := &model.StatsResult{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 2}
+	tests := []struct {
+		name           string
+		store          stubAPIKeys
+		method         string
+		path           string
+		header         string
+		value          string
+		mockService    func(*adapters.MockStatsService)
+		wantStatusCode int
+		wantCode       string
+	}{
+		{
+			name:           "missing key",
+			store:          keys,
+			method:         http.MethodGet,
+			path:           "/stats/top",
+			wantStatusCode: http.StatusUnauthorized,
+			wantCode:       model.ErrInvalidAPIKey.Code,
+		},
+		{
+			name:           "unknown key",
+			store:          keys,
+			method:         http.MethodGet,
+			path:           "/stats/top",
+			header:         HeaderAPIKey,
+			value:          "guess",
+			wantStatusCode: http.StatusUnauthorized,
+			wantCode:       model.ErrInvalidAPIKey.Code,
+		},
+		{
+			name:           "store unavailable",
+			store:          stubAPIKeys{err: errors.New("redis down")},
+			method:         http.MethodGet,
+			path:           "/stats/top",
+			header:         HeaderAPIKey,
+			value:          "reader",
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantCode:       model.ErrAuthUnavailable.Code,
+		},
+		{
+			name:           "missing scope",
+			store:          keys,
+			method:         http.MethodPost,
+			path:           "/fizzbuzz",
+			header:         HeaderAPIKey,
+			value:          "reader",
+			wantStatusCode: http.StatusForbidden,
+			wantCode:       "forbidden",
+		},
+		{
+			name:   "key in the X-API-Key header",
+			store:  keys,
+			method: http.MethodGet,
+			path:   "/stats/top",
+			header: HeaderAPIKey,
+			value:  "reader",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().GetTopRequests(gomock.Any(), gomock.Any()).Return(&model.StatsPage{}, nil)
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:   "key as a bearer token",
+			store:  keys,
+			method: http.MethodGet,
+			path:   "/stats/top",
+			header: echo.HeaderAuthorization,
+			value:  "Bearer reader",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().GetTopRequests(gomock.Any(), gomock.Any()).Return(&model.StatsPage{}, nil)
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "probes not authenticated",
+			store:          keys,
+			method:         http.MethodGet,
+			path:           "/healthz",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:   "statistics of the own key",
+			store:  keys,
+			method: http.MethodGet,
+			path:   "/stats?key=acme",
+			header: HeaderAPIKey,
+			value:  "reader",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().GetKeyStats(gomock.Any(), "acme").Return(stats, nil)
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "statistics of another key",
+			store:          keys,
+			method:         http.MethodGet,
+			path:           "/stats?key=ops",
+			header:         HeaderAPIKey,
+			value:          "reader",
+			wantStatusCode: http.StatusForbidden,
+			wantCode:       "forbidden",
+		},
+		{
+			name:   "statistics of another key read by an admin",
+			store:  keys,
+			method: http.MethodGet,
+			path:   "/stats?key=acme",
+			header: HeaderAPIKey,
+			value:  "root",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().GetKeyStats(gomock.Any(), "acme").Return(stats, nil)
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:   "admin routes with an admin key",
+			store:  keys,
+			method: http.MethodDelete,
+			path:   "/admin/stats",
+			header: echo.HeaderAuthorization,
+			value:  "Bearer root",
+			mockService: func(m *adapters.MockStatsService) {
+				m.EXPECT().ResetStats(gomock.Any()).Return(nil)
+			},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "admin routes without the admin scope",
+			store:          keys,
+			method:         http.MethodDelete,
+			path:           "/admin/stats",
+			header:         echo.HeaderAuthorization,
+			value:          "Bearer reader",
+			wantStatusCode: http.StatusForbidden,
+			wantCode:       "forbidden",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStats := adapters.NewMockStatsService(ctrl)
+			if tt.mockService != nil {
+				tt.mockService(mockStats)
+			}
+			router := NewRouter(context.Background(), WithAPIKeys(tt.store))
+			router.RegisterRoutes(NewHandler(nil, mockStats))
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			router.GetApp().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("expected %d, got %d: %s", tt.wantStatusCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantCode != "" && !strings.Contains(rec.Body.String(), `"code":"`+tt.wantCode+`"`) {
+				t.Errorf("expected the code %q, got %s", tt.wantCode, rec.Body.String())
+			}
+		})
+	}
+}
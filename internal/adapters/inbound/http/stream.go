@@ -0,0 +1,66 @@
+package http
+
+import (
+	"bufio"
+	"iter"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// streamBufferSize is the size of the buffer terms are written to before being sent
+	streamBufferSize = 32 * 1024
+	// streamFlushTerms is the number of terms written between two flushes of the response
+	streamFlushTerms = 4096
+)
+
+// writeFizzBuzzStream writes the terms with the encoder as a chunked body, flushing the
+// response while the terms are generated, so the sequence is never held in memory.
+// It stops early if the client goes away.
+func writeFizzBuzzStream(ctx echo.Context, encoder termEncoder, terms iter.Seq[string]) error {
+	res := ctx.Response()
+	res.Header().Set(echo.HeaderContentType, encoder.contentType)
+	res.WriteHeader(http.StatusOK)
+
+	w := bufio.NewWriterSize(res, streamBufferSize)
+	if _, err := w.WriteString(encoder.prefix); err != nil {
+		return err
+	}
+
+	done := ctx.Request().Context().Done()
+	i := 0
+	for term := range terms {
+		if i > 0 {
+			if _, err := w.WriteString(encoder.separator); err != nil {
+				return err
+			}
+		}
+		if err := encoder.writeTerm(w, term); err != nil {
+			return err
+		}
+		i++
+
+		if i%streamFlushTerms == 1 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			res.Flush()
+
+			select {
+			case <-done:
+				return ctx.Request().Context().Err()
+			default:
+			}
+		}
+	}
+
+	if _, err := w.WriteString(encoder.suffix); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
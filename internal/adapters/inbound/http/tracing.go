@@ -0,0 +1,56 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the spans of the HTTP server
+const tracerName = "github.com/niltonkummer/fizzbuzz-api/internal/adapters/inbound/http"
+
+// tracingMiddleware starts the server span of each request, continuing the trace of the caller
+// given by the W3C trace context headers
+func tracingMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			parent := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+			route := ctx.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			spanCtx, span := otel.Tracer(tracerName).Start(parent, fmt.Sprintf("%s %s", req.Method, route),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(req.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(req.URL.Path)))
+			defer span.End()
+			ctx.SetRequest(req.WithContext(spanCtx))
+
+			err := next(ctx)
+			if err != nil {
+				ctx.Error(err)
+			}
+
+			status := ctx.Response().Status
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+			if err != nil {
+				span.RecordError(err)
+			}
+			// The error is returned for the access log, the response is already written
+			return err
+		}
+	}
+}
@@ -1,11 +1,12 @@
 package http
 
 import (
-	"errors"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
 )
 
 type Validator struct {
@@ -23,34 +24,90 @@ func NewValidator() *Validator {
 
 		return name
 	})
+	validate.RegisterStructValidation(validateFizzBuzzRequest, model.FizzBuzzRequest{})
+	_ = validate.RegisterValidation("rfc3339", validateRFC3339)
 
 	return &Validator{
 		validator: validate,
 	}
 }
 
+// Validate validates i, the failed validations are reported as the fields of a model.ErrInvalidRequest
 func (cv *Validator) Validate(i interface{}) error {
 	if err := cv.validator.Struct(i); err != nil {
 		errs, ok := err.(validator.ValidationErrors)
 		if ok {
-			var errMessages []string
+			errMessages := make([]string, 0, len(errs))
+			fields := make([]model.FieldError, 0, len(errs))
 			for _, e := range errs {
-				fieldName := e.Field()
-				if e.Tag() == "required" {
-					errMessages = append(errMessages, fieldName+" is required")
-				} else if e.Tag() == "min" {
-					errMessages = append(errMessages, fieldName+" must be greater than "+e.Param())
-				} else if e.Tag() == "max" {
-					errMessages = append(errMessages, fieldName+" must be less than "+e.Param())
-				} else {
-					errMessages = append(errMessages, fieldName+" is invalid")
+				field := model.FieldError{
+					Field:   fieldPath(e),
+					Rule:    e.Tag(),
+					Param:   e.Param(),
+					Message: fieldMessage(e),
 				}
+				errMessages = append(errMessages, field.Message)
+				fields = append(fields, field)
 			}
 
-			return errors.New(
-				strings.Join(errMessages, ", "),
-			)
+			return model.ErrInvalidRequest.WithMessage(strings.Join(errMessages, ", ")).WithFields(fields...)
 		}
 	}
 	return nil
 }
+
+// fieldMessage returns the message of a failed validation
+func fieldMessage(e validator.FieldError) string {
+	fieldName := e.Field()
+	switch e.Tag() {
+	case "required":
+		return fieldName + " is required"
+	case "min":
+		return fieldName + " must be greater than " + e.Param()
+	case "max":
+		return fieldName + " must be less than " + e.Param()
+	case "oneof":
+		return fieldName + " must be one of " + e.Param()
+	case "rfc3339":
+		return fieldName + " must be a date in RFC 3339 format"
+	case "excluded_with":
+		return fieldName + " cannot be combined with " + e.Param()
+	default:
+		return fieldName + " is invalid"
+	}
+}
+
+// fieldPath returns the path of the field of a failed validation from the request, such as rules[1].divisor
+func fieldPath(e validator.FieldError) string {
+	namespace := e.Namespace()
+	if _, path, found := strings.Cut(namespace, "."); found {
+		return path
+	}
+	return namespace
+}
+
+// validateRFC3339 requires a date in RFC 3339 format, with or without fractional seconds
+func validateRFC3339(fl validator.FieldLevel) bool {
+	_, err := time.Parse(time.RFC3339Nano, fl.Field().String())
+	return err == nil
+}
+
+// validateFizzBuzzRequest requires the two-rule shorthand parameters unless the request
+// carries a rule list, in which case the shorthand parameters must be left empty
+func validateFizzBuzzRequest(sl validator.StructLevel) {
+	request := sl.Current().Interface().(model.FizzBuzzRequest)
+
+	if request.HasRules() {
+		if request.Int1 != 0 || request.Int2 != 0 || request.Str1 != "" || request.Str2 != "" {
+			sl.ReportError(request.Rules, "rules", "Rules", "excluded_with", "int1, int2, str1 and str2")
+		}
+		return
+	}
+
+	if request.Int1 < 1 {
+		sl.ReportError(request.Int1, "int1", "Int1", "min", "1")
+	}
+	if request.Int2 < 1 {
+		sl.ReportError(request.Int2, "int2", "Int2", "min", "1")
+	}
+}
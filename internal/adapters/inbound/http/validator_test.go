@@ -1,9 +1,12 @@
 package http
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
 )
 
 func TestValidator_Validate(t *testing.T) {
@@ -132,3 +135,80 @@ func TestValidator_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestValidator_ValidateFizzBuzzRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    model.FizzBuzzRequest
+		wantErr    string
+		wantFields []model.FieldError
+	}{
+		{
+			name:    "valid shorthand request",
+			request: model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"},
+		},
+		{
+			name:    "shorthand request without int1",
+			request: model.FizzBuzzRequest{Int1: 0, Int2: 1, Limit: 15, Str1: "Fizz", Str2: "Buzz"},
+			wantErr: "int1 must be greater than 1",
+			wantFields: []model.FieldError{
+				{Field: "int1", Rule: "min", Param: "1", Message: "int1 must be greater than 1"},
+			},
+		},
+		{
+			name: "valid rule list request",
+			request: model.FizzBuzzRequest{
+				Limit: 21,
+				Rules: []model.Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 5, Word: "Buzz"}, {Divisor: 7, Word: "Bazz"}},
+			},
+		},
+		{
+			name: "rule list with invalid divisor",
+			request: model.FizzBuzzRequest{
+				Limit: 21,
+				Rules: []model.Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 0, Word: "Buzz"}},
+			},
+			wantErr: "divisor must be greater than 1",
+			wantFields: []model.FieldError{
+				{Field: "rules[1].divisor", Rule: "min", Param: "1", Message: "divisor must be greater than 1"},
+			},
+		},
+		{
+			name: "rule list combined with shorthand",
+			request: model.FizzBuzzRequest{
+				Int1:  3,
+				Limit: 21,
+				Rules: []model.Rule{{Divisor: 3, Word: "Fizz"}},
+			},
+			wantErr: "rules cannot be combined with int1, int2, str1 and str2",
+			wantFields: []model.FieldError{{
+				Field:   "rules",
+				Rule:    "excluded_with",
+				Param:   "int1, int2, str1 and str2",
+				Message: "rules cannot be combined with int1, int2, str1 and str2",
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cv := NewValidator()
+			err := cv.Validate(tt.request)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+			var apiErr *model.Error
+			if !errors.As(err, &apiErr) || apiErr.Code != model.ErrInvalidRequest.Code {
+				t.Fatalf("Validate() error = %v, want a %s error", err, model.ErrInvalidRequest.Code)
+			}
+			if !reflect.DeepEqual(apiErr.Fields, tt.wantFields) {
+				t.Errorf("Validate() fields = %+v, want %+v", apiErr.Fields, tt.wantFields)
+			}
+		})
+	}
+}
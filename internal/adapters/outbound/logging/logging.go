@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New returns a logger writing the records of level and above to w as JSON lines. The records logged with
+// the context of a request have its request ID, and its trace ID when it is traced.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+// ParseLevel returns the level named level, debug, info, warn or error
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(level))
+	return l, err
+}
+
+// contextHandler adds the request and the trace IDs of the context to the records
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id, ok := RequestIDFromContext(ctx); ok {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type requestIDContextKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the ID of its request
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestIDFromContext returns the ID of the request of ctx, if any
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDContextKey{}).(string)
+	return id, ok
+}
+
+// accessLog holds the attributes added to the access log of a request while it is served
+type accessLog struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+type accessLogContextKey struct{}
+
+// ContextWithAccessLog returns a copy of ctx collecting the attributes of the access log of its request
+func ContextWithAccessLog(ctx context.Context) context.Context {
+	return context.WithValue(ctx, accessLogContextKey{}, &accessLog{})
+}
+
+// AddAccessAttrs adds attrs to the access log of the request of ctx, they are dropped when it has none
+func AddAccessAttrs(ctx context.Context, attrs ...slog.Attr) {
+	log, ok := ctx.Value(accessLogContextKey{}).(*accessLog)
+	if !ok {
+		return
+	}
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	log.attrs = append(log.attrs, attrs...)
+}
+
+// AccessAttrs returns the attributes added to the access log of the request of ctx
+func AccessAttrs(ctx context.Context) []slog.Attr {
+	log, ok := ctx.Value(accessLogContextKey{}).(*accessLog)
+	if !ok {
+		return nil
+	}
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	return append([]slog.Attr(nil), log.attrs...)
+}
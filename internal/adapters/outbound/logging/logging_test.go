@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNew(t *testing.T) {
+	traceID := trace.TraceID{1, 2, 3}
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: trace.SpanID{1}})
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want map[string]any
+	}{
+		{
+			name: "no request",
+			ctx:  context.Background(),
+			want: map[string]any{"level": "INFO", "msg": "hello", "component": "test"},
+		},
+		{
+			name: "request",
+			ctx:  ContextWithRequestID(context.Background(), "req-1"),
+			want: map[string]any{"level": "INFO", "msg": "hello", "component": "test", "request_id": "req-1"},
+		},
+		{
+			name: "traced request",
+			ctx:  trace.ContextWithSpanContext(ContextWithRequestID(context.Background(), "req-1"), spanCtx),
+			want: map[string]any{
+				"level": "INFO", "msg": "hello", "component": "test", "request_id": "req-1", "trace_id": traceID.String(),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			New(&buf, slog.LevelInfo).With("component", "test").InfoContext(tt.ctx, "hello")
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON record %q: %v", buf.String(), err)
+			}
+			delete(got, "time")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got record %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelWarn)
+	logger.Info("dropped")
+	if buf.Len() != 0 {
+		t.Errorf("expected the info record to be dropped, got %q", buf.String())
+	}
+	logger.Warn("kept")
+	if buf.Len() == 0 {
+		t.Error("expected the warn record to be written")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		level   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{level: "debug", want: slog.LevelDebug},
+		{level: "INFO", want: slog.LevelInfo},
+		{level: "warn", want: slog.LevelWarn},
+		{level: "error", want: slog.LevelError},
+		{level: "verbose", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			got, err := ParseLevel(tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddAccessAttrs(t *testing.T) {
+	// The attributes are dropped without an access log
+	AddAccessAttrs(context.Background(), slog.Int("limit", 15))
+	if got := AccessAttrs(context.Background()); got != nil {
+		t.Errorf("AccessAttrs() = %v, want nil", got)
+	}
+
+	ctx := ContextWithAccessLog(context.Background())
+	AddAccessAttrs(ctx, slog.Int("limit", 15))
+	AddAccessAttrs(ctx, slog.Bool("cache_hit", true))
+	want := []slog.Attr{slog.Int("limit", 15), slog.Bool("cache_hit", true)}
+	if got := AccessAttrs(ctx); !reflect.DeepEqual(got, want) {
+		t.Errorf("AccessAttrs() = %v, want %v", got, want)
+	}
+}
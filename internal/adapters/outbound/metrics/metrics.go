@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	_ adapters.Metrics = (*Prometheus)(nil)
+	_ adapters.Metrics = NoOp{}
+)
+
+const namespace = "fizzbuzz"
+
+// Prometheus records the metrics of the service in its own Prometheus registry
+type Prometheus struct {
+	registry *prometheus.Registry
+
+	httpRequests  *prometheus.CounterVec
+	httpDuration  *prometheus.HistogramVec
+	cacheLookups  *prometheus.CounterVec
+	limits        prometheus.Histogram
+	statsDuration *prometheus.HistogramVec
+	statsErrors   *prometheus.CounterVec
+}
+
+// NewPrometheus creates a new instance of Prometheus, with the Go runtime and process metrics
+func NewPrometheus() *Prometheus {
+	m := &Prometheus{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of the HTTP requests by method, route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_lookups_total",
+			Help:      "Number of FizzBuzz cache lookups by result, hit or miss.",
+		}, []string{"result"}),
+		limits: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_limit",
+			Help:      "Limit of the served FizzBuzz requests.",
+			Buckets:   []float64{10, 100, 1000, 10000, 100000, 500000},
+		}),
+		statsDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "stats_operation_duration_seconds",
+			Help:      "Duration of the stats repository operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		statsErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stats_operation_errors_total",
+			Help:      "Number of failed stats repository operations.",
+		}, []string{"operation"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration, m.cacheLookups, m.limits, m.statsDuration, m.statsErrors,
+	)
+	return m
+}
+
+// RegisterRedisPool adds the connection pool statistics of a Redis client to the metrics
+func (m *Prometheus) RegisterRedisPool(client *redis.Client) {
+	m.registry.MustRegister(newRedisPoolCollector(client))
+}
+
+// Handler returns the HTTP handler exposing the metrics in the Prometheus text format
+func (m *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Middleware returns the Echo middleware recording the count and the duration of the HTTP requests
+func (m *Prometheus) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			start := time.Now()
+			err := next(ctx)
+			if err != nil {
+				ctx.Error(err)
+			}
+
+			// The route pattern keeps the cardinality bounded, unknown paths share a label
+			route := ctx.Path()
+			if route == "" || isNotFound(err) {
+				route = "unmatched"
+			}
+			status := strconv.Itoa(ctx.Response().Status)
+			method := ctx.Request().Method
+			m.httpRequests.WithLabelValues(method, route, status).Inc()
+			m.httpDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+			// The error is returned for the outer middleware, the response is already written
+			return err
+		}
+	}
+}
+
+// ObserveFizzBuzzLimit records the limit of a served FizzBuzz request
+func (m *Prometheus) ObserveFizzBuzzLimit(limit int) {
+	m.limits.Observe(float64(limit))
+}
+
+// ObserveCacheLookup records a lookup of the FizzBuzz cache
+func (m *Prometheus) ObserveCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheLookups.WithLabelValues(result).Inc()
+}
+
+// ObserveStatsOperation records the duration and the outcome of a stats repository operation
+func (m *Prometheus) ObserveStatsOperation(operation string, duration time.Duration, err error) {
+	m.statsDuration.WithLabelValues(operation).Observe(duration.Seconds())
+	if err != nil {
+		m.statsErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+func isNotFound(err error) bool {
+	var httpErr *echo.HTTPError
+	return errors.As(err, &httpErr) && httpErr.Code == http.StatusNotFound
+}
+
+// NoOp discards the metrics
+type NoOp struct{}
+
+func (NoOp) ObserveFizzBuzzLimit(int)                           {}
+func (NoOp) ObserveCacheLookup(bool)                            {}
+func (NoOp) ObserveStatsOperation(string, time.Duration, error) {}
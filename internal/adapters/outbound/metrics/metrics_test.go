@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+)
+
+func scrape(t *testing.T, m *Prometheus) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestPrometheus_Middleware(t *testing.T) {
+	m := NewPrometheus()
+	app := echo.New()
+	app.Use(m.Middleware())
+	app.GET("/stats/top", func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	})
+	app.GET("/stats", func(ctx echo.Context) error {
+		return echo.NewHTTPError(http.StatusServiceUnavailable)
+	})
+
+	for _, path := range []string{"/stats/top?n=3", "/stats/top", "/stats", "/unknown/1"} {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	body := scrape(t, m)
+	for _, want := range []string{
+		`fizzbuzz_http_requests_total{method="GET",route="/stats/top",status="200"} 2`,
+		`fizzbuzz_http_requests_total{method="GET",route="/stats",status="503"} 1`,
+		`fizzbuzz_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`fizzbuzz_http_request_duration_seconds_count{method="GET",route="/stats/top",status="200"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q", want)
+		}
+	}
+}
+
+func TestPrometheus_Observe(t *testing.T) {
+	m := NewPrometheus()
+	m.ObserveFizzBuzzLimit(15)
+	m.ObserveFizzBuzzLimit(200000)
+	m.ObserveCacheLookup(true)
+	m.ObserveCacheLookup(false)
+	m.ObserveCacheLookup(false)
+	m.ObserveStatsOperation("increment_request_count", time.Millisecond, nil)
+	m.ObserveStatsOperation("increment_request_count", time.Millisecond, errors.New("redis down"))
+
+	body := scrape(t, m)
+	for _, want := range []string{
+		`fizzbuzz_request_limit_bucket{le="100"} 1`,
+		`fizzbuzz_request_limit_bucket{le="500000"} 2`,
+		`fizzbuzz_cache_lookups_total{result="hit"} 1`,
+		`fizzbuzz_cache_lookups_total{result="miss"} 2`,
+		`fizzbuzz_stats_operation_duration_seconds_count{operation="increment_request_count"} 2`,
+		`fizzbuzz_stats_operation_errors_total{operation="increment_request_count"} 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q", want)
+		}
+	}
+}
+
+func TestPrometheus_RegisterRedisPool(t *testing.T) {
+	m := NewPrometheus()
+	m.RegisterRedisPool(redis.NewClient(&redis.Options{Addr: os.Getenv("REDIS_ADDRESS")}))
+
+	body := scrape(t, m)
+	for _, want := range []string{
+		`fizzbuzz_redis_pool_hits_total`,
+		`fizzbuzz_redis_pool_timeouts_total`,
+		`fizzbuzz_redis_pool_connections{state="idle"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q", want)
+		}
+	}
+}
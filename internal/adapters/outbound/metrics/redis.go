@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// redisPoolCollector collects the statistics of the connection pool of a Redis client
+type redisPoolCollector struct {
+	client *redis.Client
+
+	hits        *prometheus.Desc
+	misses      *prometheus.Desc
+	timeouts    *prometheus.Desc
+	connections *prometheus.Desc
+}
+
+func newRedisPoolCollector(client *redis.Client) *redisPoolCollector {
+	return &redisPoolCollector{
+		client: client,
+		hits: prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "hits_total"),
+			"Number of times a free connection was found in the Redis pool.", nil, nil),
+		misses: prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "misses_total"),
+			"Number of times a free connection was not found in the Redis pool.", nil, nil),
+		timeouts: prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "timeouts_total"),
+			"Number of times waiting for a Redis pool connection timed out.", nil, nil),
+		connections: prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "connections"),
+			"Number of connections of the Redis pool by state, total, idle or stale.", []string{"state"}, nil),
+	}
+}
+
+// Describe sends the descriptors of the pool metrics
+func (c *redisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.connections
+}
+
+// Collect sends the pool metrics from a snapshot of the pool statistics
+func (c *redisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(stats.TotalConns), "total")
+	ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(stats.IdleConns), "idle")
+	ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(stats.StaleConns), "stale")
+}
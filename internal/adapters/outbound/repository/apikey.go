@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/tracing"
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+var (
+	_ adapters.APIKeyStore           = (*FileAPIKeyStore)(nil)
+	_ adapters.APIKeyStore           = (*RedisAPIKeyStore)(nil)
+	_ adapters.ReadinessChecker      = (*RedisAPIKeyStore)(nil)
+	_ adapters.APIKeyStatsRepository = (*APIKeyStats)(nil)
+)
+
+// DefaultAPIKeyPrefix is the prefix of the API keys in Redis, followed by the SHA-256 of the key
+const DefaultAPIKeyPrefix = "fizzbuzz:apikeys:"
+
+// hashAPIKey returns the hex encoded SHA-256 of an API key, the keys are looked up by their hash
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// apiKeyEntry is an API key of the keys file
+type apiKeyEntry struct {
+	model.APIKey
+	// Key is the key sent by the client
+	Key string `json:"key"`
+}
+
+// FileAPIKeyStore holds the API keys loaded from a JSON file, a list of objects with the name,
+// the key and the scopes of each client
+type FileAPIKeyStore struct {
+	// keys holds the API keys by the hash of their key
+	keys map[string]model.APIKey
+}
+
+// NewFileAPIKeyStore creates a new instance of FileAPIKeyStore from the keys file at path
+func NewFileAPIKeyStore(path string) (*FileAPIKeyStore, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the API keys file: %w", err)
+	}
+	var entries []apiKeyEntry
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse the API keys file: %w", err)
+	}
+
+	store := &FileAPIKeyStore{keys: make(map[string]model.APIKey, len(entries))}
+	names := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if err := entry.APIKey.Validate(); err != nil {
+			return nil, err
+		}
+		if entry.Key == "" {
+			return nil, fmt.Errorf("API key %q has no key", entry.Name)
+		}
+		hash := hashAPIKey(entry.Key)
+		if _, found := store.keys[hash]; found || names[entry.Name] {
+			return nil, fmt.Errorf("API key %q is defined twice", entry.Name)
+		}
+		names[entry.Name] = true
+		store.keys[hash] = entry.APIKey
+	}
+	return store, nil
+}
+
+// GetAPIKey returns the API key matching key, nil when there is none
+func (s *FileAPIKeyStore) GetAPIKey(_ context.Context, key string) (*model.APIKey, error) {
+	apiKey, found := s.keys[hashAPIKey(key)]
+	if !found {
+		return nil, nil
+	}
+	return &apiKey, nil
+}
+
+// RedisAPIKeyStore holds the API keys in Redis, as JSON objects with the name and the scopes of each client
+// stored under the SHA-256 of their key, so the keys themselves are not stored
+type RedisAPIKeyStore struct {
+	client  *redis.Client
+	prefix  string
+	timeout time.Duration
+}
+
+// NewRedisAPIKeyStore creates a new instance of RedisAPIKeyStore, each lookup bounded by timeout
+func NewRedisAPIKeyStore(client *redis.Client, timeout time.Duration) *RedisAPIKeyStore {
+	return &RedisAPIKeyStore{
+		client:  client,
+		prefix:  DefaultAPIKeyPrefix,
+		timeout: timeout,
+	}
+}
+
+// GetAPIKey returns the API key matching key, nil when there is none
+func (s *RedisAPIKeyStore) GetAPIKey(ctx context.Context, key string) (apiKey *model.APIKey, err error) {
+	ctx, span := startRedisSpan(ctx, "redis.apikeys.get", "GET")
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+	value, err := s.client.Get(ctx, s.prefix+hashAPIKey(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey = &model.APIKey{}
+	if err := json.Unmarshal(value, apiKey); err != nil {
+		return nil, fmt.Errorf("invalid API key: %w", err)
+	}
+	if err := apiKey.Validate(); err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
+
+// PutAPIKey stores the API key of a client, sending key
+func (s *RedisAPIKeyStore) PutAPIKey(ctx context.Context, key string, apiKey model.APIKey) error {
+	if err := apiKey.Validate(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(apiKey)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+hashAPIKey(key), value, 0).Err()
+}
+
+// CheckReadiness returns the readiness of the store, unavailable when Redis does not answer a ping
+func (s *RedisAPIKeyStore) CheckReadiness(ctx context.Context) model.ComponentHealth {
+	return pingReadiness(ctx, s.client, s.timeout, "apikeys-redis")
+}
+
+// APIKeyStatsOption configures an APIKeyStats
+type APIKeyStatsOption func(*APIKeyStats)
+
+// WithAPIKeyStatsReset sets the reset of the statistics of the keys whose repository was not created yet,
+// such as the ones stored in Redis by other instances
+func WithAPIKeyStatsReset(reset func(ctx context.Context) error) APIKeyStatsOption {
+	return func(s *APIKeyStats) {
+		s.reset = reset
+	}
+}
+
+// WithAPIKeyStatsRemove sets the removal of the statistics of a request from the keys whose repository was
+// not created yet, such as the ones stored in Redis by other instances
+func WithAPIKeyStatsRemove(remove func(ctx context.Context, request model.FizzBuzzRequest) error) APIKeyStatsOption {
+	return func(s *APIKeyStats) {
+		s.remove = remove
+	}
+}
+
+// APIKeyStats holds a stats repository per API key, created on first use
+type APIKeyStats struct {
+	create func(key string) adapters.StatsRepository
+	reset  func(ctx context.Context) error
+	remove func(ctx context.Context, request model.FizzBuzzRequest) error
+
+	mu    sync.Mutex
+	repos map[string]adapters.StatsRepository
+}
+
+// NewAPIKeyStats creates a new instance of APIKeyStats, create returning the repository of a key
+func NewAPIKeyStats(create func(key string) adapters.StatsRepository, opts ...APIKeyStatsOption) *APIKeyStats {
+	keyStats := &APIKeyStats{
+		create: create,
+		repos:  make(map[string]adapters.StatsRepository),
+	}
+	for _, opt := range opts {
+		opt(keyStats)
+	}
+	return keyStats
+}
+
+// ForAPIKey returns the stats repository of the API key named key
+func (s *APIKeyStats) ForAPIKey(key string) adapters.StatsRepository {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo, found := s.repos[key]
+	if !found {
+		repo = s.create(key)
+		s.repos[key] = repo
+	}
+	return repo
+}
+
+// ResetStats resets the statistics of every API key, the repositories created first so their pending
+// hits are written, then the other keys
+func (s *APIKeyStats) ResetStats(ctx context.Context) error {
+	var errs []error
+	for _, repo := range s.createdRepos() {
+		errs = append(errs, repo.ResetStats(ctx))
+	}
+	if s.reset != nil {
+		errs = append(errs, s.reset(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// RemoveRequest removes the statistics of request from every API key, the repositories created first so
+// their pending hits are written, then the other keys
+func (s *APIKeyStats) RemoveRequest(ctx context.Context, request model.FizzBuzzRequest) error {
+	var errs []error
+	for _, repo := range s.createdRepos() {
+		_, err := repo.RemoveRequest(ctx, request)
+		errs = append(errs, err)
+	}
+	if s.remove != nil {
+		errs = append(errs, s.remove(ctx, request))
+	}
+	return errors.Join(errs...)
+}
+
+// createdRepos returns the repositories created so far
+func (s *APIKeyStats) createdRepos() []adapters.StatsRepository {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repos := make([]adapters.StatsRepository, 0, len(s.repos))
+	for _, repo := range s.repos {
+		repos = append(repos, repo)
+	}
+	return repos
+}
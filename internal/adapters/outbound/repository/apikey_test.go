@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+func TestNewFileAPIKeyStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		key     string
+		want    *model.APIKey
+		wantErr bool
+	}{
+		{
+			name:    "Known key",
+			content: `[{"name":"acme","key":"s3cret","scopes":["fizzbuzz:generate","stats:read"]}]`,
+			key:     "s3cret",
+			want:    &model.APIKey{Name: "acme", Scopes: []model.Scope{model.ScopeFizzBuzzGenerate, model.ScopeStatsRead}},
+		},
+		{
+			name:    "Unknown key",
+			content: `[{"name":"acme","key":"s3cret","scopes":["admin"]}]`,
+			key:     "other",
+		},
+		{
+			name:    "Invalid JSON",
+			content: `{`,
+			wantErr: true,
+		},
+		{
+			name:    "Unknown scope",
+			content: `[{"name":"acme","key":"s3cret","scopes":["stats:write"]}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Missing key",
+			content: `[{"name":"acme","scopes":["admin"]}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Duplicate name",
+			content: `[{"name":"acme","key":"a","scopes":[]},{"name":"acme","key":"b","scopes":[]}]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "apikeys.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			store, err := NewFileAPIKeyStore(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewFileAPIKeyStore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := store.GetAPIKey(context.Background(), tt.key)
+			if err != nil {
+				t.Fatalf("GetAPIKey() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetAPIKey() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisAPIKeyStore(t *testing.T) {
+	redisClient.FlushAll(context.Background())
+	store := NewRedisAPIKeyStore(redisClient, 0)
+	apiKey := model.APIKey{Name: "acme", Scopes: []model.Scope{model.ScopeStatsRead}}
+	if err := store.PutAPIKey(context.Background(), "s3cret", apiKey); err != nil {
+		t.Fatalf("PutAPIKey() error = %v", err)
+	}
+
+	got, err := store.GetAPIKey(context.Background(), "s3cret")
+	if err != nil {
+		t.Fatalf("GetAPIKey() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, &apiKey) {
+		t.Errorf("GetAPIKey() got = %+v, want %+v", got, apiKey)
+	}
+	if got, err := store.GetAPIKey(context.Background(), "other"); got != nil || err != nil {
+		t.Errorf("GetAPIKey() of an unknown key got = %+v, %v, want nil", got, err)
+	}
+
+	// Only the hash of the key is stored
+	if keys := redisClient.Keys(context.Background(), "*s3cret*").Val(); len(keys) != 0 {
+		t.Errorf("the key is stored in %v", keys)
+	}
+}
+
+func TestAPIKeyStats_ForAPIKey(t *testing.T) {
+	redisClient.FlushAll(context.Background())
+	ctx := context.Background()
+	keyStats := NewAPIKeyStats(func(key string) adapters.StatsRepository {
+		return NewRedisStatsRepository(redisClient, WithNamespace(RedisKeyStatsAPIKeyPrefix+key))
+	})
+	global := NewRedisStatsRepository(redisClient)
+
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+	if err := keyStats.ForAPIKey("acme").IncrementRequestCount(ctx, request); err != nil {
+		t.Fatalf("IncrementRequestCount() error = %v", err)
+	}
+	if keyStats.ForAPIKey("acme") != keyStats.ForAPIKey("acme") {
+		t.Error("ForAPIKey() created the repository of a key twice")
+	}
+
+	got, err := keyStats.ForAPIKey("acme").GetMostFrequentRequest(ctx)
+	if err != nil || got == nil || got.Hits != 1 {
+		t.Errorf("GetMostFrequentRequest() of the key got = %+v, %v, want 1 hit", got, err)
+	}
+	for name, repo := range map[string]adapters.StatsRepository{"other key": keyStats.ForAPIKey("other"), "global": global} {
+		if got, err := repo.GetMostFrequentRequest(ctx); err != nil || got != nil {
+			t.Errorf("GetMostFrequentRequest() of the %s got = %+v, %v, want nil", name, got, err)
+		}
+	}
+
+	// Resetting the global statistics keeps the statistics of the keys
+	if err := global.ResetStats(ctx); err != nil {
+		t.Fatalf("ResetStats() error = %v", err)
+	}
+	if got, _ := keyStats.ForAPIKey("acme").GetMostFrequentRequest(ctx); got == nil {
+		t.Error("ResetStats() removed the statistics of the key")
+	}
+}
+
+func TestAPIKeyStats_ResetStats(t *testing.T) {
+	redisClient.FlushAll(context.Background())
+	ctx := context.Background()
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+
+	// The hits of a key are recorded asynchronously, the ones of another key were stored by another instance
+	keyStats := NewAPIKeyStats(func(key string) adapters.StatsRepository {
+		return NewStatsRecorder(NewRedisStatsRepository(redisClient, WithNamespace(RedisKeyStatsAPIKeyPrefix+key)),
+			WithRecorderFlushInterval(time.Hour))
+	}, WithAPIKeyStatsReset(func(ctx context.Context) error {
+		return ResetRedisStatsNamespaces(ctx, redisClient, RedisKeyStatsAPIKeyPrefix)
+	}))
+	other := NewRedisStatsRepository(redisClient, WithNamespace(RedisKeyStatsAPIKeyPrefix+"other"))
+	global := NewRedisStatsRepository(redisClient)
+	for _, repo := range []adapters.StatsRepository{keyStats.ForAPIKey("acme"), other, global} {
+		if err := repo.IncrementRequestCount(ctx, request); err != nil {
+			t.Fatalf("IncrementRequestCount() error = %v", err)
+		}
+	}
+
+	if err := keyStats.ResetStats(ctx); err != nil {
+		t.Fatalf("ResetStats() error = %v", err)
+	}
+	for name, repo := range map[string]adapters.StatsRepository{"key": keyStats.ForAPIKey("acme"), "other key": other} {
+		if got, err := repo.GetMostFrequentRequest(ctx); err != nil || got != nil {
+			t.Errorf("GetMostFrequentRequest() of the %s got = %+v, %v, want nil", name, got, err)
+		}
+	}
+	if keys := redisClient.Keys(ctx, RedisKeyStatsAPIKeyPrefix+"*").Val(); len(keys) > 0 {
+		t.Errorf("ResetStats() left the keys %v", keys)
+	}
+	if got, _ := global.GetMostFrequentRequest(ctx); got == nil {
+		t.Error("ResetStats() removed the global statistics")
+	}
+}
+
+func TestAPIKeyStats_RemoveRequest(t *testing.T) {
+	redisClient.FlushAll(context.Background())
+	ctx := context.Background()
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+	kept := model.FizzBuzzRequest{Int1: 2, Int2: 7, Limit: 30, Str1: "fizz", Str2: "buzz"}
+
+	// The hits of a key are recorded asynchronously, the ones of another key were stored by another instance
+	keyStats := NewAPIKeyStats(func(key string) adapters.StatsRepository {
+		return NewStatsRecorder(NewRedisStatsRepository(redisClient, WithNamespace(RedisKeyStatsAPIKeyPrefix+key)),
+			WithRecorderFlushInterval(time.Hour))
+	}, WithAPIKeyStatsRemove(func(ctx context.Context, request model.FizzBuzzRequest) error {
+		return RemoveRedisStatsNamespacesRequest(ctx, redisClient, RedisKeyStatsAPIKeyPrefix, request)
+	}))
+	other := NewRedisStatsRepository(redisClient, WithNamespace(RedisKeyStatsAPIKeyPrefix+"other"))
+	global := NewRedisStatsRepository(redisClient)
+	for _, repo := range []adapters.StatsRepository{keyStats.ForAPIKey("acme"), other, global} {
+		for _, r := range []model.FizzBuzzRequest{request, kept} {
+			if err := repo.IncrementRequestCount(ctx, r); err != nil {
+				t.Fatalf("IncrementRequestCount() error = %v", err)
+			}
+		}
+	}
+
+	if err := keyStats.RemoveRequest(ctx, request); err != nil {
+		t.Fatalf("RemoveRequest() error = %v", err)
+	}
+	for name, repo := range map[string]adapters.StatsRepository{"key": keyStats.ForAPIKey("acme"), "other key": other} {
+		page, err := repo.GetRequestsByHits(ctx, 0, 10)
+		if err != nil {
+			t.Fatalf("GetRequestsByHits() of the %s error = %v", name, err)
+		}
+		if page.Total != 1 || len(page.Results) != 1 || page.Results[0].Int1 != kept.Int1 {
+			t.Errorf("GetRequestsByHits() of the %s got = %+v, want only the kept request", name, page)
+		}
+		got, err := repo.GetMostFrequentRequestBetween(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		if err != nil || got == nil || got.Int1 != kept.Int1 {
+			t.Errorf("GetMostFrequentRequestBetween() of the %s got = %+v, %v, want the kept request", name, got, err)
+		}
+	}
+	if page, _ := global.GetRequestsByHits(ctx, 0, 10); page == nil || page.Total != 2 {
+		t.Errorf("RemoveRequest() removed the global statistics, got = %+v", page)
+	}
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+	"github.com/sony/gobreaker"
+)
+
+const (
+	// DefaultBreakerFailures is the number of consecutive failures opening a circuit breaker by default
+	DefaultBreakerFailures = 5
+	// DefaultBreakerOpenTimeout is how long a circuit breaker stays open by default before trying again
+	DefaultBreakerOpenTimeout = 30 * time.Second
+	// DefaultStatsBufferSize is the default number of distinct requests buffered while the statistics are unavailable
+	DefaultStatsBufferSize = 10000
+)
+
+// BreakerOption configures the circuit breaker of an adapter
+type BreakerOption func(*breakerOptions)
+
+type breakerOptions struct {
+	failures    uint32
+	openTimeout time.Duration
+	bufferSize  int
+}
+
+func newBreakerOptions(opts []BreakerOption) breakerOptions {
+	options := breakerOptions{
+		failures:    DefaultBreakerFailures,
+		openTimeout: DefaultBreakerOpenTimeout,
+		bufferSize:  DefaultStatsBufferSize,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// WithBreakerFailures sets the number of consecutive failures opening the circuit breaker
+func WithBreakerFailures(failures uint32) BreakerOption {
+	return func(o *breakerOptions) {
+		if failures > 0 {
+			o.failures = failures
+		}
+	}
+}
+
+// WithBreakerOpenTimeout sets how long the circuit breaker stays open before letting a request try again
+func WithBreakerOpenTimeout(timeout time.Duration) BreakerOption {
+	return func(o *breakerOptions) {
+		if timeout > 0 {
+			o.openTimeout = timeout
+		}
+	}
+}
+
+// WithStatsBufferSize sets the number of distinct requests whose hits are buffered while the statistics are unavailable
+func WithStatsBufferSize(size int) BreakerOption {
+	return func(o *breakerOptions) {
+		if size > 0 {
+			o.bufferSize = size
+		}
+	}
+}
+
+// newCircuitBreaker creates a circuit breaker opening after the configured consecutive failures
+func newCircuitBreaker(name string, options breakerOptions) *gobreaker.CircuitBreaker {
+	return gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:    name,
+		Timeout: options.openTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= options.failures
+		},
+		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+			slog.Warn("Circuit breaker state changed", "name", name, "from", from.String(), "to", to.String())
+		},
+		// A request cancelled by its client does not tell anything about the adapter
+		IsSuccessful: func(err error) bool {
+			return err == nil || errors.Is(err, context.Canceled)
+		},
+	})
+}
+
+// isBreakerOpen reports whether err comes from a circuit breaker rejecting the call
+func isBreakerOpen(err error) bool {
+	return errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests)
+}
+
+// execute runs fn through the circuit breaker, failing with unavailable while it is open
+func execute[T any](breaker *gobreaker.CircuitBreaker, unavailable error, fn func() (T, error)) (T, error) {
+	var res T
+	_, err := breaker.Execute(func() (any, error) {
+		var err error
+		res, err = fn()
+		return nil, err
+	})
+	if isBreakerOpen(err) {
+		return res, unavailable
+	}
+	return res, err
+}
+
+// breakerHealth returns the health of a component protected by a circuit breaker
+func breakerHealth(breaker *gobreaker.CircuitBreaker) model.ComponentHealth {
+	health := model.ComponentHealth{
+		Name:    breaker.Name(),
+		Status:  model.HealthStatusOK,
+		Breaker: breaker.State().String(),
+	}
+	if breaker.State() != gobreaker.StateClosed {
+		health.Status = model.HealthStatusDegraded
+	}
+	return health
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+	"github.com/sony/gobreaker"
+)
+
+var (
+	_ adapters.CacheFizzbuzz  = (*CacheBreaker)(nil)
+	_ adapters.HealthReporter = (*CacheBreaker)(nil)
+)
+
+// CacheBreaker protects a cache with a circuit breaker. Failed or rejected Get and Set behave as a miss
+// and a skipped write, Flush fails with model.ErrCacheUnavailable while the breaker is open.
+type CacheBreaker struct {
+	cache   adapters.CacheFizzbuzz
+	breaker *gobreaker.CircuitBreaker
+}
+
+// NewCacheBreaker creates a new instance of CacheBreaker protecting cache
+func NewCacheBreaker(cache adapters.CacheFizzbuzz, opts ...BreakerOption) *CacheBreaker {
+	return &CacheBreaker{
+		cache:   cache,
+		breaker: newCircuitBreaker("cache", newBreakerOptions(opts)),
+	}
+}
+
+// Get retrieves a value from the cache by key, an empty string when it is not cached or unavailable
+func (c *CacheBreaker) Get(ctx context.Context, key string) (string, error) {
+	value, err := execute(c.breaker, model.ErrCacheUnavailable, func() (string, error) {
+		return c.cache.Get(ctx, key)
+	})
+	if err != nil {
+		return "", nil
+	}
+	return value, nil
+}
+
+// Set stores a value in the cache with a key, the value is not cached when the cache is unavailable
+func (c *CacheBreaker) Set(ctx context.Context, key string, value string) error {
+	_, _ = execute(c.breaker, model.ErrCacheUnavailable, func() (any, error) {
+		return nil, c.cache.Set(ctx, key, value)
+	})
+	return nil
+}
+
+// Flush removes every cached value of the service
+func (c *CacheBreaker) Flush(ctx context.Context) error {
+	_, err := execute(c.breaker, model.ErrCacheUnavailable, func() (any, error) {
+		return nil, c.cache.Flush(ctx)
+	})
+	return err
+}
+
+// Health returns the state of the circuit breaker
+func (c *CacheBreaker) Health() model.ComponentHealth {
+	return breakerHealth(c.breaker)
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+// flakyCache is an in-process cache failing while down is set
+type flakyCache struct {
+	*CacheMemory
+	down atomic.Bool
+}
+
+func (f *flakyCache) Get(ctx context.Context, key string) (string, error) {
+	if f.down.Load() {
+		return "", errRedisDown
+	}
+	return f.CacheMemory.Get(ctx, key)
+}
+
+func (f *flakyCache) Set(ctx context.Context, key string, value string) error {
+	if f.down.Load() {
+		return errRedisDown
+	}
+	return f.CacheMemory.Set(ctx, key, value)
+}
+
+func (f *flakyCache) Flush(ctx context.Context) error {
+	if f.down.Load() {
+		return errRedisDown
+	}
+	return f.CacheMemory.Flush(ctx)
+}
+
+func TestCacheBreaker(t *testing.T) {
+	cache := &flakyCache{CacheMemory: NewCacheMemory(100)}
+	c := NewCacheBreaker(cache, WithBreakerFailures(2))
+	_ = c.Set(context.Background(), "a", "1,2,Fizz")
+
+	cache.down.Store(true)
+	if got, err := c.Get(context.Background(), "a"); got != "" || err != nil {
+		t.Errorf("Get() got = %q, %v, want a miss", got, err)
+	}
+	if err := c.Set(context.Background(), "b", "1,2"); err != nil {
+		t.Errorf("Set() error = %v, want the value skipped", err)
+	}
+	if got := c.Health(); got.Status != model.HealthStatusDegraded || got.Breaker != "open" {
+		t.Errorf("Health() got = %+v, want degraded", got)
+	}
+	if err := c.Flush(context.Background()); !errors.Is(err, model.ErrCacheUnavailable) {
+		t.Errorf("Flush() error = %v, want %v", err, model.ErrCacheUnavailable)
+	}
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+)
+
+var _ adapters.CacheFizzbuzz = (*CacheMemory)(nil)
+
+// DefaultCacheMaxBytes is the default size bound of the in-process cache
+const DefaultCacheMaxBytes = 64 << 20
+
+// CacheStats holds the counters of a cache
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+	Bytes     int64
+}
+
+type cacheEntry struct {
+	key   string
+	value string
+}
+
+func (e *cacheEntry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+// CacheMemory is an in-process cache bounded by the total size of its keys and values,
+// evicting the least recently used entries first. It is safe for concurrent use.
+type CacheMemory struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	entries  map[string]*list.Element
+	// recent orders the entries from the most to the least recently used
+	recent *list.List
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// NewCacheMemory creates a new instance of CacheMemory holding at most maxBytes of keys and values
+func NewCacheMemory(maxBytes int64) *CacheMemory {
+	if maxBytes <= 0 {
+		maxBytes = DefaultCacheMaxBytes
+	}
+	return &CacheMemory{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		recent:   list.New(),
+	}
+}
+
+// Get retrieves a value from the cache by key, an empty string when it is not cached
+func (c *CacheMemory) Get(ctx context.Context, key string) (string, error) {
+	// The value is read while locked, Set replaces the entry of the element
+	var value string
+	c.mu.Lock()
+	element, ok := c.entries[key]
+	if ok {
+		c.recent.MoveToFront(element)
+		value = element.Value.(*cacheEntry).value
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		c.misses.Add(1)
+		return "", nil
+	}
+	c.hits.Add(1)
+	return value, nil
+}
+
+// Set stores a value in the cache with a key, evicting the least recently used entries to make room.
+// Values larger than the cache are not stored.
+func (c *CacheMemory) Set(ctx context.Context, key string, value string) error {
+	entry := &cacheEntry{key: key, value: value}
+	if entry.size() > c.maxBytes {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.bytes += entry.size() - element.Value.(*cacheEntry).size()
+		element.Value = entry
+		c.recent.MoveToFront(element)
+	} else {
+		c.entries[key] = c.recent.PushFront(entry)
+		c.bytes += entry.size()
+	}
+
+	for c.bytes > c.maxBytes {
+		oldest := c.recent.Back()
+		evicted := c.recent.Remove(oldest).(*cacheEntry)
+		delete(c.entries, evicted.key)
+		c.bytes -= evicted.size()
+		c.evictions.Add(1)
+	}
+	return nil
+}
+
+// Flush removes every entry of the cache
+func (c *CacheMemory) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.recent.Init()
+	c.bytes = 0
+	return nil
+}
+
+// Stats returns the counters of the cache
+func (c *CacheMemory) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   len(c.entries),
+		Bytes:     c.bytes,
+	}
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCacheMemory_GetSet(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxBytes  int64
+		set       [][2]string
+		get       []string
+		want      []string
+		wantStats CacheStats
+	}{
+		{
+			name:      "Miss on empty cache",
+			maxBytes:  100,
+			get:       []string{"a"},
+			want:      []string{""},
+			wantStats: CacheStats{Misses: 1},
+		},
+		{
+			name:      "Hit after set",
+			maxBytes:  100,
+			set:       [][2]string{{"a", "1,2,Fizz"}},
+			get:       []string{"a", "a"},
+			want:      []string{"1,2,Fizz", "1,2,Fizz"},
+			wantStats: CacheStats{Hits: 2, Entries: 1, Bytes: 9},
+		},
+		{
+			name:      "Overwrite updates the size",
+			maxBytes:  100,
+			set:       [][2]string{{"a", "1,2,Fizz"}, {"a", "1"}},
+			get:       []string{"a"},
+			want:      []string{"1"},
+			wantStats: CacheStats{Hits: 1, Entries: 1, Bytes: 2},
+		},
+		{
+			name:      "Least recently used entry is evicted",
+			maxBytes:  12,
+			set:       [][2]string{{"a", "11111"}, {"b", "22222"}, {"c", "33333"}},
+			get:       []string{"a", "b", "c"},
+			want:      []string{"", "22222", "33333"},
+			wantStats: CacheStats{Hits: 2, Misses: 1, Evictions: 1, Entries: 2, Bytes: 12},
+		},
+		{
+			name:      "Value larger than the cache is not stored",
+			maxBytes:  4,
+			set:       [][2]string{{"a", "11111"}},
+			get:       []string{"a"},
+			want:      []string{""},
+			wantStats: CacheStats{Misses: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCacheMemory(tt.maxBytes)
+			for _, kv := range tt.set {
+				if err := c.Set(context.Background(), kv[0], kv[1]); err != nil {
+					t.Errorf("Set() error = %v", err)
+				}
+			}
+			for i, key := range tt.get {
+				got, err := c.Get(context.Background(), key)
+				if err != nil {
+					t.Errorf("Get() error = %v", err)
+				}
+				if got != tt.want[i] {
+					t.Errorf("Get(%q) got = %q, want %q", key, got, tt.want[i])
+				}
+			}
+			if got := c.Stats(); got != tt.wantStats {
+				t.Errorf("Stats() got = %+v, want %+v", got, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestCacheMemory_RecentlyUsedEntryIsKept(t *testing.T) {
+	c := NewCacheMemory(12)
+	_ = c.Set(context.Background(), "a", "11111")
+	_ = c.Set(context.Background(), "b", "22222")
+	_, _ = c.Get(context.Background(), "a")
+	_ = c.Set(context.Background(), "c", "33333")
+
+	if got, _ := c.Get(context.Background(), "a"); got != "11111" {
+		t.Errorf("Get(a) got = %q, want it kept", got)
+	}
+	if got, _ := c.Get(context.Background(), "b"); got != "" {
+		t.Errorf("Get(b) got = %q, want it evicted", got)
+	}
+}
+
+func TestCacheMemory_Flush(t *testing.T) {
+	c := NewCacheMemory(100)
+	_ = c.Set(context.Background(), "a", "11111")
+	if err := c.Flush(context.Background()); err != nil {
+		t.Errorf("Flush() error = %v", err)
+	}
+	if got, _ := c.Get(context.Background(), "a"); got != "" {
+		t.Errorf("Get(a) got = %q, want it flushed", got)
+	}
+	if stats := c.Stats(); stats.Entries != 0 || stats.Bytes != 0 {
+		t.Errorf("Stats() got = %+v, want an empty cache", stats)
+	}
+}
+
+func TestCacheMemory_Concurrent(t *testing.T) {
+	c := NewCacheMemory(1024)
+	value := strings.Repeat("x", 100)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprint((w + i) % 20)
+				if got, _ := c.Get(context.Background(), key); got != "" && got != value {
+					t.Errorf("Get(%q) got = %q", key, got)
+				}
+				_ = c.Set(context.Background(), key, value)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := c.Stats()
+	if stats.Bytes > 1024 {
+		t.Errorf("Stats() bytes = %d, want at most 1024", stats.Bytes)
+	}
+	if stats.Hits+stats.Misses != 8000 {
+		t.Errorf("Stats() hits + misses = %d, want 8000", stats.Hits+stats.Misses)
+	}
+}
+
+func TestCacheMemory_ConcurrentGetSetSameKey(t *testing.T) {
+	c := NewCacheMemory(1024)
+	values := []string{"1,2,Fizz", "1,2,Fizz,4,Buzz"}
+	_ = c.Set(context.Background(), "key", values[0])
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				_ = c.Set(context.Background(), "key", values[i%2])
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if got, _ := c.Get(context.Background(), "key"); got != values[0] && got != values[1] {
+					t.Errorf("Get() got = %q", got)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -1,6 +1,10 @@
 package repository
 
-import "github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+import (
+	"context"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+)
 
 type CacheFizzbuzzNoOp struct{}
 
@@ -8,9 +12,12 @@ func NewCacheFizzbuzzNoOp() adapters.CacheFizzbuzz {
 	return &CacheFizzbuzzNoOp{}
 }
 
-func (c *CacheFizzbuzzNoOp) Get(key string) (string, error) {
+func (c *CacheFizzbuzzNoOp) Get(ctx context.Context, key string) (string, error) {
 	return "", nil
 }
-func (c *CacheFizzbuzzNoOp) Set(key string, value string) error {
+func (c *CacheFizzbuzzNoOp) Set(ctx context.Context, key string, value string) error {
+	return nil
+}
+func (c *CacheFizzbuzzNoOp) Flush(ctx context.Context) error {
 	return nil
 }
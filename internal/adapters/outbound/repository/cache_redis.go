@@ -1,26 +1,94 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"slices"
+	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/tracing"
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var (
+	_ adapters.CacheFizzbuzz    = (*CacheRedis)(nil)
+	_ adapters.ReadinessChecker = (*CacheRedis)(nil)
+)
+
+const (
+	// DefaultCacheKeyPrefix is the namespace of the cache keys in Redis
+	DefaultCacheKeyPrefix = "fizzbuzz:cache:"
+	// cacheFlushBatchSize is the number of keys deleted at once by Flush
+	cacheFlushBatchSize = 500
+)
+
+// CacheRedis is a Redis implementation of CacheFizzbuzz. Get and Set are bounded by the cache timeout.
 type CacheRedis struct {
-	client *redis.Client
+	client       *redis.Client
+	timeout      time.Duration
+	ttl          time.Duration
+	keyPrefix    string
+	maxValueSize int
+}
+
+// CacheRedisOption configures a CacheRedis
+type CacheRedisOption func(*CacheRedis)
+
+// WithCacheTTL sets the expiration of the cached values, 0 keeps them until they are flushed
+func WithCacheTTL(ttl time.Duration) CacheRedisOption {
+	return func(c *CacheRedis) {
+		c.ttl = ttl
+	}
+}
+
+// WithCacheTimeout sets the timeout of the Get and Set operations, 0 disables it
+func WithCacheTimeout(timeout time.Duration) CacheRedisOption {
+	return func(c *CacheRedis) {
+		c.timeout = timeout
+	}
+}
+
+// WithCacheKeyPrefix sets the namespace prepended to the cache keys
+func WithCacheKeyPrefix(prefix string) CacheRedisOption {
+	return func(c *CacheRedis) {
+		c.keyPrefix = prefix
+	}
+}
+
+// WithCacheMaxValueSize sets the size in bytes above which values are not cached, 0 caches every value
+func WithCacheMaxValueSize(size int) CacheRedisOption {
+	return func(c *CacheRedis) {
+		c.maxValueSize = size
+	}
 }
 
 // NewCacheRedis creates a new instance of CacheRedis
-func NewCacheRedis(client *redis.Client) *CacheRedis {
-	return &CacheRedis{
-		client: client,
+func NewCacheRedis(client *redis.Client, opts ...CacheRedisOption) *CacheRedis {
+	cache := &CacheRedis{
+		client:    client,
+		timeout:   DefaultRedisTimeout,
+		keyPrefix: DefaultCacheKeyPrefix,
 	}
+	for _, opt := range opts {
+		opt(cache)
+	}
+	return cache
 }
 
 // Get retrieves a value from the Redis cache by key
-func (c *CacheRedis) Get(key string) (string, error) {
-	ctx := c.client.Context()
-	val, err := c.client.Get(ctx, key).Result()
+func (c *CacheRedis) Get(ctx context.Context, key string) (val string, err error) {
+	ctx, span := startRedisSpan(ctx, "redis.cache.get", "GET")
+	defer func() {
+		span.SetAttributes(attribute.Bool("fizzbuzz.cache.hit", val != ""))
+		tracing.End(span, err)
+	}()
+
+	ctx, cancel := withTimeout(ctx, c.timeout)
+	defer cancel()
+	val, err = c.client.Get(ctx, c.keyPrefix+key).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			return "", nil
@@ -30,13 +98,51 @@ func (c *CacheRedis) Get(key string) (string, error) {
 	return val, nil
 }
 
-// Set stores a value in the Redis cache with a key
-func (c *CacheRedis) Set(key string, value string) error {
-	ctx := c.client.Context()
-	err := c.client.Set(ctx, key, value, 0).Err()
-	if err != nil {
+// Set stores a value in the Redis cache with a key, unless it is larger than the max value size
+func (c *CacheRedis) Set(ctx context.Context, key string, value string) (err error) {
+	if c.maxValueSize > 0 && len(value) > c.maxValueSize {
+		return nil
+	}
+
+	ctx, span := startRedisSpan(ctx, "redis.cache.set", "SET")
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.client.Set(ctx, c.keyPrefix+key, value, c.ttl).Err()
+}
+
+// Flush removes the cache keys of the service, the ones starting with the key prefix
+func (c *CacheRedis) Flush(ctx context.Context) (err error) {
+	var keys []string
+	if c.keyPrefix == "" {
+		return errors.New("cannot flush the cache without a key prefix")
+	}
+
+	ctx, span := startRedisSpan(ctx, "redis.cache.flush", "SCAN DEL")
+	defer func() {
+		span.SetAttributes(attribute.Int("fizzbuzz.cache.flushed_keys", len(keys)))
+		tracing.End(span, err)
+	}()
+
+	iter := c.client.Scan(ctx, 0, prefixPattern(c.keyPrefix), cacheFlushBatchSize).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
 		return err
 	}
 
+	// Keys are deleted once the scan is over, deleting them while scanning may skip some
+	for batch := range slices.Chunk(keys, cacheFlushBatchSize) {
+		if err := c.client.Del(ctx, batch...).Err(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// CheckReadiness returns the readiness of the cache, unavailable when Redis does not answer a ping
+func (c *CacheRedis) CheckReadiness(ctx context.Context) model.ComponentHealth {
+	return pingReadiness(ctx, c.client, c.timeout, "cache-redis")
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheRedis_GetSet(t *testing.T) {
+	redisClient.FlushAll(context.Background())
+
+	tests := []struct {
+		name    string
+		opts    []CacheRedisOption
+		value   string
+		wantKey string
+		want    string
+		wantTTL time.Duration
+	}{
+		{
+			name:    "Default prefix without expiration",
+			value:   "1,2,Fizz",
+			wantKey: "fizzbuzz:cache:3,5,3,Fizz,Buzz",
+			want:    "1,2,Fizz",
+			wantTTL: -1,
+		},
+		{
+			name:    "Custom prefix and TTL",
+			opts:    []CacheRedisOption{WithCacheKeyPrefix("app:"), WithCacheTTL(time.Minute)},
+			value:   "1,2,Fizz",
+			wantKey: "app:3,5,3,Fizz,Buzz",
+			want:    "1,2,Fizz",
+			wantTTL: time.Minute,
+		},
+		{
+			name:    "Value larger than the max size is not cached",
+			opts:    []CacheRedisOption{WithCacheKeyPrefix("large:"), WithCacheMaxValueSize(4)},
+			value:   "1,2,Fizz",
+			wantKey: "large:3,5,3,Fizz,Buzz",
+			want:    "",
+			wantTTL: -2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCacheRedis(redisClient, tt.opts...)
+			if err := c.Set(context.Background(), "3,5,3,Fizz,Buzz", tt.value); err != nil {
+				t.Errorf("Set() error = %v", err)
+				return
+			}
+			got, err := c.Get(context.Background(), "3,5,3,Fizz,Buzz")
+			if err != nil {
+				t.Errorf("Get() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Get() got = %q, want %q", got, tt.want)
+			}
+			if ttl := redisClient.TTL(context.Background(), tt.wantKey).Val(); ttl != tt.wantTTL {
+				t.Errorf("TTL(%q) got = %v, want %v", tt.wantKey, ttl, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestCacheRedis_Flush(t *testing.T) {
+	ctx := context.Background()
+	redisClient.FlushAll(ctx)
+	redisClient.Set(ctx, "other:app", "kept", 0)
+	redisClient.ZAdd(ctx, RedisKeyStats)
+
+	c := NewCacheRedis(redisClient)
+	// More keys than a flush batch
+	for i := 0; i < cacheFlushBatchSize+10; i++ {
+		_ = c.Set(context.Background(), strings.Repeat("x", i+1), "value")
+	}
+	if err := c.Flush(context.Background()); err != nil {
+		t.Errorf("Flush() error = %v", err)
+	}
+
+	if keys := redisClient.Keys(ctx, DefaultCacheKeyPrefix+"*").Val(); len(keys) != 0 {
+		t.Errorf("Expected no cache key after Flush(), got %d", len(keys))
+	}
+	if got := redisClient.Get(ctx, "other:app").Val(); got != "kept" {
+		t.Errorf("Expected the keys of other apps to be kept, got %q", got)
+	}
+
+	// The glob characters of the prefix match themselves only
+	redisClient.Set(ctx, "cache1:x", "kept", 0)
+	globCache := NewCacheRedis(redisClient, WithCacheKeyPrefix("cache[1]:"))
+	_ = globCache.Set(ctx, "x", "value")
+	if err := globCache.Flush(ctx); err != nil {
+		t.Errorf("Flush() error = %v", err)
+	}
+	if got := redisClient.Exists(ctx, "cache[1]:x").Val(); got != 0 {
+		t.Errorf("Expected the cache key to be flushed")
+	}
+	if got := redisClient.Get(ctx, "cache1:x").Val(); got != "kept" {
+		t.Errorf("Expected the keys matching the prefix as a pattern to be kept, got %q", got)
+	}
+
+	if err := NewCacheRedis(redisClient, WithCacheKeyPrefix("")).Flush(context.Background()); err == nil {
+		t.Errorf("Flush() without a key prefix error = nil, want an error")
+	}
+}
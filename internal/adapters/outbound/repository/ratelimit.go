@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"math"
+	"time"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+const (
+	// DefaultRateLimitCapacity is the default number of tokens of a full bucket
+	DefaultRateLimitCapacity = 1000
+	// DefaultRateLimitRate is the default number of tokens added to a bucket per second
+	DefaultRateLimitRate = 100
+	// DefaultRateLimitKeyPrefix is the namespace of the buckets in Redis
+	DefaultRateLimitKeyPrefix = "fizzbuzz:ratelimit:"
+)
+
+// RateLimitOption configures a rate limiter
+type RateLimitOption func(*rateLimitOptions)
+
+type rateLimitOptions struct {
+	capacity  int
+	rate      float64
+	keyPrefix string
+	timeout   time.Duration
+	now       func() time.Time
+}
+
+func newRateLimitOptions(opts []RateLimitOption) rateLimitOptions {
+	options := rateLimitOptions{
+		capacity:  DefaultRateLimitCapacity,
+		rate:      DefaultRateLimitRate,
+		keyPrefix: DefaultRateLimitKeyPrefix,
+		timeout:   DefaultRedisTimeout,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// WithRateLimitCapacity sets the number of tokens of a full bucket, the largest burst of requests of a client
+func WithRateLimitCapacity(capacity int) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		if capacity > 0 {
+			o.capacity = capacity
+		}
+	}
+}
+
+// WithRateLimitRate sets the number of tokens added to a bucket per second, the sustained rate of a client
+func WithRateLimitRate(rate float64) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		if rate > 0 {
+			o.rate = rate
+		}
+	}
+}
+
+// WithRateLimitKeyPrefix sets the namespace prepended to the keys of the buckets in Redis
+func WithRateLimitKeyPrefix(prefix string) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.keyPrefix = prefix
+	}
+}
+
+// WithRateLimitTimeout sets the timeout of each operation of the Redis rate limiter, 0 disables it
+func WithRateLimitTimeout(timeout time.Duration) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithRateLimitClock sets the function returning the current time of the requests
+func WithRateLimitClock(now func() time.Time) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.now = now
+	}
+}
+
+// cost returns the tokens taken by a request of the given cost, at least one and at most a full bucket
+// so that the most expensive requests are still allowed once the bucket is full
+func (o rateLimitOptions) cost(cost int) int {
+	return min(max(cost, 1), o.capacity)
+}
+
+// refill returns the tokens of a bucket once elapsed went by since it held tokens
+func (o rateLimitOptions) refill(tokens float64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return tokens
+	}
+	return min(float64(o.capacity), tokens+elapsed.Seconds()*o.rate)
+}
+
+// fullAfter returns the time until a bucket holding tokens is full
+func (o rateLimitOptions) fullAfter(tokens float64) time.Duration {
+	return o.duration(float64(o.capacity) - tokens)
+}
+
+// duration returns the time taken to add tokens to a bucket, rounded up to the millisecond
+func (o rateLimitOptions) duration(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(tokens/o.rate*1000)) * time.Millisecond
+}
+
+// rateLimit returns the state of a bucket holding tokens once a request of cost tokens was taken, or refused
+func (o rateLimitOptions) rateLimit(allowed bool, tokens float64, cost int) model.RateLimit {
+	limit := model.RateLimit{
+		Allowed:   allowed,
+		Limit:     o.capacity,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     o.fullAfter(tokens),
+	}
+	if !allowed {
+		limit.RetryAfter = o.duration(float64(cost) - tokens)
+	}
+	return limit
+}
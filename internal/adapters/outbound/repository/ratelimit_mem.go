@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+var _ adapters.RateLimiter = (*InMemoryRateLimiter)(nil)
+
+// tokenBucket holds the tokens of a client, as of updated
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// InMemoryRateLimiter is an in-process implementation of RateLimiter, the buckets are not shared between
+// instances. The full buckets are dropped as they are equivalent to missing ones.
+type InMemoryRateLimiter struct {
+	options rateLimitOptions
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	// swept is the last time the full buckets were dropped
+	swept time.Time
+}
+
+// NewInMemoryRateLimiter creates a new instance of InMemoryRateLimiter
+func NewInMemoryRateLimiter(opts ...RateLimitOption) *InMemoryRateLimiter {
+	options := newRateLimitOptions(opts)
+	return &InMemoryRateLimiter{
+		options: options,
+		buckets: make(map[string]*tokenBucket),
+		swept:   options.now(),
+	}
+}
+
+// Take takes cost tokens from the bucket of key when it holds enough of them
+func (l *InMemoryRateLimiter) Take(_ context.Context, key string, cost int) (model.RateLimit, error) {
+	cost = l.options.cost(cost)
+	now := l.options.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	bucket, found := l.buckets[key]
+	if !found {
+		bucket = &tokenBucket{tokens: float64(l.options.capacity), updated: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = l.options.refill(bucket.tokens, now.Sub(bucket.updated))
+	bucket.updated = now
+
+	allowed := bucket.tokens >= float64(cost)
+	if allowed {
+		bucket.tokens -= float64(cost)
+	}
+	return l.options.rateLimit(allowed, bucket.tokens, cost), nil
+}
+
+// Len returns the number of buckets held by the limiter
+func (l *InMemoryRateLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// sweep drops the buckets full by now, once per time taken to fill an empty bucket
+func (l *InMemoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.options.fullAfter(0) {
+		return
+	}
+	l.swept = now
+	for key, bucket := range l.buckets {
+		if l.options.refill(bucket.tokens, now.Sub(bucket.updated)) >= float64(l.options.capacity) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/tracing"
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+var (
+	_ adapters.RateLimiter      = (*RedisRateLimiter)(nil)
+	_ adapters.ReadinessChecker = (*RedisRateLimiter)(nil)
+)
+
+// takeTokensScript refills the bucket of KEYS[1] and takes ARGV[4] tokens from it when it holds enough of them.
+// ARGV holds the capacity, the tokens added per millisecond and the current Unix time in milliseconds.
+// It returns 1 when the tokens were taken, 0 otherwise, and the tokens left as a string to keep their fraction.
+var takeTokensScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(bucket[1])
+local updated = tonumber(bucket[2])
+if tokens == nil or updated == nil then
+	tokens = capacity
+	updated = now
+end
+if now > updated then
+	tokens = math.min(capacity, tokens + (now - updated) * rate)
+	updated = now
+end
+
+local allowed = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(updated))
+redis.call('PEXPIRE', KEYS[1], math.ceil((capacity - tokens) / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisRateLimiter is a Redis implementation of RateLimiter, sharing the buckets between the instances.
+// A bucket is refilled and taken from atomically by a script, and expires once it is full.
+type RedisRateLimiter struct {
+	client  *redis.Client
+	options rateLimitOptions
+}
+
+// NewRedisRateLimiter creates a new instance of RedisRateLimiter
+func NewRedisRateLimiter(client *redis.Client, opts ...RateLimitOption) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		client:  client,
+		options: newRateLimitOptions(opts),
+	}
+}
+
+// Take takes cost tokens from the bucket of key when it holds enough of them
+func (l *RedisRateLimiter) Take(ctx context.Context, key string, cost int) (limit model.RateLimit, err error) {
+	ctx, span := startRedisSpan(ctx, "redis.ratelimit.take", "EVALSHA")
+	defer func() { tracing.End(span, err) }()
+
+	cost = l.options.cost(cost)
+	ctx, cancel := withTimeout(ctx, l.options.timeout)
+	defer cancel()
+
+	res, err := takeTokensScript.Run(ctx, l.client, []string{l.options.keyPrefix + key},
+		l.options.capacity, l.options.rate/1000, l.options.now().UnixMilli(), cost).Slice()
+	if err != nil {
+		return limit, err
+	}
+	if len(res) != 2 {
+		return limit, fmt.Errorf("unexpected rate limit script result: %v", res)
+	}
+	allowed, _ := res[0].(int64)
+	remaining, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(remaining, 64)
+	if err != nil {
+		return limit, fmt.Errorf("invalid rate limit tokens %q: %w", remaining, err)
+	}
+	return l.options.rateLimit(allowed == 1, tokens, cost), nil
+}
+
+// CheckReadiness returns the readiness of the limiter, unavailable when Redis does not answer a ping
+func (l *RedisRateLimiter) CheckReadiness(ctx context.Context) model.ComponentHealth {
+	return pingReadiness(ctx, l.client, l.options.timeout, "ratelimit-redis")
+}
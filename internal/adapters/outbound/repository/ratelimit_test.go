@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+func TestRateLimiters_Take(t *testing.T) {
+	type take struct {
+		after time.Duration
+		key   string
+		cost  int
+		want  model.RateLimit
+	}
+	tests := []struct {
+		name  string
+		takes []take
+	}{
+		{
+			name: "Bucket emptied then refilled",
+			takes: []take{
+				{key: "a", cost: 6, want: model.RateLimit{Allowed: true, Limit: 10, Remaining: 4, Reset: 3 * time.Second}},
+				{key: "a", cost: 4, want: model.RateLimit{Allowed: true, Limit: 10, Remaining: 0, Reset: 5 * time.Second}},
+				{key: "a", cost: 1, want: model.RateLimit{Allowed: false, Limit: 10, Remaining: 0, Reset: 5 * time.Second, RetryAfter: 500 * time.Millisecond}},
+				{after: time.Second, key: "a", cost: 1, want: model.RateLimit{Allowed: true, Limit: 10, Remaining: 1, Reset: 4500 * time.Millisecond}},
+			},
+		},
+		{
+			name: "Buckets per key",
+			takes: []take{
+				{key: "a", cost: 10, want: model.RateLimit{Allowed: true, Limit: 10, Remaining: 0, Reset: 5 * time.Second}},
+				{key: "b", cost: 1, want: model.RateLimit{Allowed: true, Limit: 10, Remaining: 9, Reset: 500 * time.Millisecond}},
+			},
+		},
+		{
+			name: "Cost bounded by the capacity",
+			takes: []take{
+				{key: "a", cost: 50, want: model.RateLimit{Allowed: true, Limit: 10, Remaining: 0, Reset: 5 * time.Second}},
+				{after: time.Second, key: "a", cost: 50, want: model.RateLimit{Allowed: false, Limit: 10, Remaining: 2, Reset: 4 * time.Second, RetryAfter: 4 * time.Second}},
+				{after: 4 * time.Second, key: "a", cost: 0, want: model.RateLimit{Allowed: true, Limit: 10, Remaining: 9, Reset: 500 * time.Millisecond}},
+			},
+		},
+	}
+	limiters := map[string]func(opts ...RateLimitOption) adapters.RateLimiter{
+		"InMemory": func(opts ...RateLimitOption) adapters.RateLimiter { return NewInMemoryRateLimiter(opts...) },
+		"Redis": func(opts ...RateLimitOption) adapters.RateLimiter {
+			return NewRedisRateLimiter(redisClient, opts...)
+		},
+	}
+	for limiterName, newLimiter := range limiters {
+		for _, tt := range tests {
+			t.Run(limiterName+"/"+tt.name, func(t *testing.T) {
+				redisClient.FlushAll(context.Background())
+				now := time.Unix(1700000000, 0)
+				limiter := newLimiter(WithRateLimitCapacity(10), WithRateLimitRate(2),
+					WithRateLimitClock(func() time.Time { return now }))
+
+				for i, take := range tt.takes {
+					now = now.Add(take.after)
+					got, err := limiter.Take(context.Background(), take.key, take.cost)
+					if err != nil {
+						t.Fatalf("Take() #%d error = %v", i, err)
+					}
+					if got != take.want {
+						t.Errorf("Take() #%d got = %+v, want %+v", i, got, take.want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestInMemoryRateLimiter_DropsFullBuckets(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewInMemoryRateLimiter(WithRateLimitCapacity(10), WithRateLimitRate(2),
+		WithRateLimitClock(func() time.Time { return now }))
+
+	_, _ = limiter.Take(context.Background(), "a", 10)
+	now = now.Add(time.Second)
+	_, _ = limiter.Take(context.Background(), "b", 1)
+	if got := limiter.Len(); got != 2 {
+		t.Fatalf("Len() got = %d, want 2", got)
+	}
+
+	// Once an empty bucket has had the time to fill, the full buckets are dropped
+	now = now.Add(5 * time.Second)
+	_, _ = limiter.Take(context.Background(), "c", 1)
+	if got := limiter.Len(); got != 1 {
+		t.Errorf("Len() got = %d, want only the bucket taken from", got)
+	}
+}
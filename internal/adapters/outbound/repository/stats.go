@@ -1,6 +1,9 @@
 package repository
 
 import (
+	"context"
+	"time"
+
 	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
 	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
 )
@@ -10,13 +13,116 @@ type StorageType string
 const (
 	StorageTypeInMemory StorageType = "in-memory"
 	StorageTypeRedis    StorageType = "redis"
+	// StorageTypeFile is only supported by the API key store
+	StorageTypeFile StorageType = "file"
 )
 
+const (
+	// StatsBucketSize is the period covered by a bucket of the time-windowed statistics
+	StatsBucketSize = time.Hour
+	// DefaultStatsRetention is how long the buckets of the time-windowed statistics are kept by default
+	DefaultStatsRetention = 7 * 24 * time.Hour
+	// DefaultRedisTimeout is the default timeout of a Redis operation
+	DefaultRedisTimeout = time.Second
+)
+
+// StatsOption configures a stats repository
+type StatsOption func(*statsOptions)
+
+type statsOptions struct {
+	namespace string
+	retention time.Duration
+	timeout   time.Duration
+	now       func() time.Time
+	start     []model.StatsResult
+}
+
+func newStatsOptions(opts []StatsOption) statsOptions {
+	options := statsOptions{
+		namespace: RedisKeyStats,
+		retention: DefaultStatsRetention,
+		timeout:   DefaultRedisTimeout,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// WithRetention sets how long the buckets of the time-windowed statistics are kept
+func WithRetention(retention time.Duration) StatsOption {
+	return func(o *statsOptions) {
+		if retention > 0 {
+			o.retention = retention
+		}
+	}
+}
+
+// WithTimeout sets the timeout of each operation of the Redis repository, 0 disables it
+func WithTimeout(timeout time.Duration) StatsOption {
+	return func(o *statsOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithNamespace sets the Redis key of the all-time statistics, the keys of the buckets starting with it
+func WithNamespace(namespace string) StatsOption {
+	return func(o *statsOptions) {
+		if namespace != "" {
+			o.namespace = namespace
+		}
+	}
+}
+
+// WithClock sets the function returning the current time of the requests
+func WithClock(now func() time.Time) StatsOption {
+	return func(o *statsOptions) {
+		o.now = now
+	}
+}
+
+// WithInitialStats seeds the all-time statistics of the in-memory repository
+func WithInitialStats(start ...model.StatsResult) StatsOption {
+	return func(o *statsOptions) {
+		o.start = append(o.start, start...)
+	}
+}
+
+// withTimeout returns a context cancelled after timeout, or only when ctx is if timeout is 0
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// bucketStart returns the start of the statistics bucket containing t
+func bucketStart(t time.Time) time.Time {
+	return t.Truncate(StatsBucketSize)
+}
+
+// bucketsBetween returns the start of the buckets overlapping the range between from and to,
+// ignoring the ones older than the retention and the ones after the current one
+func bucketsBetween(from, to, now time.Time, retention time.Duration) []time.Time {
+	if oldest := bucketStart(now.Add(-retention)); from.Before(oldest) {
+		from = oldest
+	}
+	if newest := bucketStart(now).Add(StatsBucketSize); to.After(newest) {
+		to = newest
+	}
+	var starts []time.Time
+	for start := bucketStart(from); start.Before(to); start = start.Add(StatsBucketSize) {
+		starts = append(starts, start)
+	}
+	return starts
+}
+
 func GetStatsRepository(create func() adapters.StatsRepository) adapters.StatsRepository {
 	if create != nil {
 		return create()
 	} else {
-		return NewInMemoryStatsRepository(make(map[model.FizzBuzzRequest]int))
+		return NewInMemoryStatsRepository()
 	}
 
 }
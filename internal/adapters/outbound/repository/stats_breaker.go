@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+	"github.com/sony/gobreaker"
+)
+
+var (
+	_ adapters.StatsRepository = (*StatsBreaker)(nil)
+	_ adapters.HealthReporter  = (*StatsBreaker)(nil)
+)
+
+// StatsBreaker protects a stats repository with a circuit breaker. The hits which cannot be written are
+// buffered in memory, up to a number of distinct requests, and replayed once the repository recovers.
+// The other operations fail with model.ErrStatsUnavailable while the breaker is open.
+type StatsBreaker struct {
+	repo    adapters.StatsRepository
+	breaker *gobreaker.CircuitBreaker
+
+	mu         sync.Mutex
+	buffer     map[string]*model.RequestCount
+	bufferSize int
+	dropped    atomic.Uint64
+	// pending is set while the buffer may hold hits, sparing the lock to the successful writes
+	pending   atomic.Bool
+	replaying atomic.Bool
+}
+
+// NewStatsBreaker creates a new instance of StatsBreaker protecting repo
+func NewStatsBreaker(repo adapters.StatsRepository, opts ...BreakerOption) *StatsBreaker {
+	options := newBreakerOptions(opts)
+	return &StatsBreaker{
+		repo:       repo,
+		breaker:    newCircuitBreaker("stats", options),
+		buffer:     make(map[string]*model.RequestCount),
+		bufferSize: options.bufferSize,
+	}
+}
+
+// GetMostFrequentRequest returns the most frequent request parameters and their hit count
+func (s *StatsBreaker) GetMostFrequentRequest(ctx context.Context) (*model.StatsResult, error) {
+	return execute(s.breaker, model.ErrStatsUnavailable, func() (*model.StatsResult, error) {
+		return s.repo.GetMostFrequentRequest(ctx)
+	})
+}
+
+// GetMostFrequentRequestBetween returns the most frequent request parameters and their hit count between from and to
+func (s *StatsBreaker) GetMostFrequentRequestBetween(ctx context.Context, from, to time.Time) (*model.StatsResult, error) {
+	return execute(s.breaker, model.ErrStatsUnavailable, func() (*model.StatsResult, error) {
+		return s.repo.GetMostFrequentRequestBetween(ctx, from, to)
+	})
+}
+
+// GetRequestsByHits returns count requests parameters ordered by hit count, skipping the first offset ones
+func (s *StatsBreaker) GetRequestsByHits(ctx context.Context, offset, count int) (*model.StatsPage, error) {
+	return execute(s.breaker, model.ErrStatsUnavailable, func() (*model.StatsPage, error) {
+		return s.repo.GetRequestsByHits(ctx, offset, count)
+	})
+}
+
+// IncrementRequestCount increments the count for a specific request parameters, buffering it when it fails
+func (s *StatsBreaker) IncrementRequestCount(ctx context.Context, request model.FizzBuzzRequest) error {
+	return s.AddRequestCounts(ctx, []model.RequestCount{{Request: request, Count: 1}})
+}
+
+// AddRequestCounts adds the hits of several request parameters at once, buffering them when it fails.
+// A successful write replays the buffered hits.
+func (s *StatsBreaker) AddRequestCounts(ctx context.Context, counts []model.RequestCount) error {
+	_, err := execute(s.breaker, model.ErrStatsUnavailable, func() (any, error) {
+		return nil, s.repo.AddRequestCounts(ctx, counts)
+	})
+	if err != nil {
+		s.bufferCounts(counts)
+		return nil
+	}
+
+	s.replay(ctx)
+	return nil
+}
+
+// RemoveRequest removes the statistics of a specific request parameters, buffered hits included
+func (s *StatsBreaker) RemoveRequest(ctx context.Context, request model.FizzBuzzRequest) (bool, error) {
+	removed, err := execute(s.breaker, model.ErrStatsUnavailable, func() (bool, error) {
+		return s.repo.RemoveRequest(ctx, request)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	_, buffered := s.buffer[request.Key()]
+	delete(s.buffer, request.Key())
+	s.mu.Unlock()
+	return removed || buffered, nil
+}
+
+// ResetStats resets the statistics data, buffered hits included
+func (s *StatsBreaker) ResetStats(ctx context.Context) error {
+	_, err := execute(s.breaker, model.ErrStatsUnavailable, func() (any, error) {
+		return nil, s.repo.ResetStats(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.buffer = make(map[string]*model.RequestCount)
+	s.mu.Unlock()
+	return nil
+}
+
+// Health returns the state of the circuit breaker and of the buffer
+func (s *StatsBreaker) Health() model.ComponentHealth {
+	health := breakerHealth(s.breaker)
+
+	s.mu.Lock()
+	health.BufferedRequests = len(s.buffer)
+	s.mu.Unlock()
+	health.DroppedHits = s.dropped.Load()
+	return health
+}
+
+// bufferCounts adds hits to the buffer, dropping the ones of new requests when it is full
+func (s *StatsBreaker) bufferCounts(counts []model.RequestCount) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, count := range counts {
+		key := count.Request.Key()
+		if buffered, ok := s.buffer[key]; ok {
+			buffered.Count += count.Count
+			continue
+		}
+		if len(s.buffer) >= s.bufferSize {
+			s.dropped.Add(uint64(count.Count))
+			continue
+		}
+		s.buffer[key] = &model.RequestCount{Request: count.Request, Count: count.Count}
+	}
+	s.pending.Store(len(s.buffer) > 0)
+}
+
+// replay writes the buffered hits, buffering them again when it fails. Only one replay runs at a time.
+func (s *StatsBreaker) replay(ctx context.Context) {
+	if !s.pending.Load() || !s.replaying.CompareAndSwap(false, true) {
+		return
+	}
+	defer s.replaying.Store(false)
+
+	s.mu.Lock()
+	counts := make([]model.RequestCount, 0, len(s.buffer))
+	for _, buffered := range s.buffer {
+		counts = append(counts, *buffered)
+	}
+	s.buffer = make(map[string]*model.RequestCount)
+	s.pending.Store(false)
+	s.mu.Unlock()
+	if len(counts) == 0 {
+		return
+	}
+
+	// The replay is not abandoned when the request which triggered it goes away
+	ctx = context.WithoutCancel(ctx)
+	_, err := execute(s.breaker, model.ErrStatsUnavailable, func() (any, error) {
+		return nil, s.repo.AddRequestCounts(ctx, counts)
+	})
+	if err != nil {
+		s.bufferCounts(counts)
+	}
+}
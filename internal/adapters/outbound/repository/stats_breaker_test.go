@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+var errRedisDown = errors.New("redis down")
+
+// flakyStats is an in-memory stats repository failing while down is set
+type flakyStats struct {
+	*InMemoryStatsRepository
+	down  atomic.Bool
+	calls atomic.Int64
+}
+
+func (f *flakyStats) GetMostFrequentRequest(ctx context.Context) (*model.StatsResult, error) {
+	f.calls.Add(1)
+	if f.down.Load() {
+		return nil, errRedisDown
+	}
+	return f.InMemoryStatsRepository.GetMostFrequentRequest(ctx)
+}
+
+func (f *flakyStats) AddRequestCounts(ctx context.Context, counts []model.RequestCount) error {
+	f.calls.Add(1)
+	if f.down.Load() {
+		return errRedisDown
+	}
+	return f.InMemoryStatsRepository.AddRequestCounts(ctx, counts)
+}
+
+func (f *flakyStats) ResetStats(ctx context.Context) error {
+	f.calls.Add(1)
+	if f.down.Load() {
+		return errRedisDown
+	}
+	return f.InMemoryStatsRepository.ResetStats(ctx)
+}
+
+func TestStatsBreaker_BuffersAndReplays(t *testing.T) {
+	fizzBuzz := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	fooBar := model.FizzBuzzRequest{Int1: 2, Int2: 7, Limit: 20, Str1: "Foo", Str2: "Bar"}
+
+	repo := &flakyStats{InMemoryStatsRepository: NewInMemoryStatsRepository()}
+	s := NewStatsBreaker(repo, WithBreakerFailures(2), WithBreakerOpenTimeout(20*time.Millisecond))
+
+	repo.down.Store(true)
+	for _, request := range []model.FizzBuzzRequest{fizzBuzz, fizzBuzz, fizzBuzz, fooBar} {
+		if err := s.IncrementRequestCount(context.Background(), request); err != nil {
+			t.Fatalf("IncrementRequestCount() error = %v, want the hit buffered", err)
+		}
+	}
+	if got := repo.calls.Load(); got != 2 {
+		t.Errorf("repository calls = %d, want 2 before the breaker opens", got)
+	}
+	health := s.Health()
+	if health.Status != model.HealthStatusDegraded || health.Breaker != "open" || health.BufferedRequests != 2 {
+		t.Errorf("Health() got = %+v, want degraded with 2 buffered requests", health)
+	}
+	if _, err := s.GetMostFrequentRequest(context.Background()); !errors.Is(err, model.ErrStatsUnavailable) {
+		t.Errorf("GetMostFrequentRequest() error = %v, want %v", err, model.ErrStatsUnavailable)
+	}
+
+	repo.down.Store(false)
+	time.Sleep(30 * time.Millisecond)
+	if err := s.IncrementRequestCount(context.Background(), fooBar); err != nil {
+		t.Fatalf("IncrementRequestCount() error = %v", err)
+	}
+
+	health = s.Health()
+	if health.Status != model.HealthStatusOK || health.BufferedRequests != 0 {
+		t.Errorf("Health() got = %+v, want ok with an empty buffer", health)
+	}
+	got, err := s.GetMostFrequentRequest(context.Background())
+	if err != nil {
+		t.Fatalf("GetMostFrequentRequest() error = %v", err)
+	}
+	if want := model.NewStatsResult(fizzBuzz, 3); got.Hits != want.Hits || got.Request().Key() != fizzBuzz.Key() {
+		t.Errorf("GetMostFrequentRequest() got = %+v, want %+v", got, want)
+	}
+	page, _ := s.GetRequestsByHits(context.Background(), 0, 10)
+	if page.Total != 2 || page.Results[1].Hits != 2 {
+		t.Errorf("GetRequestsByHits() got = %+v, want the buffered and the new hits of %s", page, fooBar.Key())
+	}
+}
+
+func TestStatsBreaker_BufferSize(t *testing.T) {
+	repo := &flakyStats{InMemoryStatsRepository: NewInMemoryStatsRepository()}
+	repo.down.Store(true)
+	s := NewStatsBreaker(repo, WithStatsBufferSize(2))
+
+	for limit := 1; limit <= 4; limit++ {
+		request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: limit, Str1: "Fizz", Str2: "Buzz"}
+		_ = s.AddRequestCounts(context.Background(), []model.RequestCount{{Request: request, Count: 2}})
+	}
+	// Hits of already buffered requests are still counted once the buffer is full
+	_ = s.IncrementRequestCount(context.Background(), model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 1, Str1: "Fizz", Str2: "Buzz"})
+
+	health := s.Health()
+	if health.BufferedRequests != 2 || health.DroppedHits != 4 {
+		t.Errorf("Health() got = %+v, want 2 buffered requests and 4 dropped hits", health)
+	}
+}
+
+func TestStatsBreaker_ResetStats(t *testing.T) {
+	repo := &flakyStats{InMemoryStatsRepository: NewInMemoryStatsRepository()}
+	s := NewStatsBreaker(repo, WithBreakerFailures(1))
+
+	repo.down.Store(true)
+	_ = s.IncrementRequestCount(context.Background(), model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"})
+	if err := s.ResetStats(context.Background()); !errors.Is(err, model.ErrStatsUnavailable) {
+		t.Errorf("ResetStats() error = %v, want %v", err, model.ErrStatsUnavailable)
+	}
+	if got := s.Health().BufferedRequests; got != 1 {
+		t.Errorf("Health() buffered requests = %d, want the buffer kept", got)
+	}
+}
+
+func TestStatsBreaker_CancelledRequestsDoNotOpen(t *testing.T) {
+	repo := &flakyStats{InMemoryStatsRepository: NewInMemoryStatsRepository()}
+	s := NewStatsBreaker(&cancelledStats{repo}, WithBreakerFailures(1))
+
+	_, _ = s.GetMostFrequentRequest(context.Background())
+	if got := s.Health(); got.Status != model.HealthStatusOK {
+		t.Errorf("Health() got = %+v, want the breaker closed", got)
+	}
+}
+
+// cancelledStats fails the reads as if their context was cancelled
+type cancelledStats struct {
+	*flakyStats
+}
+
+func (c *cancelledStats) GetMostFrequentRequest(ctx context.Context) (*model.StatsResult, error) {
+	return nil, context.Canceled
+}
@@ -1,64 +1,494 @@
 package repository
 
 import (
+	"cmp"
+	"container/heap"
+	"context"
+	"hash/maphash"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
 	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
 )
 
-var _ adapters.StatsRepository = (*InMemoryStatsRepository)(nil)
+var (
+	_ adapters.StatsRepository  = (*InMemoryStatsRepository)(nil)
+	_ adapters.ReadinessChecker = (*InMemoryStatsRepository)(nil)
+)
+
+const (
+	// statsShardCount is the number of shards the hit counters are spread over
+	statsShardCount = 32
+	// topRequestsSize is the number of most frequent requests kept ordered, the maximum of /stats/top
+	topRequestsSize = 100
+)
+
+// requestHits holds the hit count of a request parameters
+type requestHits struct {
+	key     string
+	request model.FizzBuzzRequest
+	hits    atomic.Int64
+	removed atomic.Bool
+	inTop   atomic.Bool
+
+	// topHits and topIndex are guarded by the topRequests lock
+	topHits  int64
+	topIndex int
+}
+
+// rankedAbove reports whether the entry is ranked above the other one in the top requests
+func (e *requestHits) rankedAbove(other *requestHits) bool {
+	return compareRank(e.topHits, e.key, other.topHits, other.key) < 0
+}
+
+// rankedRequest is a snapshot of the hit count of a request parameters
+type rankedRequest struct {
+	key     string
+	request model.FizzBuzzRequest
+	hits    int64
+}
+
+// compareRank orders requests by hits then by key, so the order is deterministic
+func compareRank(hitsA int64, keyA string, hitsB int64, keyB string) int {
+	if hitsA != hitsB {
+		return cmp.Compare(hitsB, hitsA)
+	}
+	return strings.Compare(keyA, keyB)
+}
+
+// sortRanked sorts the requests from the most to the least frequent
+func sortRanked(requests []rankedRequest) {
+	slices.SortFunc(requests, func(a, b rankedRequest) int {
+		return compareRank(a.hits, a.key, b.hits, b.key)
+	})
+}
+
+// statsShard is a lock protected part of the hit counters
+type statsShard struct {
+	mu    sync.RWMutex
+	stats map[string]*requestHits
+}
+
+// hitCounters holds the hit counts of request parameters spread over shards
+type hitCounters struct {
+	seed   maphash.Seed
+	shards [statsShardCount]statsShard
+}
+
+func newHitCounters() *hitCounters {
+	counters := &hitCounters{seed: maphash.MakeSeed()}
+	for i := range counters.shards {
+		counters.shards[i].stats = make(map[string]*requestHits)
+	}
+	return counters
+}
+
+func (c *hitCounters) shard(key string) *statsShard {
+	return &c.shards[maphash.String(c.seed, key)%statsShardCount]
+}
+
+// counter returns the entry of the request parameters, creating it if needed
+func (c *hitCounters) counter(key string, request model.FizzBuzzRequest) *requestHits {
+	shard := c.shard(key)
+
+	shard.mu.RLock()
+	entry, ok := shard.stats[key]
+	shard.mu.RUnlock()
+	if ok {
+		return entry
+	}
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if entry, ok = shard.stats[key]; !ok {
+		entry = &requestHits{key: key, request: request, topIndex: -1}
+		shard.stats[key] = entry
+	}
+	return entry
+}
+
+// remove removes the entry of the request parameters
+func (c *hitCounters) remove(key string) (*requestHits, bool) {
+	shard := c.shard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	entry, ok := shard.stats[key]
+	delete(shard.stats, key)
+	return entry, ok
+}
 
-// InMemoryStatsRepository is an in-memory implementation of StatsRepository
+// entries returns every entry of the counters
+func (c *hitCounters) entries() []*requestHits {
+	var entries []*requestHits
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.mu.RLock()
+		for _, entry := range shard.stats {
+			entries = append(entries, entry)
+		}
+		shard.mu.RUnlock()
+	}
+	return entries
+}
+
+// len returns the number of distinct requests of the counters
+func (c *hitCounters) len() int {
+	total := 0
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.mu.RLock()
+		total += len(shard.stats)
+		shard.mu.RUnlock()
+	}
+	return total
+}
+
+// statsState holds the all-time hit counters, the top requests and the hit counters
+// of the time buckets, replaced as a whole on reset
+type statsState struct {
+	counters *hitCounters
+	top      *topRequests
+
+	bucketsMu sync.RWMutex
+	buckets   map[int64]*hitCounters
+}
+
+func newStatsState() *statsState {
+	return &statsState{
+		counters: newHitCounters(),
+		top:      newTopRequests(topRequestsSize),
+		buckets:  make(map[int64]*hitCounters),
+	}
+}
+
+// bucket returns the counters of the bucket starting at the start Unix time, creating it if
+// needed. Creating a bucket drops the ones starting before the oldest Unix time.
+func (s *statsState) bucket(start, oldest int64) *hitCounters {
+	s.bucketsMu.RLock()
+	bucket, ok := s.buckets[start]
+	s.bucketsMu.RUnlock()
+	if ok {
+		return bucket
+	}
+
+	s.bucketsMu.Lock()
+	defer s.bucketsMu.Unlock()
+	if bucket, ok = s.buckets[start]; !ok {
+		bucket = newHitCounters()
+		s.buckets[start] = bucket
+		for t := range s.buckets {
+			if t < oldest {
+				delete(s.buckets, t)
+			}
+		}
+	}
+	return bucket
+}
+
+// InMemoryStatsRepository is an in-memory implementation of StatsRepository, safe for concurrent use.
+// Hit counters are sharded so increments of different requests do not contend, and the most
+// frequent requests are kept in a heap so reading them does not scan every request.
+// Hits are also counted in time buckets of StatsBucketSize for the time-windowed statistics.
 type InMemoryStatsRepository struct {
-	stats map[model.FizzBuzzRequest]int
+	state     atomic.Pointer[statsState]
+	retention time.Duration
+	now       func() time.Time
 }
 
 // NewInMemoryStatsRepository creates a new InMemoryStatsRepository instance
-func NewInMemoryStatsRepository(start map[model.FizzBuzzRequest]int) *InMemoryStatsRepository {
-	return &InMemoryStatsRepository{
-		stats: start,
+func NewInMemoryStatsRepository(opts ...StatsOption) *InMemoryStatsRepository {
+	options := newStatsOptions(opts)
+	r := &InMemoryStatsRepository{
+		retention: options.retention,
+		now:       options.now,
 	}
+	r.state.Store(newStatsState())
+	for _, result := range options.start {
+		r.increment(r.state.Load(), result.Request(), int64(result.Hits))
+	}
+	return r
 }
 
 // GetMostFrequentRequest returns the most frequent request parameters and their hit count
-func (r *InMemoryStatsRepository) GetMostFrequentRequest() (stats *model.StatsResult, err error) {
-
-	var maxHits int
-	var key model.FizzBuzzRequest
-	for request, hits := range r.stats {
-		if hits > maxHits {
-			key = request
-			maxHits = hits
+func (r *InMemoryStatsRepository) GetMostFrequentRequest(ctx context.Context) (stats *model.StatsResult, err error) {
+	best, ok := r.state.Load().top.first()
+	if ok && best.hits > 0 {
+		return model.NewStatsResult(best.request, int(best.hits)), nil
+	}
+
+	return stats, nil
+}
+
+// GetMostFrequentRequestBetween returns the most frequent request parameters and their hit count
+// between from and to, at the precision of the statistics buckets
+func (r *InMemoryStatsRepository) GetMostFrequentRequestBetween(ctx context.Context, from, to time.Time) (stats *model.StatsResult, err error) {
+	state := r.state.Load()
+
+	ranked := make(map[string]*rankedRequest)
+	state.bucketsMu.RLock()
+	for _, start := range bucketsBetween(from, to, r.now(), r.retention) {
+		bucket, ok := state.buckets[start.Unix()]
+		if !ok {
+			continue
+		}
+		for _, entry := range bucket.entries() {
+			if _, ok := ranked[entry.key]; !ok {
+				ranked[entry.key] = &rankedRequest{key: entry.key, request: entry.request}
+			}
+			ranked[entry.key].hits += entry.hits.Load()
+		}
+	}
+	state.bucketsMu.RUnlock()
+
+	var best *rankedRequest
+	for _, request := range ranked {
+		if best == nil || compareRank(request.hits, request.key, best.hits, best.key) < 0 {
+			best = request
 		}
 	}
-	if maxHits > 0 {
-		return &model.StatsResult{
-			Int1:  key.Int1,
-			Int2:  key.Int2,
-			Limit: key.Limit,
-			Str1:  key.Str1,
-			Str2:  key.Str2,
-			Hits:  maxHits,
-		}, nil
+	if best != nil && best.hits > 0 {
+		return model.NewStatsResult(best.request, int(best.hits)), nil
 	}
 
 	return stats, nil
 }
 
+// GetRequestsByHits returns count requests parameters ordered by hit count, skipping the first offset ones
+func (r *InMemoryStatsRepository) GetRequestsByHits(ctx context.Context, offset, count int) (page *model.StatsPage, err error) {
+	state := r.state.Load()
+
+	var ranked []rankedRequest
+	if offset+count <= topRequestsSize {
+		ranked = state.top.sorted()
+	} else {
+		for _, entry := range state.counters.entries() {
+			ranked = append(ranked, rankedRequest{key: entry.key, request: entry.request, hits: entry.hits.Load()})
+		}
+		sortRanked(ranked)
+	}
+
+	page = &model.StatsPage{
+		Results: []model.StatsResult{},
+		Total:   state.counters.len(),
+	}
+	for i := offset; i >= 0 && i < len(ranked) && i < offset+count; i++ {
+		page.Results = append(page.Results, *model.NewStatsResult(ranked[i].request, int(ranked[i].hits)))
+	}
+	return page, nil
+}
+
 // IncrementRequestCount increments the count for a specific request parameters
-func (r *InMemoryStatsRepository) IncrementRequestCount(int1, int2, limit int, str1, str2 string) error {
-	request := model.FizzBuzzRequest{
-		Int1:  int1,
-		Int2:  int2,
-		Limit: limit,
-		Str1:  str1,
-		Str2:  str2,
-	}
-	r.stats[request]++
+func (r *InMemoryStatsRepository) IncrementRequestCount(ctx context.Context, request model.FizzBuzzRequest) error {
+	return r.AddRequestCounts(ctx, []model.RequestCount{{Request: request, Count: 1}})
+}
+
+// AddRequestCounts adds the hits of several request parameters at once
+func (r *InMemoryStatsRepository) AddRequestCounts(ctx context.Context, counts []model.RequestCount) error {
+	state := r.state.Load()
+	now := r.now()
+	bucket := state.bucket(bucketStart(now).Unix(), bucketStart(now.Add(-r.retention)).Unix())
+
+	for _, count := range counts {
+		r.increment(state, count.Request, count.Count)
+		bucket.counter(count.Request.Key(), count.Request).hits.Add(count.Count)
+	}
 	return nil
 }
 
+// increment adds hits to the all-time count of the request parameters
+func (r *InMemoryStatsRepository) increment(state *statsState, request model.FizzBuzzRequest, hits int64) {
+	entry := state.counters.counter(request.Key(), request)
+	state.top.update(entry, entry.hits.Add(hits))
+}
+
+// RemoveRequest removes the statistics of a specific request parameters
+func (r *InMemoryStatsRepository) RemoveRequest(ctx context.Context, request model.FizzBuzzRequest) (removed bool, err error) {
+	state := r.state.Load()
+	key := request.Key()
+
+	state.bucketsMu.RLock()
+	for _, bucket := range state.buckets {
+		bucket.remove(key)
+	}
+	state.bucketsMu.RUnlock()
+
+	entry, ok := state.counters.remove(key)
+	if !ok {
+		return false, nil
+	}
+
+	state.top.remove(entry, state.counters.entries)
+	return true, nil
+}
+
 // ResetStats resets the statistics data
-func (r *InMemoryStatsRepository) ResetStats() error {
-	r.stats = make(map[model.FizzBuzzRequest]int)
+func (r *InMemoryStatsRepository) ResetStats(ctx context.Context) error {
+	r.state.Store(newStatsState())
 	return nil
 }
+
+// CheckReadiness returns the readiness of the repository, unavailable unless it was created by NewInMemoryStatsRepository
+func (r *InMemoryStatsRepository) CheckReadiness(context.Context) model.ComponentHealth {
+	if r.state.Load() == nil {
+		return model.ComponentHealth{Name: "stats-memory", Status: model.HealthStatusUnavailable,
+			Error: "statistics state not initialized"}
+	}
+	return model.ComponentHealth{Name: "stats-memory", Status: model.HealthStatusOK}
+}
+
+// topRequests keeps the requests with the most hits in a min-heap bounded to size entries, so the
+// least frequent of them is evicted in O(log size) when another request overtakes it.
+// It implements heap.Interface and must be used through its methods.
+type topRequests struct {
+	mu      sync.Mutex
+	size    int
+	entries []*requestHits
+	best    *requestHits
+	// minHits is the hit count of the least frequent entry once the heap is full,
+	// so increments of requests that cannot enter the heap skip the lock
+	minHits atomic.Int64
+}
+
+func newTopRequests(size int) *topRequests {
+	return &topRequests{
+		size:    size,
+		entries: make([]*requestHits, 0, size),
+	}
+}
+
+func (t *topRequests) Len() int { return len(t.entries) }
+
+func (t *topRequests) Less(i, j int) bool { return t.entries[j].rankedAbove(t.entries[i]) }
+
+func (t *topRequests) Swap(i, j int) {
+	t.entries[i], t.entries[j] = t.entries[j], t.entries[i]
+	t.entries[i].topIndex = i
+	t.entries[j].topIndex = j
+}
+
+func (t *topRequests) Push(x any) {
+	entry := x.(*requestHits)
+	entry.topIndex = len(t.entries)
+	t.entries = append(t.entries, entry)
+}
+
+func (t *topRequests) Pop() any {
+	entry := t.entries[len(t.entries)-1]
+	t.entries = t.entries[:len(t.entries)-1]
+	entry.topIndex = -1
+	return entry
+}
+
+// update records that the entry reached hits, admitting it in the heap if it overtakes the least frequent entry
+func (t *topRequests) update(entry *requestHits, hits int64) {
+	if hits < t.minHits.Load() && !entry.inTop.Load() {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if entry.removed.Load() || hits <= entry.topHits {
+		return
+	}
+	entry.topHits = hits
+
+	switch {
+	case entry.topIndex >= 0:
+		heap.Fix(t, entry.topIndex)
+	case len(t.entries) < t.size:
+		heap.Push(t, entry)
+	case entry.rankedAbove(t.entries[0]):
+		evicted := t.entries[0]
+		evicted.topIndex = -1
+		evicted.inTop.Store(false)
+		t.entries[0] = entry
+		entry.topIndex = 0
+		heap.Fix(t, 0)
+	default:
+		return
+	}
+	entry.inTop.Store(true)
+
+	if len(t.entries) == t.size {
+		t.minHits.Store(t.entries[0].topHits)
+	}
+	if t.best == nil || entry.rankedAbove(t.best) {
+		t.best = entry
+	}
+}
+
+// remove removes the entry from the heap. The next most frequent request is not tracked,
+// so the heap is then rebuilt from all the entries.
+func (t *topRequests) remove(entry *requestHits, entries func() []*requestHits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Once marked under the lock, the entry can no longer enter the heap
+	entry.removed.Store(true)
+	if entry.topIndex < 0 {
+		return
+	}
+
+	for _, e := range t.entries {
+		e.topIndex = -1
+		e.inTop.Store(false)
+	}
+	t.entries = t.entries[:0]
+	t.best = nil
+	t.minHits.Store(0)
+
+	for _, e := range entries() {
+		if e.removed.Load() {
+			continue
+		}
+		e.topHits = e.hits.Load()
+		if len(t.entries) < t.size {
+			heap.Push(t, e)
+		} else if e.rankedAbove(t.entries[0]) {
+			t.entries[0].topIndex = -1
+			t.entries[0] = e
+			e.topIndex = 0
+			heap.Fix(t, 0)
+		}
+	}
+
+	for _, e := range t.entries {
+		e.inTop.Store(true)
+		if t.best == nil || e.rankedAbove(t.best) {
+			t.best = e
+		}
+	}
+	if len(t.entries) == t.size {
+		t.minHits.Store(t.entries[0].topHits)
+	}
+}
+
+// first returns a snapshot of the most frequent entry
+func (t *topRequests) first() (rankedRequest, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.best == nil {
+		return rankedRequest{}, false
+	}
+	return rankedRequest{key: t.best.key, request: t.best.request, hits: t.best.topHits}, true
+}
+
+// sorted returns a snapshot of the entries of the heap from the most to the least frequent
+func (t *topRequests) sorted() []rankedRequest {
+	t.mu.Lock()
+	ranked := make([]rankedRequest, 0, len(t.entries))
+	for _, entry := range t.entries {
+		ranked = append(ranked, rankedRequest{key: entry.key, request: entry.request, hits: entry.topHits})
+	}
+	t.mu.Unlock()
+
+	sortRanked(ranked)
+	return ranked
+}
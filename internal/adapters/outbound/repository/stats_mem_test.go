@@ -1,15 +1,19 @@
 package repository
 
 import (
+	"context"
+	"fmt"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
 )
 
 func TestInMemoryStatsRepository_GetMostFrequentRequest(t *testing.T) {
 	type fields struct {
-		stats map[model.FizzBuzzRequest]int
+		stats []model.StatsResult
 	}
 	tests := []struct {
 		name      string
@@ -20,7 +24,7 @@ func TestInMemoryStatsRepository_GetMostFrequentRequest(t *testing.T) {
 		{
 			name: "No requests",
 			fields: fields{
-				stats: make(map[model.FizzBuzzRequest]int),
+				stats: nil,
 			},
 			wantStats: nil,
 			wantErr:   false,
@@ -28,8 +32,8 @@ func TestInMemoryStatsRepository_GetMostFrequentRequest(t *testing.T) {
 		{
 			name: "Single request",
 			fields: fields{
-				stats: map[model.FizzBuzzRequest]int{
-					{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}: 10,
+				stats: []model.StatsResult{
+					{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 10},
 				},
 			},
 			wantStats: &model.StatsResult{
@@ -45,10 +49,10 @@ func TestInMemoryStatsRepository_GetMostFrequentRequest(t *testing.T) {
 		{
 			name: "Multiple requests",
 			fields: fields{
-				stats: map[model.FizzBuzzRequest]int{
-					{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}: 10,
-					{Int1: 2, Int2: 7, Limit: 20, Str1: "Foo", Str2: "Bar"}:   5,
-					{Int1: 4, Int2: 6, Limit: 30, Str1: "Qux", Str2: "Quux"}:  15,
+				stats: []model.StatsResult{
+					{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 10},
+					{Int1: 2, Int2: 7, Limit: 20, Str1: "Foo", Str2: "Bar", Hits: 5},
+					{Int1: 4, Int2: 6, Limit: 30, Str1: "Qux", Str2: "Quux", Hits: 15},
 				},
 			},
 			wantStats: &model.StatsResult{
@@ -63,8 +67,8 @@ func TestInMemoryStatsRepository_GetMostFrequentRequest(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := NewInMemoryStatsRepository(tt.fields.stats)
-			gotStats, err := r.GetMostFrequentRequest()
+			r := NewInMemoryStatsRepository(WithInitialStats(tt.fields.stats...))
+			gotStats, err := r.GetMostFrequentRequest(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetMostFrequentRequest() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -78,7 +82,7 @@ func TestInMemoryStatsRepository_GetMostFrequentRequest(t *testing.T) {
 
 func TestInMemoryStatsRepository_IncrementRequestCount(t *testing.T) {
 	type fields struct {
-		stats map[model.FizzBuzzRequest]int
+		stats []model.StatsResult
 	}
 	type args struct {
 		int1  int
@@ -86,18 +90,20 @@ func TestInMemoryStatsRepository_IncrementRequestCount(t *testing.T) {
 		limit int
 		str1  string
 		str2  string
+		rules []model.Rule
 	}
 	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		wantErr bool
+		name     string
+		fields   fields
+		args     args
+		wantHits int
+		wantErr  bool
 	}{
 		{
 			name: "Increment existing request",
 			fields: fields{
-				stats: map[model.FizzBuzzRequest]int{
-					{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}: 10,
+				stats: []model.StatsResult{
+					{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 10},
 				},
 			},
 			args: args{
@@ -107,11 +113,12 @@ func TestInMemoryStatsRepository_IncrementRequestCount(t *testing.T) {
 				str1:  "Fizz",
 				str2:  "Buzz",
 			},
+			wantHits: 11,
 		},
 		{
 			name: "Increment new request",
 			fields: fields{
-				stats: make(map[model.FizzBuzzRequest]int),
+				stats: nil,
 			},
 			args: args{
 				int1:  2,
@@ -120,21 +127,125 @@ func TestInMemoryStatsRepository_IncrementRequestCount(t *testing.T) {
 				str1:  "Foo",
 				str2:  "Bar",
 			},
+			wantHits: 1,
+		},
+		{
+			name: "Increment rule list request",
+			fields: fields{
+				stats: []model.StatsResult{
+					{Limit: 21, Rules: []model.Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 5, Word: "Buzz"}, {Divisor: 7, Word: "Bazz"}}, Hits: 4},
+				},
+			},
+			args: args{
+				limit: 21,
+				rules: []model.Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 5, Word: "Buzz"}, {Divisor: 7, Word: "Bazz"}},
+			},
+			wantHits: 5,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := NewInMemoryStatsRepository(tt.fields.stats)
-			if err := r.IncrementRequestCount(tt.args.int1, tt.args.int2, tt.args.limit, tt.args.str1, tt.args.str2); (err != nil) != tt.wantErr {
+			r := NewInMemoryStatsRepository(WithInitialStats(tt.fields.stats...))
+			request := model.FizzBuzzRequest{
+				Int1:  tt.args.int1,
+				Int2:  tt.args.int2,
+				Limit: tt.args.limit,
+				Str1:  tt.args.str1,
+				Str2:  tt.args.str2,
+				Rules: tt.args.rules,
+			}
+			if err := r.IncrementRequestCount(context.Background(), request); (err != nil) != tt.wantErr {
 				t.Errorf("IncrementRequestCount() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			gotStats, _ := r.GetMostFrequentRequest(context.Background())
+			if !reflect.DeepEqual(gotStats, model.NewStatsResult(request, tt.wantHits)) {
+				t.Errorf("GetMostFrequentRequest() gotStats = %v, want %d hits", gotStats, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestInMemoryStatsRepository_AddRequestCounts(t *testing.T) {
+	fizzBuzz := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	fooBar := model.FizzBuzzRequest{Int1: 2, Int2: 7, Limit: 20, Str1: "Foo", Str2: "Bar"}
+	tests := []struct {
+		name      string
+		counts    []model.RequestCount
+		wantStats *model.StatsResult
+		wantTotal int
+	}{
+		{
+			name:      "No counts",
+			wantTotal: 1,
+			wantStats: model.NewStatsResult(fizzBuzz, 10),
+		},
+		{
+			name:      "Counts of existing and new requests",
+			counts:    []model.RequestCount{{Request: fooBar, Count: 7}, {Request: fizzBuzz, Count: 2}, {Request: fooBar, Count: 6}},
+			wantTotal: 2,
+			wantStats: model.NewStatsResult(fooBar, 13),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewInMemoryStatsRepository(WithInitialStats(*model.NewStatsResult(fizzBuzz, 10)))
+			if err := r.AddRequestCounts(context.Background(), tt.counts); err != nil {
+				t.Errorf("AddRequestCounts() error = %v", err)
+			}
+			gotStats, _ := r.GetMostFrequentRequest(context.Background())
+			if !reflect.DeepEqual(gotStats, tt.wantStats) {
+				t.Errorf("GetMostFrequentRequest() gotStats = %v, want %v", gotStats, tt.wantStats)
+			}
+			if page, _ := r.GetRequestsByHits(context.Background(), 0, 10); page.Total != tt.wantTotal {
+				t.Errorf("GetRequestsByHits() total = %v, want %v", page.Total, tt.wantTotal)
+			}
+			windowed, _ := r.GetMostFrequentRequestBetween(context.Background(), time.Now().Add(-time.Hour), time.Now())
+			if len(tt.counts) > 0 && (windowed == nil || windowed.Hits != tt.wantStats.Hits) {
+				t.Errorf("GetMostFrequentRequestBetween() got = %v, want the counts in the current bucket", windowed)
+			}
 		})
 	}
 }
 
+func TestInMemoryStatsRepository_DistinctRequests(t *testing.T) {
+	// The words of these requests contain the separators of the former request keys
+	pairs := [][2]model.FizzBuzzRequest{
+		{
+			{Limit: 15, Rules: []model.Rule{{Divisor: 3, Word: "a,5:b"}}},
+			{Limit: 15, Rules: []model.Rule{{Divisor: 3, Word: "a"}, {Divisor: 5, Word: "b"}}},
+		},
+		{
+			{Int1: 3, Int2: 5, Limit: 15, Str1: "a,b", Str2: "c"},
+			{Int1: 3, Int2: 5, Limit: 15, Str1: "a", Str2: "b,c"},
+		},
+	}
+	for _, pair := range pairs {
+		r := NewInMemoryStatsRepository()
+		for _, request := range pair {
+			if err := r.IncrementRequestCount(context.Background(), request); err != nil {
+				t.Fatalf("IncrementRequestCount() error = %v", err)
+			}
+		}
+
+		page, _ := r.GetRequestsByHits(context.Background(), 0, 10)
+		if page.Total != 2 {
+			t.Errorf("GetRequestsByHits() total = %v, want 2 distinct requests", page.Total)
+		}
+		for _, result := range page.Results {
+			if result.Hits != 1 {
+				t.Errorf("GetRequestsByHits() hits of %+v = %v, want 1", result.Request(), result.Hits)
+			}
+		}
+		windowed, _ := r.GetMostFrequentRequestBetween(context.Background(), time.Now().Add(-time.Hour), time.Now())
+		if windowed == nil || windowed.Hits != 1 {
+			t.Errorf("GetMostFrequentRequestBetween() got = %v, want 1 hit", windowed)
+		}
+	}
+}
+
 func TestInMemoryStatsRepository_ResetStats(t *testing.T) {
 	type fields struct {
-		stats map[model.FizzBuzzRequest]int
+		stats []model.StatsResult
 	}
 	tests := []struct {
 		name    string
@@ -144,26 +255,352 @@ func TestInMemoryStatsRepository_ResetStats(t *testing.T) {
 		{
 			name: "Reset stats",
 			fields: fields{
-				stats: map[model.FizzBuzzRequest]int{
-					{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}: 10,
-					{Int1: 2, Int2: 7, Limit: 20, Str1: "Foo", Str2: "Bar"}:   5,
-					{Int1: 4, Int2: 6, Limit: 30, Str1: "Qux", Str2: "Quux"}:  15,
+				stats: []model.StatsResult{
+					{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 10},
+					{Int1: 2, Int2: 7, Limit: 20, Str1: "Foo", Str2: "Bar", Hits: 5},
+					{Int1: 4, Int2: 6, Limit: 30, Str1: "Qux", Str2: "Quux", Hits: 15},
 				},
 			},
 			wantErr: false,
 		},
 		{
 			name:    "Reset empty stats",
-			fields:  fields{stats: make(map[model.FizzBuzzRequest]int)},
+			fields:  fields{stats: nil},
 			wantErr: false,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := NewInMemoryStatsRepository(tt.fields.stats)
-			if err := r.ResetStats(); (err != nil) != tt.wantErr {
+			r := NewInMemoryStatsRepository(WithInitialStats(tt.fields.stats...))
+			if err := r.ResetStats(context.Background()); (err != nil) != tt.wantErr {
 				t.Errorf("ResetStats() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestInMemoryStatsRepository_GetRequestsByHits(t *testing.T) {
+	stats := []model.StatsResult{
+		{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 10},
+		{Int1: 2, Int2: 7, Limit: 20, Str1: "Foo", Str2: "Bar", Hits: 5},
+		{Limit: 21, Rules: []model.Rule{{Divisor: 7, Word: "Bazz"}}, Hits: 7},
+		{Int1: 4, Int2: 6, Limit: 30, Str1: "Qux", Str2: "Quux", Hits: 15},
+	}
+	tests := []struct {
+		name   string
+		offset int
+		count  int
+		want   *model.StatsPage
+	}{
+		{
+			name:   "Top two requests",
+			offset: 0,
+			count:  2,
+			want: &model.StatsPage{
+				Results: []model.StatsResult{stats[3], stats[0]},
+				Total:   4,
+			},
+		},
+		{
+			name:   "Last page",
+			offset: 2,
+			count:  3,
+			want: &model.StatsPage{
+				Results: []model.StatsResult{stats[2], stats[1]},
+				Total:   4,
+			},
+		},
+		{
+			name:   "Offset after the last request",
+			offset: 4,
+			count:  2,
+			want: &model.StatsPage{
+				Results: []model.StatsResult{},
+				Total:   4,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewInMemoryStatsRepository(WithInitialStats(stats...))
+			got, err := r.GetRequestsByHits(context.Background(), tt.offset, tt.count)
+			if err != nil {
+				t.Errorf("GetRequestsByHits() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetRequestsByHits() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInMemoryStatsRepository_RemoveRequest(t *testing.T) {
+	stats := []model.StatsResult{
+		{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 10},
+		{Int1: 2, Int2: 7, Limit: 20, Str1: "Foo", Str2: "Bar", Hits: 5},
+	}
+	tests := []struct {
+		name        string
+		request     model.FizzBuzzRequest
+		wantRemoved bool
+		wantTotal   int
+	}{
+		{
+			name:        "Remove existing request",
+			request:     model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"},
+			wantRemoved: true,
+			wantTotal:   1,
+		},
+		{
+			name:        "Remove unknown request",
+			request:     model.FizzBuzzRequest{Int1: 4, Int2: 6, Limit: 30, Str1: "Qux", Str2: "Quux"},
+			wantRemoved: false,
+			wantTotal:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewInMemoryStatsRepository(WithInitialStats(stats...))
+			removed, err := r.RemoveRequest(context.Background(), tt.request)
+			if err != nil {
+				t.Errorf("RemoveRequest() error = %v", err)
+				return
+			}
+			if removed != tt.wantRemoved {
+				t.Errorf("RemoveRequest() removed = %v, want %v", removed, tt.wantRemoved)
+			}
+			page, _ := r.GetRequestsByHits(context.Background(), 0, 10)
+			if page.Total != tt.wantTotal {
+				t.Errorf("RemoveRequest() total = %v, want %v", page.Total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestInMemoryStatsRepository_Concurrent(t *testing.T) {
+	const (
+		workers    = 16
+		increments = 2000
+		requests   = 150
+	)
+	r := NewInMemoryStatsRepository()
+	request := func(i int) model.FizzBuzzRequest {
+		return model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: i, Str1: "Fizz", Str2: "Buzz"}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				// Request 0 is hit by every increment, the others are spread across the workers
+				if err := r.IncrementRequestCount(context.Background(), request(0)); err != nil {
+					t.Errorf("IncrementRequestCount() error = %v", err)
+					return
+				}
+				if err := r.IncrementRequestCount(context.Background(), request(1+(w+i)%requests)); err != nil {
+					t.Errorf("IncrementRequestCount() error = %v", err)
+					return
+				}
+			}
+		}()
+	}
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				if _, err := r.GetMostFrequentRequest(context.Background()); err != nil {
+					t.Errorf("GetMostFrequentRequest() error = %v", err)
+					return
+				}
+				if _, err := r.GetRequestsByHits(context.Background(), i%requests, 10); err != nil {
+					t.Errorf("GetRequestsByHits() error = %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, _ := r.GetMostFrequentRequest(context.Background())
+	want := model.NewStatsResult(request(0), workers*increments)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetMostFrequentRequest() got = %v, want %v", got, want)
+	}
+
+	page, _ := r.GetRequestsByHits(context.Background(), 0, requests+1)
+	if page.Total != requests+1 {
+		t.Errorf("GetRequestsByHits() total = %v, want %v", page.Total, requests+1)
+	}
+	hits := 0
+	for _, result := range page.Results {
+		hits += result.Hits
+	}
+	if hits != 2*workers*increments {
+		t.Errorf("GetRequestsByHits() hits = %v, want %v", hits, 2*workers*increments)
+	}
+
+	// Removing requests concurrently with increments keeps the top requests consistent
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments/10; i++ {
+				if w%2 == 0 {
+					_, _ = r.RemoveRequest(context.Background(), request(1+(w+i)%requests))
+				} else {
+					_ = r.IncrementRequestCount(context.Background(), request(1+(w+i)%requests))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := r.RemoveRequest(context.Background(), request(0)); err != nil {
+		t.Errorf("RemoveRequest() error = %v", err)
+	}
+	got, _ = r.GetMostFrequentRequest(context.Background())
+	if got != nil && got.Request().Limit == 0 {
+		t.Errorf("GetMostFrequentRequest() got = %v, want a remaining request", got)
+	}
+
+	if err := r.ResetStats(context.Background()); err != nil {
+		t.Errorf("ResetStats() error = %v", err)
+	}
+	if got, _ = r.GetMostFrequentRequest(context.Background()); got != nil {
+		t.Errorf("GetMostFrequentRequest() got = %v, want nil", got)
+	}
+}
+
+func TestInMemoryStatsRepository_TopRequestsEviction(t *testing.T) {
+	r := NewInMemoryStatsRepository()
+	// More distinct requests than the top requests size, each with a distinct hit count
+	for i := 1; i <= topRequestsSize+50; i++ {
+		for j := 0; j < i; j++ {
+			_ = r.IncrementRequestCount(context.Background(), model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: i, Str1: "Fizz", Str2: "Buzz"})
+		}
+	}
+
+	for _, offset := range []int{0, 90} {
+		t.Run(fmt.Sprintf("offset %d", offset), func(t *testing.T) {
+			page, _ := r.GetRequestsByHits(context.Background(), offset, 20)
+			for i, result := range page.Results {
+				if want := topRequestsSize + 50 - offset - i; result.Hits != want || result.Limit != want {
+					t.Errorf("GetRequestsByHits() [%d] got = %+v, want %d hits", i, result, want)
+				}
+			}
+		})
+	}
+
+	// Removing a top request promotes the next most frequent one
+	_, _ = r.RemoveRequest(context.Background(), model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: topRequestsSize + 50, Str1: "Fizz", Str2: "Buzz"})
+	got, _ := r.GetMostFrequentRequest(context.Background())
+	if got == nil || got.Hits != topRequestsSize+49 {
+		t.Errorf("GetMostFrequentRequest() got = %v, want %d hits", got, topRequestsSize+49)
+	}
+	page, _ := r.GetRequestsByHits(context.Background(), topRequestsSize-1, 1)
+	if len(page.Results) != 1 || page.Results[0].Hits != 50 {
+		t.Errorf("GetRequestsByHits() got = %v, want 50 hits", page.Results)
+	}
+}
+
+func TestInMemoryStatsRepository_GetMostFrequentRequestBetween(t *testing.T) {
+	start := time.Now().Truncate(time.Hour).Add(30 * time.Minute)
+	now := start
+	clock := func() time.Time { return now }
+	r := NewInMemoryStatsRepository(WithClock(clock), WithRetention(48*time.Hour))
+
+	fizzBuzz := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	fooBar := model.FizzBuzzRequest{Int1: 2, Int2: 7, Limit: 20, Str1: "Foo", Str2: "Bar"}
+	record := func(at time.Time, request model.FizzBuzzRequest, hits int) {
+		now = at
+		for i := 0; i < hits; i++ {
+			if err := r.IncrementRequestCount(context.Background(), request); err != nil {
+				t.Fatalf("IncrementRequestCount() error = %v", err)
+			}
+		}
+	}
+	// fizzBuzz was popular three days ago, past the retention, and yesterday, fooBar is popular now
+	record(start.Add(-72*time.Hour), fizzBuzz, 10)
+	record(start.Add(-27*time.Hour), fizzBuzz, 5)
+	record(start.Add(-20*time.Minute), fooBar, 3)
+	now = start
+
+	tests := []struct {
+		name string
+		from time.Time
+		to   time.Time
+		want *model.StatsResult
+	}{
+		{
+			name: "last hour",
+			from: now.Add(-time.Hour),
+			to:   now,
+			want: model.NewStatsResult(fooBar, 3),
+		},
+		{
+			name: "last week, limited to the retention",
+			from: now.Add(-7 * 24 * time.Hour),
+			to:   now,
+			want: model.NewStatsResult(fizzBuzz, 5),
+		},
+		{
+			name: "range without requests",
+			from: now.Add(-26 * time.Hour),
+			to:   now.Add(-time.Hour),
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.GetMostFrequentRequestBetween(context.Background(), tt.from, tt.to)
+			if err != nil {
+				t.Errorf("GetMostFrequentRequestBetween() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetMostFrequentRequestBetween() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	all, _ := r.GetMostFrequentRequest(context.Background())
+	if want := model.NewStatsResult(fizzBuzz, 15); !reflect.DeepEqual(all, want) {
+		t.Errorf("GetMostFrequentRequest() got = %v, want %v", all, want)
+	}
+
+	if _, err := r.RemoveRequest(context.Background(), fooBar); err != nil {
+		t.Errorf("RemoveRequest() error = %v", err)
+	}
+	if got, _ := r.GetMostFrequentRequestBetween(context.Background(), now.Add(-time.Hour), now); got != nil {
+		t.Errorf("GetMostFrequentRequestBetween() after RemoveRequest() got = %v, want nil", got)
+	}
+
+	if err := r.ResetStats(context.Background()); err != nil {
+		t.Errorf("ResetStats() error = %v", err)
+	}
+	if got, _ := r.GetMostFrequentRequestBetween(context.Background(), now.Add(-24*time.Hour), now); got != nil {
+		t.Errorf("GetMostFrequentRequestBetween() after ResetStats() got = %v, want nil", got)
+	}
+}
+
+func TestInMemoryStatsRepository_CheckReadiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		repo       *InMemoryStatsRepository
+		wantStatus model.HealthStatus
+	}{
+		{name: "Created repository", repo: NewInMemoryStatsRepository(), wantStatus: model.HealthStatusOK},
+		{name: "Uninitialized repository", repo: &InMemoryStatsRepository{}, wantStatus: model.HealthStatusUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.repo.CheckReadiness(context.Background()); got.Status != tt.wantStatus {
+				t.Errorf("CheckReadiness() got = %+v, want %s", got, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+var _ adapters.StatsRepository = (*InstrumentedStatsRepository)(nil)
+
+// InstrumentedStatsRepository records the duration and the errors of the operations of a stats repository
+type InstrumentedStatsRepository struct {
+	repo    adapters.StatsRepository
+	metrics adapters.Metrics
+}
+
+// NewInstrumentedStatsRepository creates a new instance of InstrumentedStatsRepository recording the operations of repo
+func NewInstrumentedStatsRepository(repo adapters.StatsRepository, metrics adapters.Metrics) *InstrumentedStatsRepository {
+	return &InstrumentedStatsRepository{
+		repo:    repo,
+		metrics: metrics,
+	}
+}
+
+// GetMostFrequentRequest returns the most frequent request parameters and their hit count
+func (r *InstrumentedStatsRepository) GetMostFrequentRequest(ctx context.Context) (stats *model.StatsResult, err error) {
+	defer r.observe("get_most_frequent_request", time.Now(), &err)
+	return r.repo.GetMostFrequentRequest(ctx)
+}
+
+// GetMostFrequentRequestBetween returns the most frequent request parameters and their hit count between from and to
+func (r *InstrumentedStatsRepository) GetMostFrequentRequestBetween(ctx context.Context, from, to time.Time) (stats *model.StatsResult, err error) {
+	defer r.observe("get_most_frequent_request_between", time.Now(), &err)
+	return r.repo.GetMostFrequentRequestBetween(ctx, from, to)
+}
+
+// GetRequestsByHits returns count requests parameters ordered by hit count, skipping the first offset ones
+func (r *InstrumentedStatsRepository) GetRequestsByHits(ctx context.Context, offset, count int) (page *model.StatsPage, err error) {
+	defer r.observe("get_requests_by_hits", time.Now(), &err)
+	return r.repo.GetRequestsByHits(ctx, offset, count)
+}
+
+// IncrementRequestCount increments the count for a specific request parameters
+func (r *InstrumentedStatsRepository) IncrementRequestCount(ctx context.Context, request model.FizzBuzzRequest) (err error) {
+	defer r.observe("increment_request_count", time.Now(), &err)
+	return r.repo.IncrementRequestCount(ctx, request)
+}
+
+// AddRequestCounts adds the hits of several request parameters at once
+func (r *InstrumentedStatsRepository) AddRequestCounts(ctx context.Context, counts []model.RequestCount) (err error) {
+	defer r.observe("add_request_counts", time.Now(), &err)
+	return r.repo.AddRequestCounts(ctx, counts)
+}
+
+// RemoveRequest removes the statistics of a specific request parameters
+func (r *InstrumentedStatsRepository) RemoveRequest(ctx context.Context, request model.FizzBuzzRequest) (removed bool, err error) {
+	defer r.observe("remove_request", time.Now(), &err)
+	return r.repo.RemoveRequest(ctx, request)
+}
+
+// ResetStats resets the statistics data
+func (r *InstrumentedStatsRepository) ResetStats(ctx context.Context) (err error) {
+	defer r.observe("reset_stats", time.Now(), &err)
+	return r.repo.ResetStats(ctx)
+}
+
+func (r *InstrumentedStatsRepository) observe(operation string, start time.Time, err *error) {
+	r.metrics.ObserveStatsOperation(operation, time.Since(start), *err)
+}
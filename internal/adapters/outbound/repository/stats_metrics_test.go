@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+// operationsMetrics records the observed stats operations
+type operationsMetrics struct {
+	operations []string
+	errors     []error
+}
+
+func (m *operationsMetrics) ObserveFizzBuzzLimit(int) {}
+func (m *operationsMetrics) ObserveCacheLookup(bool)  {}
+func (m *operationsMetrics) ObserveStatsOperation(operation string, _ time.Duration, err error) {
+	m.operations = append(m.operations, operation)
+	m.errors = append(m.errors, err)
+}
+
+func TestInstrumentedStatsRepository(t *testing.T) {
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	repo := &flakyStats{InMemoryStatsRepository: NewInMemoryStatsRepository()}
+	metrics := &operationsMetrics{}
+	r := NewInstrumentedStatsRepository(repo, metrics)
+
+	_ = r.IncrementRequestCount(context.Background(), request)
+	_, _ = r.GetMostFrequentRequest(context.Background())
+	repo.down.Store(true)
+	_, _ = r.GetMostFrequentRequest(context.Background())
+
+	wantOperations := []string{"increment_request_count", "get_most_frequent_request", "get_most_frequent_request"}
+	if len(metrics.operations) != len(wantOperations) {
+		t.Fatalf("observed operations = %v, want %v", metrics.operations, wantOperations)
+	}
+	for i, operation := range wantOperations {
+		if metrics.operations[i] != operation {
+			t.Errorf("observed operation %d = %s, want %s", i, metrics.operations[i], operation)
+		}
+	}
+	if metrics.errors[1] != nil || !errors.Is(metrics.errors[2], errRedisDown) {
+		t.Errorf("observed errors = %v, want only the last operation failed", metrics.errors)
+	}
+}
@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+var (
+	_ adapters.StatsRepository = (*StatsRecorder)(nil)
+	_ adapters.HealthReporter  = (*StatsRecorder)(nil)
+)
+
+const (
+	// DefaultRecorderQueueSize is the default number of increments waiting to be aggregated
+	DefaultRecorderQueueSize = 10000
+	// DefaultRecorderBatchSize is the default number of distinct requests flushing a batch
+	DefaultRecorderBatchSize = 500
+	// DefaultRecorderFlushInterval is the default period after which a batch is flushed
+	DefaultRecorderFlushInterval = time.Second
+)
+
+// RecorderStats holds the counters of a StatsRecorder, in increments
+type RecorderStats struct {
+	// Queued is the number of increments waiting to be aggregated
+	Queued int
+	// Pending is the number of distinct requests aggregated in the next batch
+	Pending int
+	Dropped uint64
+	Flushed uint64
+}
+
+// RecorderOption configures a StatsRecorder
+type RecorderOption func(*StatsRecorder)
+
+// WithRecorderQueueSize sets the number of increments waiting to be aggregated, the next ones are dropped
+func WithRecorderQueueSize(size int) RecorderOption {
+	return func(r *StatsRecorder) {
+		if size > 0 {
+			r.queue = make(chan model.RequestCount, size)
+		}
+	}
+}
+
+// WithRecorderBatchSize sets the number of distinct requests flushing a batch
+func WithRecorderBatchSize(size int) RecorderOption {
+	return func(r *StatsRecorder) {
+		if size > 0 {
+			r.batchSize = size
+		}
+	}
+}
+
+// WithRecorderFlushInterval sets the period after which a batch is flushed
+func WithRecorderFlushInterval(interval time.Duration) RecorderOption {
+	return func(r *StatsRecorder) {
+		if interval > 0 {
+			r.interval = interval
+		}
+	}
+}
+
+// StatsRecorder records the hits of a stats repository asynchronously. The increments go through a bounded
+// queue, are aggregated per request and written in batches once enough requests or time have gone by.
+// Increments are dropped when the queue is full. The other operations are run on the repository, once the
+// aggregated hits are written.
+type StatsRecorder struct {
+	repo      adapters.StatsRepository
+	queue     chan model.RequestCount
+	batchSize int
+	interval  time.Duration
+	// flushes asks the recording goroutine to write its batch, it closes the channel once done
+	flushes chan chan struct{}
+	done    chan struct{}
+
+	// mu prevents the queue from being closed while an increment is sent to it
+	mu     sync.RWMutex
+	closed bool
+
+	pending atomic.Int64
+	dropped atomic.Uint64
+	flushed atomic.Uint64
+}
+
+// NewStatsRecorder creates a new instance of StatsRecorder writing to repo and starts recording
+func NewStatsRecorder(repo adapters.StatsRepository, opts ...RecorderOption) *StatsRecorder {
+	recorder := &StatsRecorder{
+		repo:      repo,
+		queue:     make(chan model.RequestCount, DefaultRecorderQueueSize),
+		batchSize: DefaultRecorderBatchSize,
+		interval:  DefaultRecorderFlushInterval,
+		flushes:   make(chan chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(recorder)
+	}
+
+	go recorder.run()
+	return recorder
+}
+
+// GetMostFrequentRequest returns the most frequent request parameters and their hit count, without the queued hits
+func (r *StatsRecorder) GetMostFrequentRequest(ctx context.Context) (*model.StatsResult, error) {
+	return r.repo.GetMostFrequentRequest(ctx)
+}
+
+// GetMostFrequentRequestBetween returns the most frequent request parameters and their hit count between from and to,
+// without the queued hits
+func (r *StatsRecorder) GetMostFrequentRequestBetween(ctx context.Context, from, to time.Time) (*model.StatsResult, error) {
+	return r.repo.GetMostFrequentRequestBetween(ctx, from, to)
+}
+
+// GetRequestsByHits returns count requests parameters ordered by hit count, skipping the first offset ones,
+// without the queued hits
+func (r *StatsRecorder) GetRequestsByHits(ctx context.Context, offset, count int) (*model.StatsPage, error) {
+	return r.repo.GetRequestsByHits(ctx, offset, count)
+}
+
+// IncrementRequestCount queues an increment of the count for a specific request parameters
+func (r *StatsRecorder) IncrementRequestCount(ctx context.Context, request model.FizzBuzzRequest) error {
+	return r.AddRequestCounts(ctx, []model.RequestCount{{Request: request, Count: 1}})
+}
+
+// AddRequestCounts queues the hits of several request parameters. Once the recorder is shut down,
+// they are written synchronously.
+func (r *StatsRecorder) AddRequestCounts(ctx context.Context, counts []model.RequestCount) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return r.repo.AddRequestCounts(ctx, counts)
+	}
+	for _, count := range counts {
+		select {
+		case r.queue <- count:
+		default:
+			r.dropped.Add(uint64(count.Count))
+		}
+	}
+	return nil
+}
+
+// RemoveRequest removes the statistics of a specific request parameters, once the aggregated hits are written
+func (r *StatsRecorder) RemoveRequest(ctx context.Context, request model.FizzBuzzRequest) (bool, error) {
+	if err := r.Flush(ctx); err != nil {
+		return false, err
+	}
+	return r.repo.RemoveRequest(ctx, request)
+}
+
+// ResetStats resets the statistics data, once the aggregated hits are written
+func (r *StatsRecorder) ResetStats(ctx context.Context) error {
+	if err := r.Flush(ctx); err != nil {
+		return err
+	}
+	return r.repo.ResetStats(ctx)
+}
+
+// Flush writes the aggregated hits and the queued ones, and waits for them to be written
+func (r *StatsRecorder) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case r.flushes <- ack:
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown stops queuing the increments and waits for the queued ones to be written.
+// The increments received afterwards are written synchronously.
+func (r *StatsRecorder) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the counters of the recorder
+func (r *StatsRecorder) Stats() RecorderStats {
+	return RecorderStats{
+		Queued:  len(r.queue),
+		Pending: int(r.pending.Load()),
+		Dropped: r.dropped.Load(),
+		Flushed: r.flushed.Load(),
+	}
+}
+
+// Health returns the state of the recorder, degraded once it drops increments
+func (r *StatsRecorder) Health() model.ComponentHealth {
+	stats := r.Stats()
+	health := model.ComponentHealth{
+		Name:             "stats-recorder",
+		Status:           model.HealthStatusOK,
+		BufferedRequests: stats.Pending,
+		DroppedHits:      stats.Dropped,
+	}
+	if len(r.queue) == cap(r.queue) {
+		health.Status = model.HealthStatusDegraded
+	}
+	return health
+}
+
+// run aggregates the queued increments and writes them in batches until the queue is closed and drained
+func (r *StatsRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	batch := make(map[string]*model.RequestCount)
+	for {
+		select {
+		case count, ok := <-r.queue:
+			if !ok {
+				r.write(batch)
+				return
+			}
+			batch = r.aggregate(batch, count)
+		case <-ticker.C:
+			batch = r.write(batch)
+		case ack := <-r.flushes:
+			batch = r.write(r.drain(batch))
+			close(ack)
+		}
+	}
+}
+
+// aggregate adds count to batch, and writes the batch once it holds enough requests
+func (r *StatsRecorder) aggregate(batch map[string]*model.RequestCount, count model.RequestCount) map[string]*model.RequestCount {
+	key := count.Request.Key()
+	if pending, found := batch[key]; found {
+		pending.Count += count.Count
+	} else {
+		batch[key] = &model.RequestCount{Request: count.Request, Count: count.Count}
+		r.pending.Store(int64(len(batch)))
+	}
+	if len(batch) >= r.batchSize {
+		return r.write(batch)
+	}
+	return batch
+}
+
+// drain aggregates the increments waiting in the queue into batch, so a flush writes the increments
+// queued before it
+func (r *StatsRecorder) drain(batch map[string]*model.RequestCount) map[string]*model.RequestCount {
+	for {
+		select {
+		case count, ok := <-r.queue:
+			if !ok {
+				return batch
+			}
+			batch = r.aggregate(batch, count)
+		default:
+			return batch
+		}
+	}
+}
+
+// write writes a batch to the repository and returns an empty one, the hits of a failed batch are dropped
+func (r *StatsRecorder) write(batch map[string]*model.RequestCount) map[string]*model.RequestCount {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	counts := make([]model.RequestCount, 0, len(batch))
+	var hits uint64
+	for _, count := range batch {
+		counts = append(counts, *count)
+		hits += uint64(count.Count)
+	}
+
+	if err := r.repo.AddRequestCounts(context.Background(), counts); err != nil {
+		slog.Error("Failed to write the statistics batch", "requests", len(counts), "hits", hits, "error", err)
+		r.dropped.Add(hits)
+	} else {
+		r.flushed.Add(hits)
+	}
+	r.pending.Store(0)
+	return make(map[string]*model.RequestCount)
+}
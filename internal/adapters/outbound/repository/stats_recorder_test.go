@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
+
+// batchStats is an in-memory stats repository recording the batches it receives
+type batchStats struct {
+	*InMemoryStatsRepository
+	batches atomic.Int64
+	// block, when set, holds the batches until it is closed
+	block   chan struct{}
+	started chan struct{}
+}
+
+func (b *batchStats) AddRequestCounts(ctx context.Context, counts []model.RequestCount) error {
+	b.batches.Add(1)
+	if b.block != nil {
+		b.started <- struct{}{}
+		<-b.block
+	}
+	return b.InMemoryStatsRepository.AddRequestCounts(ctx, counts)
+}
+
+func TestStatsRecorder_Batches(t *testing.T) {
+	fizzBuzz := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	fooBar := model.FizzBuzzRequest{Int1: 2, Int2: 7, Limit: 20, Str1: "Foo", Str2: "Bar"}
+	tests := []struct {
+		name        string
+		opts        []RecorderOption
+		requests    []model.FizzBuzzRequest
+		wait        time.Duration
+		wantBatches int64
+		wantHits    int
+	}{
+		{
+			name:        "Batch size reached",
+			opts:        []RecorderOption{WithRecorderBatchSize(2), WithRecorderFlushInterval(time.Hour)},
+			requests:    []model.FizzBuzzRequest{fizzBuzz, fizzBuzz, fizzBuzz, fooBar},
+			wantBatches: 1,
+			wantHits:    3,
+		},
+		{
+			name:        "Flush interval elapsed",
+			opts:        []RecorderOption{WithRecorderFlushInterval(10 * time.Millisecond)},
+			requests:    []model.FizzBuzzRequest{fizzBuzz, fooBar, fizzBuzz},
+			wait:        50 * time.Millisecond,
+			wantBatches: 1,
+			wantHits:    2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &batchStats{InMemoryStatsRepository: NewInMemoryStatsRepository()}
+			r := NewStatsRecorder(repo, tt.opts...)
+			defer func() { _ = r.Shutdown(context.Background()) }()
+
+			for _, request := range tt.requests {
+				if err := r.IncrementRequestCount(context.Background(), request); err != nil {
+					t.Errorf("IncrementRequestCount() error = %v", err)
+				}
+			}
+			deadline := time.Now().Add(time.Second + tt.wait)
+			for r.Stats().Flushed < uint64(len(tt.requests)) && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+
+			if got := repo.batches.Load(); got < tt.wantBatches {
+				t.Errorf("batches = %d, want at least %d", got, tt.wantBatches)
+			}
+			got, _ := r.GetMostFrequentRequest(context.Background())
+			if got == nil || got.Hits != tt.wantHits {
+				t.Errorf("GetMostFrequentRequest() got = %v, want %d hits", got, tt.wantHits)
+			}
+			if stats := r.Stats(); stats.Flushed != uint64(len(tt.requests)) || stats.Dropped != 0 {
+				t.Errorf("Stats() got = %+v, want %d flushed", stats, len(tt.requests))
+			}
+		})
+	}
+}
+
+func TestStatsRecorder_DropsWhenFull(t *testing.T) {
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	repo := &batchStats{
+		InMemoryStatsRepository: NewInMemoryStatsRepository(),
+		block:                   make(chan struct{}),
+		started:                 make(chan struct{}, 4),
+	}
+	r := NewStatsRecorder(repo, WithRecorderQueueSize(2), WithRecorderBatchSize(1))
+
+	// The first increment holds the recording goroutine, the next two fill the queue
+	_ = r.IncrementRequestCount(context.Background(), request)
+	<-repo.started
+	for i := 0; i < 4; i++ {
+		_ = r.IncrementRequestCount(context.Background(), request)
+	}
+	if stats := r.Stats(); stats.Queued != 2 || stats.Dropped != 2 {
+		t.Errorf("Stats() got = %+v, want 2 queued and 2 dropped", stats)
+	}
+	if got := r.Health(); got.Status != model.HealthStatusDegraded || got.DroppedHits != 2 {
+		t.Errorf("Health() got = %+v, want degraded with 2 dropped hits", got)
+	}
+
+	close(repo.block)
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if stats := r.Stats(); stats.Flushed != 3 || stats.Queued != 0 {
+		t.Errorf("Stats() got = %+v, want the queue drained", stats)
+	}
+}
+
+func TestStatsRecorder_Shutdown(t *testing.T) {
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	repo := &batchStats{InMemoryStatsRepository: NewInMemoryStatsRepository()}
+	r := NewStatsRecorder(repo, WithRecorderFlushInterval(time.Hour))
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				_ = r.IncrementRequestCount(context.Background(), request)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	// Increments received after the shutdown are written synchronously
+	_ = r.IncrementRequestCount(context.Background(), request)
+
+	got, _ := r.GetMostFrequentRequest(context.Background())
+	if got == nil || got.Hits != 801 {
+		t.Errorf("GetMostFrequentRequest() got = %v, want 801 hits", got)
+	}
+	if stats := r.Stats(); stats.Flushed != 800 {
+		t.Errorf("Stats() got = %+v, want 800 flushed", stats)
+	}
+}
+
+func TestStatsRecorder_ResetStatsWritesPendingHitsFirst(t *testing.T) {
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	repo := &batchStats{InMemoryStatsRepository: NewInMemoryStatsRepository()}
+	r := NewStatsRecorder(repo, WithRecorderFlushInterval(time.Hour))
+	defer func() { _ = r.Shutdown(context.Background()) }()
+
+	_ = r.IncrementRequestCount(context.Background(), request)
+	// Waits for the increment to be aggregated
+	for r.Stats().Pending == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := r.ResetStats(context.Background()); err != nil {
+		t.Fatalf("ResetStats() error = %v", err)
+	}
+	_ = r.Flush(context.Background())
+
+	if got, _ := r.GetMostFrequentRequest(context.Background()); got != nil {
+		t.Errorf("GetMostFrequentRequest() got = %v, want the pending hits reset", got)
+	}
+}
+
+func TestStatsRecorder_ResetStatsWritesQueuedHitsFirst(t *testing.T) {
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	tests := []struct {
+		name  string
+		clear func(r *StatsRecorder) error
+	}{
+		{
+			name:  "reset",
+			clear: func(r *StatsRecorder) error { return r.ResetStats(context.Background()) },
+		},
+		{
+			name: "remove",
+			clear: func(r *StatsRecorder) error {
+				_, err := r.RemoveRequest(context.Background(), request)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewStatsRecorder(NewInMemoryStatsRepository(), WithRecorderFlushInterval(time.Hour))
+			defer func() { _ = r.Shutdown(context.Background()) }()
+
+			// The increments are cleared while most of them are still queued
+			for i := 0; i < 1000; i++ {
+				_ = r.IncrementRequestCount(context.Background(), request)
+			}
+			if err := tt.clear(r); err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			_ = r.Flush(context.Background())
+
+			if got, _ := r.GetMostFrequentRequest(context.Background()); got != nil {
+				t.Errorf("GetMostFrequentRequest() got = %v, want the queued hits cleared", got)
+			}
+		})
+	}
+}
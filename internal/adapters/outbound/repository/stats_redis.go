@@ -1,36 +1,75 @@
 package repository
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	redis "github.com/go-redis/redis/v8"
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/tracing"
 	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
 	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-var _ adapters.StatsRepository = (*RedisStatsRepository)(nil)
+var (
+	_ adapters.StatsRepository  = (*RedisStatsRepository)(nil)
+	_ adapters.ReadinessChecker = (*RedisStatsRepository)(nil)
+)
 
 const (
-	// RedisKeyStats is the key used to store statistics in Redis
+	// RedisKeyStats is the key used to store statistics in Redis, the default namespace
 	RedisKeyStats = "fizzbuzz:stats"
+	// RedisKeyStatsBucketPrefix is the prefix of the keys of the time-windowed statistics buckets of the
+	// default namespace, followed by the Unix time of the bucket start
+	RedisKeyStatsBucketPrefix = "fizzbuzz:stats:bucket:"
+	// RedisKeyStatsAPIKeyPrefix is the prefix of the namespaces of the statistics of each API key,
+	// followed by the name of the key
+	RedisKeyStatsAPIKeyPrefix = "fizzbuzz:stats:apikey:"
+	// RedisKeyStatsEncoding records that the stats members were migrated to the JSON encoding
+	RedisKeyStatsEncoding = "fizzbuzz:stats:encoding"
+
+	statsEncodingJSON = "json"
+	// statsMigrationAttempts is the number of times the migration of a key is retried on concurrent updates
+	statsMigrationAttempts = 10
 )
 
+// RedisStatsRepository is a Redis implementation of StatsRepository. Each operation is bounded by
+// the repository timeout, except ResetStats and MigrateStatsMembers which scan the keys.
 type RedisStatsRepository struct {
 	client *redis.Client
+	// statsKey holds the all-time statistics, bucketPrefix starts the keys of the buckets
+	statsKey     string
+	bucketPrefix string
+	retention    time.Duration
+	timeout      time.Duration
+	now          func() time.Time
 }
 
-func NewRedisStatsRepository(redis *redis.Client) *RedisStatsRepository {
+func NewRedisStatsRepository(redis *redis.Client, opts ...StatsOption) *RedisStatsRepository {
+	options := newStatsOptions(opts)
 	return &RedisStatsRepository{
-		client: redis,
+		client:       redis,
+		statsKey:     options.namespace,
+		bucketPrefix: options.namespace + ":bucket:",
+		retention:    options.retention,
+		timeout:      options.timeout,
+		now:          options.now,
 	}
 }
 
 // GetMostFrequentRequest returns the most frequent request parameters and their hit count
-func (r *RedisStatsRepository) GetMostFrequentRequest() (stats *model.StatsResult, err error) {
-	ctx := r.client.Context()
-	cmd := r.client.ZRevRangeWithScores(ctx, RedisKeyStats, 0, 0)
+func (r *RedisStatsRepository) GetMostFrequentRequest(ctx context.Context) (stats *model.StatsResult, err error) {
+	ctx, span := startRedisSpan(ctx, "redis.stats.get_most_frequent_request", "ZREVRANGE")
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	cmd := r.client.ZRevRangeWithScores(ctx, r.statsKey, 0, 0)
 	if cmd.Err() != nil {
 		return stats, cmd.Err()
 	}
@@ -39,47 +78,364 @@ func (r *RedisStatsRepository) GetMostFrequentRequest() (stats *model.StatsResul
 	}
 
 	mostFrequent := cmd.Val()[0]
-	parts := strings.Split(mostFrequent.Member.(string), ",")
+	request, err := decodeStatsMember(mostFrequent.Member.(string))
+	if err != nil {
+		return stats, err
+	}
+
+	var hitsFloat float64
+	hitsFloat = mostFrequent.Score
+	hits := int(hitsFloat)
+	return model.NewStatsResult(request, hits), nil
+}
+
+// GetMostFrequentRequestBetween returns the most frequent request parameters and their hit count
+// between from and to, at the precision of the statistics buckets
+func (r *RedisStatsRepository) GetMostFrequentRequestBetween(ctx context.Context, from, to time.Time) (stats *model.StatsResult, err error) {
+	var keys []string
+	for _, start := range bucketsBetween(from, to, r.now(), r.retention) {
+		keys = append(keys, r.bucketKey(start))
+	}
+	if len(keys) == 0 {
+		return stats, nil
+	}
 
-	int1, err := strconv.Atoi(parts[0])
+	ctx, span := startRedisSpan(ctx, "redis.stats.get_most_frequent_request_between", "ZUNION")
+	span.SetAttributes(attribute.Int("fizzbuzz.stats.buckets", len(keys)))
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	members, err := r.client.ZUnionWithScores(ctx, redis.ZStore{Keys: keys}).Result()
 	if err != nil {
-		return stats, fmt.Errorf("failed to parse int1: %w", err)
+		return stats, err
+	}
+
+	var mostFrequent *redis.Z
+	for i, member := range members {
+		if mostFrequent == nil || member.Score > mostFrequent.Score ||
+			(member.Score == mostFrequent.Score && member.Member.(string) < mostFrequent.Member.(string)) {
+			mostFrequent = &members[i]
+		}
 	}
-	int2, err := strconv.Atoi(parts[1])
+	if mostFrequent == nil {
+		return stats, nil
+	}
+
+	request, err := decodeStatsMember(mostFrequent.Member.(string))
 	if err != nil {
-		return stats, fmt.Errorf("failed to parse int2: %w", err)
+		return stats, err
 	}
-	limit, err := strconv.Atoi(parts[2])
+	return model.NewStatsResult(request, int(mostFrequent.Score)), nil
+}
+
+// GetRequestsByHits returns count requests parameters ordered by hit count, skipping the first offset ones
+func (r *RedisStatsRepository) GetRequestsByHits(ctx context.Context, offset, count int) (page *model.StatsPage, err error) {
+	ctx, span := startRedisSpan(ctx, "redis.stats.get_requests_by_hits", "ZCARD ZREVRANGE")
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	total, err := r.client.ZCard(ctx, r.statsKey).Result()
 	if err != nil {
-		return stats, fmt.Errorf("failed to parse limit: %w", err)
+		return page, err
 	}
-	str1 := parts[3]
-	str2 := parts[4]
 
-	var hitsFloat float64
-	hitsFloat = mostFrequent.Score
-	hits := int(hitsFloat)
-	return &model.StatsResult{
-		Int1:  int1,
-		Int2:  int2,
-		Limit: limit,
-		Str1:  str1,
-		Str2:  str2,
-		Hits:  hits,
-	}, nil
+	page = &model.StatsPage{
+		Results: []model.StatsResult{},
+		Total:   int(total),
+	}
+	if count <= 0 || int64(offset) >= total {
+		return page, nil
+	}
+
+	members, err := r.client.ZRevRangeWithScores(ctx, r.statsKey, int64(offset), int64(offset+count-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		request, err := decodeStatsMember(member.Member.(string))
+		if err != nil {
+			return nil, err
+		}
+		page.Results = append(page.Results, *model.NewStatsResult(request, int(member.Score)))
+	}
+	return page, nil
 }
 
 // IncrementRequestCount increments the count for a specific request parameters
-func (r *RedisStatsRepository) IncrementRequestCount(int1, int2, limit int, str1, str2 string) error {
-	ctx := r.client.Context()
-	key := fmt.Sprintf("%d,%d,%d,%s,%s", int1, int2, limit, str1, str2)
-	cmd := r.client.ZIncrBy(ctx, RedisKeyStats, 1, key)
-	return cmd.Err()
+func (r *RedisStatsRepository) IncrementRequestCount(ctx context.Context, request model.FizzBuzzRequest) error {
+	return r.AddRequestCounts(ctx, []model.RequestCount{{Request: request, Count: 1}})
+}
+
+// AddRequestCounts adds the hits of several request parameters in a single transaction
+func (r *RedisStatsRepository) AddRequestCounts(ctx context.Context, counts []model.RequestCount) (err error) {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	ctx, span := startRedisSpan(ctx, "redis.stats.add_request_counts", "ZINCRBY")
+	span.SetAttributes(attribute.Int("fizzbuzz.stats.requests", len(counts)))
+	defer func() { tracing.End(span, err) }()
+
+	members := make([]string, len(counts))
+	for i, count := range counts {
+		member, err := encodeStatsMember(count.Request)
+		if err != nil {
+			return err
+		}
+		members[i] = member
+	}
+
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	start := bucketStart(r.now())
+	bucketKey := r.bucketKey(start)
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, count := range counts {
+			pipe.ZIncrBy(ctx, r.statsKey, float64(count.Count), members[i])
+			pipe.ZIncrBy(ctx, bucketKey, float64(count.Count), members[i])
+		}
+		pipe.ExpireAt(ctx, bucketKey, start.Add(StatsBucketSize+r.retention))
+		return nil
+	})
+	return err
+}
+
+// RemoveRequest removes the statistics of a specific request parameters
+func (r *RedisStatsRepository) RemoveRequest(ctx context.Context, request model.FizzBuzzRequest) (removed bool, err error) {
+	ctx, span := startRedisSpan(ctx, "redis.stats.remove_request", "ZREM")
+	defer func() { tracing.End(span, err) }()
+
+	member, err := encodeStatsMember(request)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	now := r.now()
+
+	var cmd *redis.IntCmd
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		cmd = pipe.ZRem(ctx, r.statsKey, member)
+		for _, start := range bucketsBetween(time.Time{}, now.Add(StatsBucketSize), now, r.retention) {
+			pipe.ZRem(ctx, r.bucketKey(start), member)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return cmd.Val() > 0, nil
 }
 
 // ResetStats resets the statistics data
-func (r *RedisStatsRepository) ResetStats() error {
-	ctx := r.client.Context()
-	cmd := r.client.Del(ctx, RedisKeyStats)
-	return cmd.Err()
+func (r *RedisStatsRepository) ResetStats(ctx context.Context) (err error) {
+	ctx, span := startRedisSpan(ctx, "redis.stats.reset_stats", "SCAN DEL")
+	defer func() { tracing.End(span, err) }()
+
+	return deleteKeys(ctx, r.client, prefixPattern(r.bucketPrefix), r.statsKey)
+}
+
+// ResetRedisStatsNamespaces deletes the statistics of every namespace starting with prefix, such as
+// RedisKeyStatsAPIKeyPrefix for the statistics of all the API keys
+func ResetRedisStatsNamespaces(ctx context.Context, client *redis.Client, prefix string) (err error) {
+	ctx, span := startRedisSpan(ctx, "redis.stats.reset_namespaces", "SCAN DEL")
+	defer func() { tracing.End(span, err) }()
+
+	return deleteKeys(ctx, client, prefixPattern(prefix))
+}
+
+// RemoveRedisStatsNamespacesRequest removes the statistics of request from every namespace starting with prefix,
+// such as RedisKeyStatsAPIKeyPrefix for the statistics of all the API keys
+func RemoveRedisStatsNamespacesRequest(ctx context.Context, client *redis.Client, prefix string,
+	request model.FizzBuzzRequest) (err error) {
+	ctx, span := startRedisSpan(ctx, "redis.stats.remove_namespaces_request", "SCAN ZREM")
+	defer func() { tracing.End(span, err) }()
+
+	member, err := encodeStatsMember(request)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	iter := client.Scan(ctx, 0, prefixPattern(prefix), 0).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.ZRem(ctx, key, member)
+		}
+		return nil
+	})
+	return err
+}
+
+// deleteKeys deletes keys and the keys matching the SCAN pattern
+func deleteKeys(ctx context.Context, client *redis.Client, pattern string, keys ...string) error {
+	iter := client.Scan(ctx, 0, pattern, 0).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return client.Del(ctx, keys...).Err()
+}
+
+// MigrateStatsMembers rewrites the stats members of the comma separated format of the previous
+// versions as JSON members, adding up their hits. Once done it is recorded in Redis, so further
+// calls return immediately. Each key is migrated in a transaction, so concurrent calls are safe.
+func (r *RedisStatsRepository) MigrateStatsMembers(ctx context.Context) error {
+	migrated, err := r.client.Exists(ctx, RedisKeyStatsEncoding).Result()
+	if err != nil || migrated > 0 {
+		return err
+	}
+
+	keys := []string{r.statsKey}
+	iter := r.client.Scan(ctx, 0, prefixPattern(r.bucketPrefix), 0).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := r.migrateStatsKey(ctx, key); err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", key, err)
+		}
+	}
+	return r.client.Set(ctx, RedisKeyStatsEncoding, statsEncodingJSON, 0).Err()
+}
+
+// migrateStatsKey rewrites the legacy members of a stats key, retrying when the key is updated meanwhile
+func (r *RedisStatsRepository) migrateStatsKey(ctx context.Context, key string) error {
+	migrate := func(tx *redis.Tx) error {
+		members, err := tx.ZRangeWithScores(ctx, key, 0, -1).Result()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, member := range members {
+				legacy := member.Member.(string)
+				if strings.HasPrefix(legacy, "{") {
+					continue
+				}
+				request, err := parseLegacyStatsMember(legacy)
+				if err != nil {
+					return err
+				}
+				encoded, err := encodeStatsMember(request)
+				if err != nil {
+					return err
+				}
+				pipe.ZRem(ctx, key, legacy)
+				pipe.ZIncrBy(ctx, key, member.Score, encoded)
+			}
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < statsMigrationAttempts; attempt++ {
+		err := r.client.Watch(ctx, migrate, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return errors.New("too many concurrent updates")
+}
+
+// bucketKey returns the key of the statistics bucket starting at start
+func (r *RedisStatsRepository) bucketKey(start time.Time) string {
+	return r.bucketPrefix + strconv.FormatInt(start.Unix(), 10)
+}
+
+// CheckReadiness returns the readiness of the repository, unavailable when Redis does not answer a ping
+func (r *RedisStatsRepository) CheckReadiness(ctx context.Context) model.ComponentHealth {
+	return pingReadiness(ctx, r.client, r.timeout, "stats-redis")
+}
+
+// prefixPattern returns the SCAN pattern of the keys starting with prefix, the glob characters of
+// the prefix escaped
+func prefixPattern(prefix string) string {
+	var pattern strings.Builder
+	for _, c := range prefix {
+		if strings.ContainsRune(`*?[]\`, c) {
+			pattern.WriteByte('\\')
+		}
+		pattern.WriteRune(c)
+	}
+	pattern.WriteByte('*')
+	return pattern.String()
+}
+
+// pingReadiness returns the readiness of the component named name, unavailable when Redis does not answer a ping
+func pingReadiness(ctx context.Context, client *redis.Client, timeout time.Duration, name string) model.ComponentHealth {
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return model.ComponentHealth{Name: name, Status: model.HealthStatusUnavailable, Error: err.Error()}
+	}
+	return model.ComponentHealth{Name: name, Status: model.HealthStatusOK}
+}
+
+// encodeStatsMember encodes the request parameters as a JSON stats member, which stays unambiguous
+// when the words contain commas
+func encodeStatsMember(request model.FizzBuzzRequest) (string, error) {
+	member, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode stats member: %w", err)
+	}
+	return string(member), nil
+}
+
+// decodeStatsMember decodes the request parameters of a stats member built by encodeStatsMember
+func decodeStatsMember(member string) (request model.FizzBuzzRequest, err error) {
+	if err = json.Unmarshal([]byte(member), &request); err != nil {
+		return request, fmt.Errorf("invalid stats member %q: %w", member, err)
+	}
+	return request, nil
+}
+
+// parseLegacyStatsMember parses the request parameters from a stats member of the previous versions,
+// built as "int1,int2,limit,str1,str2". Commas in the words make it ambiguous, the extra parts are
+// kept in str2 as the best guess.
+func parseLegacyStatsMember(member string) (request model.FizzBuzzRequest, err error) {
+	parts := strings.Split(member, ",")
+	if len(parts) < 5 {
+		return request, fmt.Errorf("invalid stats member: %q", member)
+	}
+
+	request.Int1, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return request, fmt.Errorf("failed to parse int1: %w", err)
+	}
+	request.Int2, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return request, fmt.Errorf("failed to parse int2: %w", err)
+	}
+	request.Limit, err = strconv.Atoi(parts[2])
+	if err != nil {
+		return request, fmt.Errorf("failed to parse limit: %w", err)
+	}
+	request.Str1 = parts[3]
+	request.Str2 = strings.Join(parts[4:], ",")
+	return request, nil
 }
@@ -2,11 +2,14 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
 	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
 )
 
@@ -50,7 +53,7 @@ func TestRedisStatsRepository_GetMostFrequentRequest(t *testing.T) {
 				// Simulate a single request in Redis
 				redisClient.ZAdd(redisClient.Context(), "fizzbuzz:stats", &redis.Z{
 					Score:  10,
-					Member: "3,5,15,Fizz,Buzz",
+					Member: `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`,
 				})
 			},
 			wantStats: &model.StatsResult{
@@ -62,6 +65,23 @@ func TestRedisStatsRepository_GetMostFrequentRequest(t *testing.T) {
 				Hits:  10,
 			},
 		},
+		{
+			name: "Rule list request",
+			fields: fields{
+				client: redisClient,
+			},
+			beforeCall: func() {
+				redisClient.ZAdd(redisClient.Context(), "fizzbuzz:stats", &redis.Z{
+					Score:  20,
+					Member: `{"int1":0,"int2":0,"limit":21,"str1":"","str2":"","rules":[{"divisor":3,"word":"Fizz"},{"divisor":5,"word":"Buzz"},{"divisor":7,"word":"Bazz"}]}`,
+				})
+			},
+			wantStats: &model.StatsResult{
+				Limit: 21,
+				Rules: []model.Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 5, Word: "Buzz"}, {Divisor: 7, Word: "Bazz"}},
+				Hits:  20,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -69,7 +89,7 @@ func TestRedisStatsRepository_GetMostFrequentRequest(t *testing.T) {
 			if tt.beforeCall != nil {
 				tt.beforeCall()
 			}
-			gotStats, err := r.GetMostFrequentRequest()
+			gotStats, err := r.GetMostFrequentRequest(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetMostFrequentRequest() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -91,6 +111,7 @@ func TestRedisStatsRepository_IncrementRequestCount(t *testing.T) {
 		limit int
 		str1  string
 		str2  string
+		rules []model.Rule
 	}
 	tests := []struct {
 		name    string
@@ -112,17 +133,66 @@ func TestRedisStatsRepository_IncrementRequestCount(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Increment rule list request",
+			fields: fields{
+				client: redisClient,
+			},
+			args: args{
+				limit: 21,
+				rules: []model.Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 5, Word: "Buzz"}, {Divisor: 7, Word: "Bazz"}},
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			r := NewRedisStatsRepository(tt.fields.client)
-			if err := r.IncrementRequestCount(tt.args.int1, tt.args.int2, tt.args.limit, tt.args.str1, tt.args.str2); (err != nil) != tt.wantErr {
+			request := model.FizzBuzzRequest{
+				Int1:  tt.args.int1,
+				Int2:  tt.args.int2,
+				Limit: tt.args.limit,
+				Str1:  tt.args.str1,
+				Str2:  tt.args.str2,
+				Rules: tt.args.rules,
+			}
+			if err := r.IncrementRequestCount(context.Background(), request); (err != nil) != tt.wantErr {
 				t.Errorf("IncrementRequestCount() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
 
+func TestRedisStatsRepository_AddRequestCounts(t *testing.T) {
+	fizzBuzz := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	rules := model.FizzBuzzRequest{Limit: 21, Rules: []model.Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 7, Word: "Bazz"}}}
+
+	r := NewRedisStatsRepository(redisClient)
+	if err := r.ResetStats(context.Background()); err != nil {
+		t.Fatalf("ResetStats() error = %v", err)
+	}
+	counts := []model.RequestCount{{Request: fizzBuzz, Count: 3}, {Request: rules, Count: 5}, {Request: fizzBuzz, Count: 4}}
+	if err := r.AddRequestCounts(context.Background(), counts); err != nil {
+		t.Fatalf("AddRequestCounts() error = %v", err)
+	}
+	if err := r.AddRequestCounts(context.Background(), nil); err != nil {
+		t.Errorf("AddRequestCounts() without counts error = %v", err)
+	}
+
+	got, err := r.GetMostFrequentRequest(context.Background())
+	if err != nil || !reflect.DeepEqual(got, model.NewStatsResult(fizzBuzz, 7)) {
+		t.Errorf("GetMostFrequentRequest() got = %v, %v, want %s with 7 hits", got, err, fizzBuzz.Key())
+	}
+	windowed, err := r.GetMostFrequentRequestBetween(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	if err != nil || !reflect.DeepEqual(windowed, model.NewStatsResult(fizzBuzz, 7)) {
+		t.Errorf("GetMostFrequentRequestBetween() got = %v, %v, want %s with 7 hits", windowed, err, fizzBuzz.Key())
+	}
+	page, _ := r.GetRequestsByHits(context.Background(), 1, 1)
+	if page == nil || len(page.Results) != 1 || !reflect.DeepEqual(page.Results[0], *model.NewStatsResult(rules, 5)) {
+		t.Errorf("GetRequestsByHits() got = %+v, want %s with 5 hits", page, rules.Key())
+	}
+}
+
 func TestRedisStatsRepository_ResetStats(t *testing.T) {
 	type fields struct {
 		client *redis.Client
@@ -151,10 +221,351 @@ func TestRedisStatsRepository_ResetStats(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			r := NewRedisStatsRepository(tt.fields.client)
 
-			if err := r.ResetStats(); (err != nil) != tt.wantErr {
+			if err := r.ResetStats(context.Background()); (err != nil) != tt.wantErr {
 				t.Errorf("ResetStats() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			tt.checkStatus()
 		})
 	}
 }
+
+func TestRedisStatsRepository_GetRequestsByHits(t *testing.T) {
+	redisClient.FlushAll(context.Background())
+	redisClient.ZAdd(context.Background(), RedisKeyStats,
+		&redis.Z{Score: 10, Member: `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`},
+		&redis.Z{Score: 5, Member: `{"int1":2,"int2":7,"limit":20,"str1":"Foo","str2":"Bar"}`},
+		&redis.Z{Score: 15, Member: `{"int1":4,"int2":6,"limit":30,"str1":"Qux","str2":"Quux"}`},
+	)
+
+	tests := []struct {
+		name   string
+		offset int
+		count  int
+		want   *model.StatsPage
+	}{
+		{
+			name:   "Top two requests",
+			offset: 0,
+			count:  2,
+			want: &model.StatsPage{
+				Results: []model.StatsResult{
+					{Int1: 4, Int2: 6, Limit: 30, Str1: "Qux", Str2: "Quux", Hits: 15},
+					{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 10},
+				},
+				Total: 3,
+			},
+		},
+		{
+			name:   "Last page",
+			offset: 2,
+			count:  2,
+			want: &model.StatsPage{
+				Results: []model.StatsResult{
+					{Int1: 2, Int2: 7, Limit: 20, Str1: "Foo", Str2: "Bar", Hits: 5},
+				},
+				Total: 3,
+			},
+		},
+		{
+			name:   "Offset after the last request",
+			offset: 3,
+			count:  2,
+			want: &model.StatsPage{
+				Results: []model.StatsResult{},
+				Total:   3,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRedisStatsRepository(redisClient)
+			got, err := r.GetRequestsByHits(context.Background(), tt.offset, tt.count)
+			if err != nil {
+				t.Errorf("GetRequestsByHits() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetRequestsByHits() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisStatsRepository_RemoveRequest(t *testing.T) {
+	redisClient.FlushAll(context.Background())
+	redisClient.ZAdd(context.Background(), RedisKeyStats,
+		&redis.Z{Score: 10, Member: `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`},
+		&redis.Z{Score: 5, Member: `{"int1":2,"int2":7,"limit":20,"str1":"Foo","str2":"Bar"}`},
+	)
+
+	tests := []struct {
+		name        string
+		request     model.FizzBuzzRequest
+		wantRemoved bool
+	}{
+		{
+			name:        "Remove existing request",
+			request:     model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"},
+			wantRemoved: true,
+		},
+		{
+			name:        "Remove unknown request",
+			request:     model.FizzBuzzRequest{Int1: 4, Int2: 6, Limit: 30, Str1: "Qux", Str2: "Quux"},
+			wantRemoved: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRedisStatsRepository(redisClient)
+			removed, err := r.RemoveRequest(context.Background(), tt.request)
+			if err != nil {
+				t.Errorf("RemoveRequest() error = %v", err)
+				return
+			}
+			if removed != tt.wantRemoved {
+				t.Errorf("RemoveRequest() removed = %v, want %v", removed, tt.wantRemoved)
+			}
+		})
+	}
+
+	if got := redisClient.ZCard(context.Background(), RedisKeyStats).Val(); got != 1 {
+		t.Errorf("Expected 1 request left after removal, got %d", got)
+	}
+}
+
+func TestRedisStatsRepository_GetMostFrequentRequestBetween(t *testing.T) {
+	redisClient.FlushAll(context.Background())
+	start := time.Now().Truncate(time.Hour).Add(30 * time.Minute)
+	now := start
+	clock := func() time.Time { return now }
+	r := NewRedisStatsRepository(redisClient, WithClock(clock), WithRetention(48*time.Hour))
+
+	fizzBuzz := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	fooBar := model.FizzBuzzRequest{Int1: 2, Int2: 7, Limit: 20, Str1: "Foo", Str2: "Bar"}
+	record := func(at time.Time, request model.FizzBuzzRequest, hits int) {
+		now = at
+		for i := 0; i < hits; i++ {
+			if err := r.IncrementRequestCount(context.Background(), request); err != nil {
+				t.Fatalf("IncrementRequestCount() error = %v", err)
+			}
+		}
+	}
+	// fizzBuzz was popular three days ago, past the retention, and yesterday, fooBar is popular now
+	record(start.Add(-72*time.Hour), fizzBuzz, 10)
+	record(start.Add(-27*time.Hour), fizzBuzz, 5)
+	record(start.Add(-20*time.Minute), fooBar, 3)
+	now = start
+
+	tests := []struct {
+		name string
+		from time.Time
+		to   time.Time
+		want *model.StatsResult
+	}{
+		{
+			name: "last hour",
+			from: now.Add(-time.Hour),
+			to:   now,
+			want: model.NewStatsResult(fooBar, 3),
+		},
+		{
+			name: "last week, limited to the retention",
+			from: now.Add(-7 * 24 * time.Hour),
+			to:   now,
+			want: model.NewStatsResult(fizzBuzz, 5),
+		},
+		{
+			name: "range without requests",
+			from: now.Add(-26 * time.Hour),
+			to:   now.Add(-time.Hour),
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.GetMostFrequentRequestBetween(context.Background(), tt.from, tt.to)
+			if err != nil {
+				t.Errorf("GetMostFrequentRequestBetween() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetMostFrequentRequestBetween() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	all, _ := r.GetMostFrequentRequest(context.Background())
+	if want := model.NewStatsResult(fizzBuzz, 15); !reflect.DeepEqual(all, want) {
+		t.Errorf("GetMostFrequentRequest() got = %v, want %v", all, want)
+	}
+
+	if _, err := r.RemoveRequest(context.Background(), fooBar); err != nil {
+		t.Errorf("RemoveRequest() error = %v", err)
+	}
+	if got, _ := r.GetMostFrequentRequestBetween(context.Background(), now.Add(-time.Hour), now); got != nil {
+		t.Errorf("GetMostFrequentRequestBetween() after RemoveRequest() got = %v, want nil", got)
+	}
+
+	if err := r.ResetStats(context.Background()); err != nil {
+		t.Errorf("ResetStats() error = %v", err)
+	}
+	if got, _ := r.GetMostFrequentRequestBetween(context.Background(), now.Add(-24*time.Hour), now); got != nil {
+		t.Errorf("GetMostFrequentRequestBetween() after ResetStats() got = %v, want nil", got)
+	}
+}
+
+func TestRedisStatsRepository_MemberEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		request model.FizzBuzzRequest
+	}{
+		{
+			name:    "Commas in the words",
+			request: model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "a,b", Str2: "c,d,e"},
+		},
+		{
+			name:    "Unicode words",
+			request: model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz 🐝", Str2: "Bözz\"\\"},
+		},
+		{
+			name:    "Empty words",
+			request: model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15},
+		},
+		{
+			name:    "Rule words with commas and colons",
+			request: model.FizzBuzzRequest{Limit: 21, Rules: []model.Rule{{Divisor: 3, Word: "1:a,"}, {Divisor: 7, Word: ""}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient.FlushAll(context.Background())
+			r := NewRedisStatsRepository(redisClient)
+
+			// A request whose words only differ by the position of the commas is counted apart
+			other := tt.request
+			other.Str1 += ","
+			_ = r.IncrementRequestCount(context.Background(), other)
+
+			for i := 0; i < 2; i++ {
+				if err := r.IncrementRequestCount(context.Background(), tt.request); err != nil {
+					t.Errorf("IncrementRequestCount() error = %v", err)
+					return
+				}
+			}
+			got, err := r.GetMostFrequentRequest(context.Background())
+			if err != nil {
+				t.Errorf("GetMostFrequentRequest() error = %v", err)
+				return
+			}
+			if want := model.NewStatsResult(tt.request, 2); !reflect.DeepEqual(got, want) {
+				t.Errorf("GetMostFrequentRequest() got = %v, want %v", got, want)
+			}
+
+			removed, err := r.RemoveRequest(context.Background(), tt.request)
+			if err != nil || !removed {
+				t.Errorf("RemoveRequest() removed = %v, error = %v", removed, err)
+			}
+		})
+	}
+}
+
+func TestRedisStatsRepository_MigrateStatsMembers(t *testing.T) {
+	ctx := context.Background()
+	redisClient.FlushAll(ctx)
+	bucketKey := NewRedisStatsRepository(redisClient).bucketKey(bucketStart(time.Now()))
+	redisClient.ZAdd(ctx, RedisKeyStats,
+		&redis.Z{Score: 10, Member: "3,5,15,Fizz,Buzz"},
+		&redis.Z{Score: 4, Member: `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`},
+		&redis.Z{Score: 7, Member: "2,7,20,Foo,Bar,Baz"},
+	)
+	redisClient.ZAdd(ctx, bucketKey, &redis.Z{Score: 2, Member: "3,5,15,Fizz,Buzz"})
+
+	r := NewRedisStatsRepository(redisClient)
+	if err := r.MigrateStatsMembers(context.Background()); err != nil {
+		t.Fatalf("MigrateStatsMembers() error = %v", err)
+	}
+
+	page, err := r.GetRequestsByHits(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatalf("GetRequestsByHits() error = %v", err)
+	}
+	want := &model.StatsPage{
+		Results: []model.StatsResult{
+			{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 14},
+			{Int1: 2, Int2: 7, Limit: 20, Str1: "Foo", Str2: "Bar,Baz", Hits: 7},
+		},
+		Total: 2,
+	}
+	if !reflect.DeepEqual(page, want) {
+		t.Errorf("GetRequestsByHits() got = %v, want %v", page, want)
+	}
+	got, err := r.GetMostFrequentRequestBetween(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	if err != nil || got == nil || got.Hits != 2 {
+		t.Errorf("GetMostFrequentRequestBetween() got = %v, error = %v, want 2 hits", got, err)
+	}
+
+	// The migration runs once
+	redisClient.ZAdd(ctx, RedisKeyStats, &redis.Z{Score: 1, Member: "4,6,30,Qux,Quux"})
+	if err := r.MigrateStatsMembers(context.Background()); err != nil {
+		t.Errorf("MigrateStatsMembers() error = %v", err)
+	}
+	if got := redisClient.ZScore(ctx, RedisKeyStats, "4,6,30,Qux,Quux").Val(); got != 1 {
+		t.Errorf("Expected the second migration to be skipped, got score %v", got)
+	}
+}
+
+func TestRedisStatsRepository_Context(t *testing.T) {
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewRedisStatsRepository(redisClient).IncrementRequestCount(ctx, request); !errors.Is(err, context.Canceled) {
+		t.Errorf("IncrementRequestCount() error = %v, want %v", err, context.Canceled)
+	}
+
+	if _, err := NewRedisStatsRepository(redisClient, WithTimeout(time.Nanosecond)).GetMostFrequentRequest(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetMostFrequentRequest() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRedisAdapters_CheckReadiness(t *testing.T) {
+	unreachable := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer unreachable.Close()
+
+	tests := []struct {
+		name       string
+		checker    adapters.ReadinessChecker
+		wantName   string
+		wantStatus model.HealthStatus
+	}{
+		{
+			name:       "Stats repository",
+			checker:    NewRedisStatsRepository(redisClient),
+			wantName:   "stats-redis",
+			wantStatus: model.HealthStatusOK,
+		},
+		{
+			name:       "Cache",
+			checker:    NewCacheRedis(redisClient),
+			wantName:   "cache-redis",
+			wantStatus: model.HealthStatusOK,
+		},
+		{
+			name:       "Unreachable Redis",
+			checker:    NewRedisStatsRepository(unreachable),
+			wantName:   "stats-redis",
+			wantStatus: model.HealthStatusUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.checker.CheckReadiness(context.Background())
+			if got.Name != tt.wantName || got.Status != tt.wantStatus {
+				t.Errorf("CheckReadiness() got = %+v, want %s %s", got, tt.wantName, tt.wantStatus)
+			}
+			if (got.Error != "") != (tt.wantStatus != model.HealthStatusOK) {
+				t.Errorf("CheckReadiness() error got = %q", got.Error)
+			}
+		})
+	}
+}
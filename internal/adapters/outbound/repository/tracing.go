@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the spans of the Redis adapters
+const tracerName = "github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/repository"
+
+// startRedisSpan starts the client span of a Redis adapter operation running the command
+func startRedisSpan(ctx context.Context, name, command string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemNameRedis, semconv.DBOperationName(command)))
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRedisAdapters_Spans(t *testing.T) {
+	redisClient.FlushAll(context.Background())
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	unreachable := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer unreachable.Close()
+
+	tests := []struct {
+		name     string
+		call     func(ctx context.Context) error
+		wantSpan string
+		wantErr  bool
+	}{
+		{
+			name: "Cache get",
+			call: func(ctx context.Context) error {
+				_, err := NewCacheRedis(redisClient).Get(ctx, request.Key())
+				return err
+			},
+			wantSpan: "redis.cache.get",
+		},
+		{
+			name: "Cache set",
+			call: func(ctx context.Context) error {
+				return NewCacheRedis(redisClient).Set(ctx, request.Key(), "1,2,Fizz")
+			},
+			wantSpan: "redis.cache.set",
+		},
+		{
+			name: "Cache flush",
+			call: func(ctx context.Context) error {
+				return NewCacheRedis(redisClient).Flush(ctx)
+			},
+			wantSpan: "redis.cache.flush",
+		},
+		{
+			name: "Stats increment",
+			call: func(ctx context.Context) error {
+				return NewRedisStatsRepository(redisClient).IncrementRequestCount(ctx, request)
+			},
+			wantSpan: "redis.stats.add_request_counts",
+		},
+		{
+			name: "Stats most frequent request",
+			call: func(ctx context.Context) error {
+				_, err := NewRedisStatsRepository(redisClient).GetMostFrequentRequest(ctx)
+				return err
+			},
+			wantSpan: "redis.stats.get_most_frequent_request",
+		},
+		{
+			name: "Stats most frequent request between",
+			call: func(ctx context.Context) error {
+				_, err := NewRedisStatsRepository(redisClient).GetMostFrequentRequestBetween(ctx, time.Now().Add(-time.Hour), time.Now())
+				return err
+			},
+			wantSpan: "redis.stats.get_most_frequent_request_between",
+		},
+		{
+			name: "Stats reset",
+			call: func(ctx context.Context) error {
+				return NewRedisStatsRepository(redisClient).ResetStats(ctx)
+			},
+			wantSpan: "redis.stats.reset_stats",
+		},
+		{
+			name: "Unreachable Redis",
+			call: func(ctx context.Context) error {
+				_, err := NewRedisStatsRepository(unreachable).RemoveRequest(ctx, request)
+				return err
+			},
+			wantSpan: "redis.stats.remove_request",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			otel.SetTracerProvider(provider)
+
+			ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+			err := tt.call(ctx)
+			parent.End()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("call error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			spans := recorder.Ended()
+			if len(spans) != 2 || spans[0].Name() != tt.wantSpan {
+				t.Fatalf("spans got = %v, want %s", spans, tt.wantSpan)
+			}
+			span := spans[0]
+			if span.SpanKind() != trace.SpanKindClient || span.Parent().SpanID() != parent.SpanContext().SpanID() {
+				t.Errorf("span got kind %v and parent %v, want a client child span", span.SpanKind(), span.Parent().SpanID())
+			}
+			if gotErr := span.Status().Code == codes.Error; gotErr != tt.wantErr {
+				t.Errorf("span status got = %+v, wantErr %v", span.Status(), tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporter is the destination of the spans
+type Exporter string
+
+const (
+	// ExporterNone discards the spans, the trace context is still propagated
+	ExporterNone Exporter = "none"
+	// ExporterOTLP sends the spans to an OpenTelemetry collector over OTLP/HTTP
+	ExporterOTLP Exporter = "otlp"
+	// ExporterStdout writes the spans as JSON to the standard output, or to a file
+	ExporterStdout Exporter = "stdout"
+
+	// DefaultServiceName is the service name reported with the spans
+	DefaultServiceName = "fizzbuzz-api"
+)
+
+// Option configures the tracer provider
+type Option func(*options)
+
+type options struct {
+	serviceName  string
+	otlpEndpoint string
+	file         string
+	sampleRatio  float64
+}
+
+// WithServiceName sets the service name reported with the spans
+func WithServiceName(name string) Option {
+	return func(o *options) {
+		if name != "" {
+			o.serviceName = name
+		}
+	}
+}
+
+// WithOTLPEndpoint sets the URL of the OTLP/HTTP collector, the OTEL_EXPORTER_OTLP_* variables apply when empty
+func WithOTLPEndpoint(endpoint string) Option {
+	return func(o *options) {
+		o.otlpEndpoint = endpoint
+	}
+}
+
+// WithFile sets the file the stdout exporter appends the spans to, the standard output is used when empty
+func WithFile(path string) Option {
+	return func(o *options) {
+		o.file = path
+	}
+}
+
+// WithSampleRatio sets the ratio of the traces started by the service which are recorded,
+// the traces started by the callers follow their sampling decision
+func WithSampleRatio(ratio float64) Option {
+	return func(o *options) {
+		o.sampleRatio = ratio
+	}
+}
+
+// Setup installs the global tracer provider exporting the spans to exporter, and the W3C trace context
+// propagator. The returned function flushes the pending spans and stops the exporter.
+func Setup(ctx context.Context, exporter Exporter, opts ...Option) (func(context.Context) error, error) {
+	o := &options{serviceName: DefaultServiceName, sampleRatio: 1}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var (
+		spanExporter sdktrace.SpanExporter
+		closer       io.Closer
+		err          error
+	)
+	switch exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var exporterOpts []otlptracehttp.Option
+		if o.otlpEndpoint != "" {
+			exporterOpts = append(exporterOpts, otlptracehttp.WithEndpointURL(o.otlpEndpoint))
+		}
+		spanExporter, err = otlptracehttp.New(ctx, exporterOpts...)
+	case ExporterStdout:
+		var writer io.Writer = os.Stdout
+		if o.file != "" {
+			file, fileErr := os.OpenFile(o.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if fileErr != nil {
+				return nil, fmt.Errorf("failed to open the traces file: %w", fileErr)
+			}
+			writer, closer = file, file
+		}
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(writer))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the %s exporter: %w", exporter, err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+		resource.WithAttributes(semconv.ServiceName(o.serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(o.sampleRatio))))
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}, nil
+}
+
+// End records err on the span, when there is one, and ends the span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
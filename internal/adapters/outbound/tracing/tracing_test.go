@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter Exporter
+		file     bool
+		wantErr  bool
+		wantSpan bool
+	}{
+		{name: "No exporter", exporter: ExporterNone},
+		{name: "Stdout exporter writing to a file", exporter: ExporterStdout, file: true, wantSpan: true},
+		{name: "Unknown exporter", exporter: "jaeger", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "traces.json")
+			var opts []Option
+			if tt.file {
+				opts = append(opts, WithFile(path))
+			}
+
+			shutdown, err := Setup(context.Background(), tt.exporter, opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			_, span := otel.Tracer("test").Start(context.Background(), "test.span")
+			span.End()
+			if err := shutdown(context.Background()); err != nil {
+				t.Fatalf("shutdown() error = %v", err)
+			}
+
+			if !tt.file {
+				return
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			if got := strings.Contains(string(content), `"Name":"test.span"`); got != tt.wantSpan {
+				t.Errorf("traces file got = %s, want the span exported", content)
+			}
+			if !strings.Contains(string(content), DefaultServiceName) {
+				t.Errorf("traces file got = %s, want the service name", content)
+			}
+		})
+	}
+}
+
+func TestEnd(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	_, span := tracer.Start(context.Background(), "ok")
+	End(span, nil)
+	_, span = tracer.Start(context.Background(), "failed")
+	End(span, errors.New("redis down"))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	if got := spans[0].Status().Code; got != codes.Unset {
+		t.Errorf("status of a successful span got = %v, want unset", got)
+	}
+	if got := spans[1].Status(); got.Code != codes.Error || got.Description != "redis down" {
+		t.Errorf("status of a failed span got = %+v, want the error", got)
+	}
+	if got := len(spans[1].Events()); got != 1 {
+		t.Errorf("events of a failed span got = %d, want the error recorded", got)
+	}
+}
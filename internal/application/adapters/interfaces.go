@@ -1,29 +1,110 @@
 package adapters
 
-import "github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+import (
+	"context"
+	"iter"
+	"time"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+)
 
 //go:generate mockgen -source=interfaces.go -destination=mock_interfaces.go -package=adapters
 
 type StatsRepository interface {
 	// GetMostFrequentRequest returns the most frequent request parameters and their hit count
-	GetMostFrequentRequest() (stats *model.StatsResult, err error)
+	GetMostFrequentRequest(ctx context.Context) (stats *model.StatsResult, err error)
+	// GetMostFrequentRequestBetween returns the most frequent request parameters and their hit count
+	// between from and to, at the precision of the statistics buckets
+	GetMostFrequentRequestBetween(ctx context.Context, from, to time.Time) (stats *model.StatsResult, err error)
+	// GetRequestsByHits returns count requests parameters ordered by hit count, skipping the first offset ones
+	GetRequestsByHits(ctx context.Context, offset, count int) (page *model.StatsPage, err error)
 	// IncrementRequestCount increments the count for a specific request parameters
-	IncrementRequestCount(int1, int2, limit int, str1, str2 string) error
+	IncrementRequestCount(ctx context.Context, request model.FizzBuzzRequest) error
+	// AddRequestCounts adds the hits of several request parameters at once
+	AddRequestCounts(ctx context.Context, counts []model.RequestCount) error
+	// RemoveRequest removes the statistics of a specific request parameters
+	RemoveRequest(ctx context.Context, request model.FizzBuzzRequest) (removed bool, err error)
 	// ResetStats resets the statistics data
-	ResetStats() error
+	ResetStats(ctx context.Context) error
 }
 
 type CacheFizzbuzz interface {
-	Get(key string) (string, error)
-	Set(key string, value string) error
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value string) error
+	// Flush removes every cached value of the service
+	Flush(ctx context.Context) error
 }
 
 type FizzBuzzService interface {
 	// GenerateFizzBuzz generates the FizzBuzz sequence for given parameters
-	GenerateFizzBuzz(int1, int2, limit int, str1, str2 string) (string, error)
+	GenerateFizzBuzz(ctx context.Context, request model.FizzBuzzRequest) (string, error)
+	// GenerateFizzBuzzBatch generates the FizzBuzz sequences of a batch of requests, the results are in the
+	// order of the requests
+	GenerateFizzBuzzBatch(ctx context.Context, requests []model.FizzBuzzRequest) ([]model.FizzBuzzBatchResult, error)
+	// StreamFizzBuzz returns the FizzBuzz sequence for given parameters as an iterator over its terms
+	StreamFizzBuzz(ctx context.Context, request model.FizzBuzzRequest) (iter.Seq[string], error)
+	// FlushCache removes every cached FizzBuzz sequence
+	FlushCache(ctx context.Context) error
 }
 
 type StatsService interface {
 	// GetStats returns the statistics of the application
-	GetStats() (*model.StatsResult, error)
+	GetStats(ctx context.Context) (*model.StatsResult, error)
+	// GetStatsBetween returns the statistics of the application between from and to
+	GetStatsBetween(ctx context.Context, from, to time.Time) (*model.StatsResult, error)
+	// GetTopRequests returns the n most frequent requests
+	GetTopRequests(ctx context.Context, n int) (*model.StatsPage, error)
+	// GetRequests returns a page of the requests ordered by hit count
+	GetRequests(ctx context.Context, page, pageSize int) (*model.StatsPage, error)
+	// RemoveRequest removes the statistics of a specific request parameters
+	RemoveRequest(ctx context.Context, request model.FizzBuzzRequest) error
+	// ResetStats resets the statistics data
+	ResetStats(ctx context.Context) error
+	// GetKeyStats returns the statistics of the requests authenticated with the API key named key
+	GetKeyStats(ctx context.Context, key string) (*model.StatsResult, error)
+	// GetKeyStatsBetween returns the statistics of the requests authenticated with the API key named key
+	// between from and to
+	GetKeyStatsBetween(ctx context.Context, key string, from, to time.Time) (*model.StatsResult, error)
+}
+
+type HealthReporter interface {
+	// Health returns the health of the component
+	Health() model.ComponentHealth
+}
+
+// APIKeyStore holds the API keys of the clients
+type APIKeyStore interface {
+	// GetAPIKey returns the API key matching key, nil when there is none
+	GetAPIKey(ctx context.Context, key string) (*model.APIKey, error)
+}
+
+// APIKeyStatsRepository holds the statistics of the requests of each API key
+type APIKeyStatsRepository interface {
+	// ForAPIKey returns the stats repository of the API key named key
+	ForAPIKey(key string) StatsRepository
+	// ResetStats resets the statistics of every API key
+	ResetStats(ctx context.Context) error
+	// RemoveRequest removes the statistics of a specific request parameters from every API key
+	RemoveRequest(ctx context.Context, request model.FizzBuzzRequest) error
+}
+
+// RateLimiter limits the requests of the clients with a token bucket per client
+type RateLimiter interface {
+	// Take takes cost tokens from the bucket of key when it holds enough of them, and returns the state of the bucket
+	Take(ctx context.Context, key string, cost int) (model.RateLimit, error)
+}
+
+// ReadinessChecker checks whether a dependency in use can serve requests
+type ReadinessChecker interface {
+	// CheckReadiness returns the state of the dependency, unavailable when it cannot serve requests
+	CheckReadiness(ctx context.Context) model.ComponentHealth
+}
+
+type Metrics interface {
+	// ObserveFizzBuzzLimit records the limit of a served FizzBuzz request
+	ObserveFizzBuzzLimit(limit int)
+	// ObserveCacheLookup records a lookup of the FizzBuzz cache
+	ObserveCacheLookup(hit bool)
+	// ObserveStatsOperation records the duration and the outcome of a stats repository operation
+	ObserveStatsOperation(operation string, duration time.Duration, err error)
 }
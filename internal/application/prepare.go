@@ -9,13 +9,13 @@ import (
 	"github.com/niltonkummer/fizzbuzz-api/internal/application/services/stats"
 )
 
-func InitServices(ctx context.Context, repo adapters.StatsRepository, opts ...fizzbuzz.Option) *httpIn.Router {
+func InitServices(ctx context.Context, repo adapters.StatsRepository, routerOpts []httpIn.Option, handlerOpts []httpIn.HandlerOption, statsOpts []stats.Option, opts ...fizzbuzz.Option) *httpIn.Router {
 	fizzBuzzService := fizzbuzz.NewFizzBuzzService(repo, opts...)
-	statsService := stats.NewStats(repo)
+	statsService := stats.NewStats(repo, statsOpts...)
 
-	handler := httpIn.NewHandler(fizzBuzzService, statsService)
+	handler := httpIn.NewHandler(fizzBuzzService, statsService, handlerOpts...)
 
-	router := httpIn.NewRouter(ctx)
+	router := httpIn.NewRouter(ctx, routerOpts...)
 	router.RegisterRoutes(handler)
 	return router
 }
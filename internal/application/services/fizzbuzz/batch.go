@@ -0,0 +1,103 @@
+package fizzbuzz
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/logging"
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/tracing"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// defaultBatchWorkers is the number of requests of a batch generated concurrently by default
+	defaultBatchWorkers = 8
+	// defaultBatchMaxItems is the number of requests of a batch allowed by default
+	defaultBatchMaxItems = 1000
+	// defaultBatchMaxWork is the sum of the limits of a batch allowed by default
+	defaultBatchMaxWork = 1000000
+)
+
+// WithBatchWorkers sets the number of requests of a batch generated concurrently
+func WithBatchWorkers(workers int) Option {
+	return func(s *Service) {
+		if workers > 0 {
+			s.batchWorkers = workers
+		}
+	}
+}
+
+// WithBatchBudget sets the number of requests of a batch and the sum of their limits allowed
+func WithBatchBudget(maxItems, maxWork int) Option {
+	return func(s *Service) {
+		if maxItems > 0 {
+			s.batchMaxItems = maxItems
+		}
+		if maxWork > 0 {
+			s.batchMaxWork = maxWork
+		}
+	}
+}
+
+// GenerateFizzBuzzBatch generates the sequences of requests with a bounded number of workers. Each request goes
+// through the cache and is counted in the statistics as when sent alone, and its error is returned in its result
+// so it does not fail the others. The batch fails with model.ErrBatchTooLarge when it has too many requests or
+// when their limits add up to more than the work budget.
+func (fb *Service) GenerateFizzBuzzBatch(ctx context.Context, requests []model.FizzBuzzRequest) (results []model.FizzBuzzBatchResult, err error) {
+	ctx, span := startSpan(ctx, "fizzbuzz.batch", attribute.Int("fizzbuzz.batch.size", len(requests)))
+	defer func() { tracing.End(span, err) }()
+
+	if len(requests) > fb.batchMaxItems {
+		return nil, model.ErrBatchTooLarge.WithMessage(
+			fmt.Sprintf("The batch has %d requests, more than the %d allowed", len(requests), fb.batchMaxItems))
+	}
+	work := 0
+	for _, request := range requests {
+		work += max(request.Limit, 0)
+	}
+	if work > fb.batchMaxWork {
+		return nil, model.ErrBatchTooLarge.WithMessage(
+			fmt.Sprintf("The limits of the batch add up to %d, more than the %d allowed", work, fb.batchMaxWork))
+	}
+	span.SetAttributes(attribute.Int("fizzbuzz.batch.work", work))
+
+	indexes := make(chan int, len(requests))
+	for i := range requests {
+		indexes <- i
+	}
+	close(indexes)
+
+	results = make([]model.FizzBuzzBatchResult, len(requests))
+	var cacheHits, failed atomic.Int64
+	var wg sync.WaitGroup
+	for range min(fb.batchWorkers, len(requests)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				// The requests left once the batch is cancelled are not generated
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					failed.Add(1)
+					continue
+				}
+				res, cached, err := fb.generate(ctx, requests[i])
+				results[i] = model.FizzBuzzBatchResult{Response: res, Err: err}
+				if err != nil {
+					failed.Add(1)
+				} else if cached {
+					cacheHits.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	logging.AddAccessAttrs(ctx, slog.Int("batch_size", len(requests)), slog.Int("batch_work", work),
+		slog.Int64("batch_failed", failed.Load()), slog.Int64("cache_hits", cacheHits.Load()))
+	return results, nil
+}
@@ -0,0 +1,135 @@
+package fizzbuzz
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/logging"
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/repository"
+	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+	"go.uber.org/mock/gomock"
+)
+
+func TestService_GenerateFizzBuzzBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	fizzBuzz := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	short := model.FizzBuzzRequest{Int1: 2, Int2: 3, Limit: 6, Str1: "Foo", Str2: "Bar"}
+	failing := model.FizzBuzzRequest{Int1: 2, Int2: 7, Limit: 7, Str1: "Foo", Str2: "Baz"}
+	statsErr := errors.New("stats down")
+
+	stats := adapters.NewMockStatsRepository(ctrl)
+	stats.EXPECT().IncrementRequestCount(gomock.Any(), fizzBuzz).Return(nil).Times(2)
+	stats.EXPECT().IncrementRequestCount(gomock.Any(), short).Return(nil).Times(1)
+	stats.EXPECT().IncrementRequestCount(gomock.Any(), failing).Return(statsErr).Times(1)
+	fb := NewFizzBuzzService(stats, WithCache(repository.NewCacheMemory(0)), WithBatchWorkers(2))
+
+	ctx := logging.ContextWithAccessLog(context.Background())
+	results, err := fb.GenerateFizzBuzzBatch(ctx, []model.FizzBuzzRequest{fizzBuzz, short, failing, fizzBuzz})
+	if err != nil {
+		t.Fatalf("GenerateFizzBuzzBatch() error = %v", err)
+	}
+
+	const sequence = "1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz"
+	want := []string{sequence, "1,Foo,Bar,Foo,5,FooBar", "", sequence}
+	if len(results) != len(want) {
+		t.Fatalf("GenerateFizzBuzzBatch() returned %d results, want %d", len(results), len(want))
+	}
+	for i, result := range results {
+		if result.Response != want[i] {
+			t.Errorf("result %d = %q, want %q", i, result.Response, want[i])
+		}
+		if wantErr := i == 2; (result.Err != nil) != wantErr || (wantErr && !errors.Is(result.Err, statsErr)) {
+			t.Errorf("result %d error = %v, want the error %v", i, result.Err, wantErr)
+		}
+	}
+
+	attrs := logging.AccessAttrs(ctx)
+	wantAttrs := []slog.Attr{slog.Int("batch_size", 4), slog.Int("batch_work", 43), slog.Int64("batch_failed", 1)}
+	if len(attrs) != 4 || !reflect.DeepEqual(attrs[:3], wantAttrs) {
+		t.Errorf("AccessAttrs() = %v, want %v and the cache hits", attrs, wantAttrs)
+	}
+}
+
+func TestService_GenerateFizzBuzzBatch_Budget(t *testing.T) {
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 100, Str1: "Fizz", Str2: "Buzz"}
+	tests := []struct {
+		name     string
+		requests []model.FizzBuzzRequest
+		wantErr  string
+	}{
+		{
+			name:     "too many requests",
+			requests: []model.FizzBuzzRequest{request, request, request, request},
+			wantErr:  "The batch has 4 requests, more than the 3 allowed",
+		},
+		{
+			name:     "limits over the work budget",
+			requests: []model.FizzBuzzRequest{request, request, {Int1: 3, Int2: 5, Limit: 51, Str1: "Fizz", Str2: "Buzz"}},
+			wantErr:  "The limits of the batch add up to 251, more than the 250 allowed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No request of a refused batch is generated nor counted
+			fb := NewFizzBuzzService(adapters.NewMockStatsRepository(gomock.NewController(t)), WithBatchBudget(3, 250))
+			_, err := fb.GenerateFizzBuzzBatch(context.Background(), tt.requests)
+			if !errors.Is(err, model.ErrBatchTooLarge) || err.Error() != tt.wantErr {
+				t.Errorf("GenerateFizzBuzzBatch() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestService_GenerateFizzBuzzBatch_Workers(t *testing.T) {
+	const workers = 3
+	var running, maxRunning atomic.Int32
+	stats := adapters.NewMockStatsRepository(gomock.NewController(t))
+	stats.EXPECT().IncrementRequestCount(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(context.Context, model.FizzBuzzRequest) error {
+			current := running.Add(1)
+			defer running.Add(-1)
+			for {
+				seen := maxRunning.Load()
+				if current <= seen || maxRunning.CompareAndSwap(seen, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}).Times(20)
+	fb := NewFizzBuzzService(stats, WithBatchWorkers(workers))
+
+	requests := make([]model.FizzBuzzRequest, 20)
+	for i := range requests {
+		requests[i] = model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: i + 1, Str1: "Fizz", Str2: "Buzz"}
+	}
+	if _, err := fb.GenerateFizzBuzzBatch(context.Background(), requests); err != nil {
+		t.Fatalf("GenerateFizzBuzzBatch() error = %v", err)
+	}
+	if got := maxRunning.Load(); got > workers {
+		t.Errorf("%d requests generated concurrently, want at most %d", got, workers)
+	}
+}
+
+func TestService_GenerateFizzBuzzBatch_Cancelled(t *testing.T) {
+	fb := NewFizzBuzzService(adapters.NewMockStatsRepository(gomock.NewController(t)))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	results, err := fb.GenerateFizzBuzzBatch(ctx, []model.FizzBuzzRequest{request, request})
+	if err != nil {
+		t.Fatalf("GenerateFizzBuzzBatch() error = %v", err)
+	}
+	for i, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("result %d error = %v, want %v", i, result.Err, context.Canceled)
+		}
+	}
+}
@@ -1,19 +1,43 @@
 package fizzbuzz
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"iter"
+	"log/slog"
 
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/logging"
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/metrics"
 	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/repository"
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/tracing"
 	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
 	"github.com/niltonkummer/fizzbuzz-api/internal/domain/fizzbuzz"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 )
 
+// tracerName is the instrumentation scope of the spans of the service
+const tracerName = "github.com/niltonkummer/fizzbuzz-api/internal/application/services/fizzbuzz"
+
 type Option func(*Service)
 
 type Service struct {
 	fizzbuzz *fizzbuzz.FizzBuzz
 	stat     adapters.StatsRepository
 	cache    adapters.CacheFizzbuzz
+	metrics  adapters.Metrics
+	keyStats adapters.APIKeyStatsRepository
+	// inflight shares the sequence of a key between the concurrent requests missing the cache
+	inflight singleflight.Group
+	// batchWorkers is the number of requests of a batch generated concurrently
+	batchWorkers int
+	// batchMaxItems and batchMaxWork bound the requests of a batch and the sum of their limits
+	batchMaxItems int
+	batchMaxWork  int
 }
 
 // WithCache allows setting a cache for the FizzBuzz service
@@ -23,11 +47,30 @@ func WithCache(cache adapters.CacheFizzbuzz) Option {
 	}
 }
 
+// WithMetrics allows recording the requests limits and the cache lookups of the FizzBuzz service
+func WithMetrics(m adapters.Metrics) Option {
+	return func(s *Service) {
+		s.metrics = m
+	}
+}
+
+// WithAPIKeyStats allows recording the statistics of the requests of each API key
+func WithAPIKeyStats(keyStats adapters.APIKeyStatsRepository) Option {
+	return func(s *Service) {
+		s.keyStats = keyStats
+	}
+}
+
 func NewFizzBuzzService(sts adapters.StatsRepository, opts ...Option) *Service {
 	service := &Service{
 		fizzbuzz: fizzbuzz.NewFizzBuzz(),
 		stat:     sts,
 		cache:    repository.NewCacheFizzbuzzNoOp(), // Default to no-op cache
+		metrics:  metrics.NoOp{},
+
+		batchWorkers:  defaultBatchWorkers,
+		batchMaxItems: defaultBatchMaxItems,
+		batchMaxWork:  defaultBatchMaxWork,
 	}
 	for _, opt := range opts {
 		opt(service)
@@ -36,32 +79,153 @@ func NewFizzBuzzService(sts adapters.StatsRepository, opts ...Option) *Service {
 	return service
 }
 
-func (fb *Service) GenerateFizzBuzz(int1, int2, limit int, str1, str2 string) (string, error) {
+func (fb *Service) GenerateFizzBuzz(ctx context.Context, request model.FizzBuzzRequest) (string, error) {
+	res, cached, err := fb.generate(ctx, request)
+	if err != nil {
+		return "", err
+	}
+	logging.AddAccessAttrs(ctx, slog.Int("limit", request.Limit), slog.Bool("cache_hit", cached))
+	return res, nil
+}
+
+// generate returns the sequence of request from the cache or calculates it, and whether it was cached.
+// The request is counted in the statistics.
+func (fb *Service) generate(ctx context.Context, request model.FizzBuzzRequest) (res string, cached bool, err error) {
+	ctx, span := startSpan(ctx, "fizzbuzz.generate", requestAttributes(request)...)
+	defer func() { tracing.End(span, err) }()
 
-	res, err := fb.calculateFizzBuzzOrGetFromCache(int1, int2, limit, str1, str2)
+	res, cached, err = fb.calculateFizzBuzzOrGetFromCache(ctx, request)
 	if err != nil {
-		return "", fmt.Errorf("error calculating or getting from cache: %w", err)
+		return "", false, fmt.Errorf("error calculating or getting from cache: %w", err)
 	}
 
-	if err = fb.stat.IncrementRequestCount(int1, int2, limit, str1, str2); err != nil {
-		return "", fmt.Errorf("error incrementing request count: %w", err)
+	if err = fb.incrementRequestCount(ctx, request); err != nil {
+		return "", false, fmt.Errorf("error incrementing request count: %w", err)
 	}
-	return res, nil
+	fb.metrics.ObserveFizzBuzzLimit(request.Limit)
+	return res, cached, nil
+}
+
+// StreamFizzBuzz returns the FizzBuzz sequence as an iterator over its terms. The sequence is
+// generated while it is consumed, so the cache is bypassed.
+func (fb *Service) StreamFizzBuzz(ctx context.Context, request model.FizzBuzzRequest) (terms iter.Seq[string], err error) {
+	ctx, span := startSpan(ctx, "fizzbuzz.stream", requestAttributes(request)...)
+	defer func() { tracing.End(span, err) }()
+
+	terms, err = fb.fizzbuzz.Terms(request.GetRules(), request.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("error calculating fizzbuzz: %w", err)
+	}
+	logging.AddAccessAttrs(ctx, slog.Int("limit", request.Limit))
+
+	if err = fb.incrementRequestCount(ctx, request); err != nil {
+		return nil, fmt.Errorf("error incrementing request count: %w", err)
+	}
+	fb.metrics.ObserveFizzBuzzLimit(request.Limit)
+	return terms, nil
+}
+
+// FlushCache removes every cached FizzBuzz sequence
+func (fb *Service) FlushCache(ctx context.Context) error {
+	return fb.cache.Flush(ctx)
 }
 
-func (fb *Service) calculateFizzBuzzOrGetFromCache(int1, int2, limit int, str1, str2 string) (string, error) {
-	res, _ := fb.cache.Get(fmt.Sprintf("%d,%d,%d,%s,%s", int1, int2, limit, str1, str2))
+// sequence is a FizzBuzz sequence and whether it was found in the cache
+type sequence struct {
+	value  string
+	cached bool
+}
+
+// calculateFizzBuzzOrGetFromCache returns the cached sequence or calculates and caches it, and whether it was
+// cached. Concurrent calls with the same parameters wait for a single lookup and calculation.
+func (fb *Service) calculateFizzBuzzOrGetFromCache(ctx context.Context, request model.FizzBuzzRequest) (string, bool, error) {
+	key := request.Key()
+	for {
+		res, err, shared := fb.inflight.Do(key, func() (any, error) {
+			return fb.getFromCacheOrCalculate(ctx, key, request)
+		})
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("fizzbuzz.shared", shared))
+		// The call was shared with a request which went away, the calculation is run again for this one
+		if err != nil && isContextError(err) && ctx.Err() == nil {
+			continue
+		}
+		if err != nil {
+			return "", false, err
+		}
+		seq := res.(sequence)
+		return seq.value, seq.cached, nil
+	}
+}
+
+func (fb *Service) getFromCacheOrCalculate(ctx context.Context, key string, request model.FizzBuzzRequest) (sequence, error) {
+	res := fb.getFromCache(ctx, key)
 	if res != "" {
-		return res, nil
+		return sequence{value: res, cached: true}, nil
 	}
 
-	res, err := fb.fizzbuzz.Calculate(int1, int2, limit, str1, str2)
+	res, err := fb.calculate(ctx, request)
 	if err != nil {
-		return "", fmt.Errorf("error calculating fizzbuzz: %w", err)
+		return sequence{}, fmt.Errorf("error calculating fizzbuzz: %w", err)
 	}
 
-	if err = fb.cache.Set(fmt.Sprintf("%d,%d,%d,%s,%s", int1, int2, limit, str1, str2), res); err != nil {
-		return "", fmt.Errorf("error setting cache: %w", err)
+	if err = fb.setCache(ctx, key, res); err != nil {
+		return sequence{}, fmt.Errorf("error setting cache: %w", err)
 	}
-	return res, nil
+	return sequence{value: res}, nil
+}
+
+// getFromCache returns the cached sequence of key, empty on a miss. Cache errors are handled as misses.
+func (fb *Service) getFromCache(ctx context.Context, key string) string {
+	ctx, span := startSpan(ctx, "fizzbuzz.cache.get")
+	res, err := fb.cache.Get(ctx, key)
+	span.SetAttributes(attribute.Bool("fizzbuzz.cache.hit", res != ""))
+	tracing.End(span, err)
+
+	fb.metrics.ObserveCacheLookup(res != "")
+	return res
+}
+
+func (fb *Service) calculate(ctx context.Context, request model.FizzBuzzRequest) (res string, err error) {
+	ctx, span := startSpan(ctx, "fizzbuzz.calculate", attribute.Int("fizzbuzz.limit", request.Limit))
+	defer func() { tracing.End(span, err) }()
+	return fb.fizzbuzz.Calculate(ctx, request.GetRules(), request.Limit)
+}
+
+func (fb *Service) setCache(ctx context.Context, key, res string) (err error) {
+	ctx, span := startSpan(ctx, "fizzbuzz.cache.set", attribute.Int("fizzbuzz.cache.value_size", len(res)))
+	defer func() { tracing.End(span, err) }()
+	return fb.cache.Set(ctx, key, res)
+}
+
+// incrementRequestCount records a hit of the request, in the statistics of its API key as well when it has one
+func (fb *Service) incrementRequestCount(ctx context.Context, request model.FizzBuzzRequest) (err error) {
+	ctx, span := startSpan(ctx, "fizzbuzz.stats.increment")
+	defer func() { tracing.End(span, err) }()
+
+	if err = fb.stat.IncrementRequestCount(ctx, request); err != nil {
+		return err
+	}
+	if key, ok := model.APIKeyFromContext(ctx); ok && fb.keyStats != nil {
+		span.SetAttributes(attribute.String("fizzbuzz.api_key", key.Name))
+		return fb.keyStats.ForAPIKey(key.Name).IncrementRequestCount(ctx, request)
+	}
+	return nil
+}
+
+// startSpan starts a span of the service, child of the span of ctx
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// requestAttributes returns the span attributes describing a request
+func requestAttributes(request model.FizzBuzzRequest) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int("fizzbuzz.limit", request.Limit),
+		attribute.Int("fizzbuzz.rules", len(request.GetRules())),
+	}
+}
+
+// isContextError reports whether err comes from a cancelled or expired context
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
 }
@@ -1,11 +1,24 @@
 package fizzbuzz
 
 import (
+	"context"
 	"errors"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/logging"
 	"github.com/niltonkummer/fizzbuzz-api/internal/adapters/outbound/repository"
 	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/mock/gomock"
 )
 
@@ -22,6 +35,7 @@ func TestService_GenerateFizzBuzz(t *testing.T) {
 		limit int
 		str1  string
 		str2  string
+		rules []model.Rule
 	}
 	tests := []struct {
 		name    string
@@ -55,7 +69,7 @@ func TestService_GenerateFizzBuzz(t *testing.T) {
 			fields: fields{
 				stat: func() adapters.StatsRepository {
 					m := adapters.NewMockStatsRepository(ctrl)
-					m.EXPECT().IncrementRequestCount(3, 5, 15, "Fizz", "Buzz").Return(errors.New("failed")).Times(1)
+					m.EXPECT().IncrementRequestCount(gomock.Any(), model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}).Return(errors.New("failed")).Times(1)
 					return m
 				},
 				cache: func() adapters.CacheFizzbuzz {
@@ -77,7 +91,7 @@ func TestService_GenerateFizzBuzz(t *testing.T) {
 			fields: fields{
 				stat: func() adapters.StatsRepository {
 					m := adapters.NewMockStatsRepository(ctrl)
-					m.EXPECT().IncrementRequestCount(3, 5, 15, "Fizz", "Buzz").Return(nil).Times(1)
+					m.EXPECT().IncrementRequestCount(gomock.Any(), model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}).Return(nil).Times(1)
 					return m
 				},
 				cache: func() adapters.CacheFizzbuzz {
@@ -99,13 +113,13 @@ func TestService_GenerateFizzBuzz(t *testing.T) {
 			fields: fields{
 				stat: func() adapters.StatsRepository {
 					m := adapters.NewMockStatsRepository(ctrl)
-					m.EXPECT().IncrementRequestCount(3, 5, 15, "Fizz", "Buzz").Return(nil).Times(1)
+					m.EXPECT().IncrementRequestCount(gomock.Any(), model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}).Return(nil).Times(1)
 					return m
 				},
 				cache: func() adapters.CacheFizzbuzz {
 					m := adapters.NewMockCacheFizzbuzz(ctrl)
-					m.EXPECT().Get("3,5,15,Fizz,Buzz").Return("", errors.New("cache error")).Times(1)
-					m.EXPECT().Set("3,5,15,Fizz,Buzz", "1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz").Return(nil).Times(1)
+					m.EXPECT().Get(gomock.Any(), `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`).Return("", errors.New("cache error")).Times(1)
+					m.EXPECT().Set(gomock.Any(), `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`, "1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz").Return(nil).Times(1)
 					return m
 				},
 			},
@@ -124,12 +138,12 @@ func TestService_GenerateFizzBuzz(t *testing.T) {
 			fields: fields{
 				stat: func() adapters.StatsRepository {
 					m := adapters.NewMockStatsRepository(ctrl)
-					m.EXPECT().IncrementRequestCount(3, 5, 15, "Fizz", "Buzz").Return(nil).Times(1)
+					m.EXPECT().IncrementRequestCount(gomock.Any(), model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}).Return(nil).Times(1)
 					return m
 				},
 				cache: func() adapters.CacheFizzbuzz {
 					m := adapters.NewMockCacheFizzbuzz(ctrl)
-					m.EXPECT().Get("3,5,15,Fizz,Buzz").Return("1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz", nil).Times(1)
+					m.EXPECT().Get(gomock.Any(), `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`).Return("1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz", nil).Times(1)
 					return m
 				},
 			},
@@ -143,6 +157,31 @@ func TestService_GenerateFizzBuzz(t *testing.T) {
 			want:    "1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz",
 			wantErr: false,
 		},
+		{
+			name: "rule list request",
+			fields: fields{
+				stat: func() adapters.StatsRepository {
+					m := adapters.NewMockStatsRepository(ctrl)
+					m.EXPECT().IncrementRequestCount(gomock.Any(), model.FizzBuzzRequest{
+						Limit: 15,
+						Rules: []model.Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 5, Word: "Buzz"}, {Divisor: 7, Word: "Bazz"}},
+					}).Return(nil).Times(1)
+					return m
+				},
+				cache: func() adapters.CacheFizzbuzz {
+					m := adapters.NewMockCacheFizzbuzz(ctrl)
+					m.EXPECT().Get(gomock.Any(), `{"int1":0,"int2":0,"limit":15,"str1":"","str2":"","rules":[{"divisor":3,"word":"Fizz"},{"divisor":5,"word":"Buzz"},{"divisor":7,"word":"Bazz"}]}`).Return("", nil).Times(1)
+					m.EXPECT().Set(gomock.Any(), `{"int1":0,"int2":0,"limit":15,"str1":"","str2":"","rules":[{"divisor":3,"word":"Fizz"},{"divisor":5,"word":"Buzz"},{"divisor":7,"word":"Bazz"}]}`, "1,2,Fizz,4,Buzz,Fizz,Bazz,8,Fizz,Buzz,11,Fizz,13,Bazz,FizzBuzz").Return(nil).Times(1)
+					return m
+				},
+			},
+			args: args{
+				limit: 15,
+				rules: []model.Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 5, Word: "Buzz"}, {Divisor: 7, Word: "Bazz"}},
+			},
+			want:    "1,2,Fizz,4,Buzz,Fizz,Bazz,8,Fizz,Buzz,11,Fizz,13,Bazz,FizzBuzz",
+			wantErr: false,
+		},
 		{
 			name: "cache error on set",
 			fields: fields{
@@ -152,8 +191,8 @@ func TestService_GenerateFizzBuzz(t *testing.T) {
 				},
 				cache: func() adapters.CacheFizzbuzz {
 					m := adapters.NewMockCacheFizzbuzz(ctrl)
-					m.EXPECT().Get("3,5,15,Fizz,Buzz").Return("", nil).Times(1)
-					m.EXPECT().Set("3,5,15,Fizz,Buzz", "1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz").Return(errors.New("cache error")).Times(1)
+					m.EXPECT().Get(gomock.Any(), `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`).Return("", nil).Times(1)
+					m.EXPECT().Set(gomock.Any(), `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`, "1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz").Return(errors.New("cache error")).Times(1)
 					return m
 				},
 			},
@@ -175,7 +214,14 @@ func TestService_GenerateFizzBuzz(t *testing.T) {
 				tt.fields.stat(),
 				WithCache(tt.fields.cache()),
 			)
-			got, err := fb.GenerateFizzBuzz(tt.args.int1, tt.args.int2, tt.args.limit, tt.args.str1, tt.args.str2)
+			got, err := fb.GenerateFizzBuzz(context.Background(), model.FizzBuzzRequest{
+				Int1:  tt.args.int1,
+				Int2:  tt.args.int2,
+				Limit: tt.args.limit,
+				Str1:  tt.args.str1,
+				Str2:  tt.args.str2,
+				Rules: tt.args.rules,
+			})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GenerateFizzBuzz() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -186,3 +232,337 @@ func TestService_GenerateFizzBuzz(t *testing.T) {
 		})
 	}
 }
+
+func TestService_StreamFizzBuzz(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	tests := []struct {
+		name    string
+		stat    func() adapters.StatsRepository
+		request model.FizzBuzzRequest
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "invalid parameters",
+			stat: func() adapters.StatsRepository {
+				return adapters.NewMockStatsRepository(ctrl)
+			},
+			request: model.FizzBuzzRequest{Int1: 0, Int2: 5, Limit: 15},
+			wantErr: true,
+		},
+		{
+			name: "error incrementing request count",
+			stat: func() adapters.StatsRepository {
+				m := adapters.NewMockStatsRepository(ctrl)
+				m.EXPECT().IncrementRequestCount(gomock.Any(), request).Return(errors.New("failed")).Times(1)
+				return m
+			},
+			request: request,
+			wantErr: true,
+		},
+		{
+			name: "valid case bypasses the cache",
+			stat: func() adapters.StatsRepository {
+				m := adapters.NewMockStatsRepository(ctrl)
+				m.EXPECT().IncrementRequestCount(gomock.Any(), request).Return(nil).Times(1)
+				return m
+			},
+			request: request,
+			want:    []string{"1", "2", "Fizz", "4", "Buzz", "Fizz", "7", "8", "Fizz", "Buzz", "11", "Fizz", "13", "14", "FizzBuzz"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fb := NewFizzBuzzService(tt.stat(), WithCache(adapters.NewMockCacheFizzbuzz(ctrl)))
+			terms, err := fb.StreamFizzBuzz(context.Background(), tt.request)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("StreamFizzBuzz() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			got := slices.Collect(terms)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StreamFizzBuzz() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// blockingCache is a cache whose lookups wait until release is closed
+type blockingCache struct {
+	release chan struct{}
+	values  sync.Map
+	gets    atomic.Int32
+	sets    atomic.Int32
+}
+
+func (c *blockingCache) Get(_ context.Context, key string) (string, error) {
+	c.gets.Add(1)
+	<-c.release
+	value, _ := c.values.Load(key)
+	s, _ := value.(string)
+	return s, nil
+}
+
+func (c *blockingCache) Set(_ context.Context, key string, value string) error {
+	c.sets.Add(1)
+	c.values.Store(key, value)
+	return nil
+}
+
+func (c *blockingCache) Flush(context.Context) error {
+	c.values.Clear()
+	return nil
+}
+
+func TestService_GenerateFizzBuzz_Concurrent(t *testing.T) {
+	const requests = 50
+	stats := repository.NewInMemoryStatsRepository()
+	cache := &blockingCache{release: make(chan struct{})}
+	fb := NewFizzBuzzService(stats, WithCache(cache))
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := fb.GenerateFizzBuzz(context.Background(), request)
+			if err != nil {
+				t.Errorf("GenerateFizzBuzz() error = %v", err)
+				return
+			}
+			if got != "1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,FizzBuzz" {
+				t.Errorf("GenerateFizzBuzz() got = %v", got)
+			}
+		}()
+	}
+	// Let the requests pile up behind the first cache lookup
+	time.Sleep(50 * time.Millisecond)
+	close(cache.release)
+	wg.Wait()
+
+	if sets := cache.sets.Load(); sets != 1 {
+		t.Errorf("Expected a single cache Set, got %d", sets)
+	}
+	if gets := cache.gets.Load(); gets >= requests {
+		t.Errorf("Expected the cache lookups to be shared, got %d", gets)
+	}
+	got, _ := stats.GetMostFrequentRequest(context.Background())
+	if got == nil || got.Hits != requests {
+		t.Errorf("Expected every request to be counted, got %v", got)
+	}
+}
+
+func TestService_GenerateFizzBuzz_ConcurrentDistinctRequests(t *testing.T) {
+	// The words hold the separators, the two requests would share a key joining their fields with commas
+	tests := []struct {
+		request model.FizzBuzzRequest
+		want    string
+	}{
+		{request: model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 5, Str1: "a,b", Str2: "c"}, want: "1,2,a,b,4,c"},
+		{request: model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 5, Str1: "a", Str2: "b,c"}, want: "1,2,a,4,b,c"},
+	}
+	cache := &blockingCache{release: make(chan struct{})}
+	fb := NewFizzBuzzService(repository.NewInMemoryStatsRepository(), WithCache(cache))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for _, tt := range tests {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, err := fb.GenerateFizzBuzz(context.Background(), tt.request)
+				if err != nil {
+					t.Errorf("GenerateFizzBuzz() error = %v", err)
+					return
+				}
+				if got != tt.want {
+					t.Errorf("GenerateFizzBuzz(%+v) got = %v, want %v", tt.request, got, tt.want)
+				}
+			}()
+		}
+	}
+	// Let the requests of both kinds pile up behind their cache lookups
+	time.Sleep(50 * time.Millisecond)
+	close(cache.release)
+	wg.Wait()
+
+	if sets := cache.sets.Load(); sets != int32(len(tests)) {
+		t.Errorf("Expected a cache Set per request, got %d", sets)
+	}
+}
+
+func TestService_GenerateFizzBuzz_Cancelled(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fb := NewFizzBuzzService(adapters.NewMockStatsRepository(ctrl))
+	_, err := fb.GenerateFizzBuzz(ctx, model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 100000, Str1: "Fizz", Str2: "Buzz"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("GenerateFizzBuzz() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestService_GenerateFizzBuzz_SharedCallCancelled(t *testing.T) {
+	stats := repository.NewInMemoryStatsRepository()
+	cache := &blockingCache{release: make(chan struct{})}
+	fb := NewFizzBuzzService(stats, WithCache(cache))
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+
+	// The first request is cancelled while the second one waits for its calculation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() {
+		_, err := fb.GenerateFizzBuzz(ctx, request)
+		cancelled <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	done := make(chan error)
+	go func() {
+		_, err := fb.GenerateFizzBuzz(context.Background(), request)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	close(cache.release)
+
+	if err := <-cancelled; !errors.Is(err, context.Canceled) {
+		t.Errorf("GenerateFizzBuzz() error = %v, want %v", err, context.Canceled)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("GenerateFizzBuzz() error = %v, want the calculation to be run again", err)
+	}
+}
+
+func TestService_GenerateFizzBuzz_Metrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+
+	stats := adapters.NewMockStatsRepository(ctrl)
+	stats.EXPECT().IncrementRequestCount(gomock.Any(), request).Return(nil).Times(2)
+	metrics := adapters.NewMockMetrics(ctrl)
+	gomock.InOrder(
+		metrics.EXPECT().ObserveCacheLookup(false),
+		metrics.EXPECT().ObserveFizzBuzzLimit(15),
+		metrics.EXPECT().ObserveCacheLookup(true),
+		metrics.EXPECT().ObserveFizzBuzzLimit(15),
+	)
+
+	fb := NewFizzBuzzService(stats, WithCache(repository.NewCacheMemory(0)), WithMetrics(metrics))
+	for i := 0; i < 2; i++ {
+		if _, err := fb.GenerateFizzBuzz(context.Background(), request); err != nil {
+			t.Fatalf("GenerateFizzBuzz() error = %v", err)
+		}
+	}
+}
+
+func TestService_GenerateFizzBuzz_AccessAttrs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+
+	stats := adapters.NewMockStatsRepository(ctrl)
+	stats.EXPECT().IncrementRequestCount(gomock.Any(), request).Return(nil).Times(2)
+	fb := NewFizzBuzzService(stats, WithCache(repository.NewCacheMemory(0)))
+
+	for _, wantHit := range []bool{false, true} {
+		ctx := logging.ContextWithAccessLog(context.Background())
+		if _, err := fb.GenerateFizzBuzz(ctx, request); err != nil {
+			t.Fatalf("GenerateFizzBuzz() error = %v", err)
+		}
+		want := []slog.Attr{slog.Int("limit", 15), slog.Bool("cache_hit", wantHit)}
+		if got := logging.AccessAttrs(ctx); !reflect.DeepEqual(got, want) {
+			t.Errorf("AccessAttrs() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestService_GenerateFizzBuzz_APIKeyStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+
+	stats := adapters.NewMockStatsRepository(ctrl)
+	stats.EXPECT().IncrementRequestCount(gomock.Any(), request).Return(nil).Times(2)
+	acmeStats := adapters.NewMockStatsRepository(ctrl)
+	acmeStats.EXPECT().IncrementRequestCount(gomock.Any(), request).Return(nil).Times(1)
+	keyStats := adapters.NewMockAPIKeyStatsRepository(ctrl)
+	keyStats.EXPECT().ForAPIKey("acme").Return(acmeStats).Times(1)
+
+	fb := NewFizzBuzzService(stats, WithAPIKeyStats(keyStats))
+	ctx := model.ContextWithAPIKey(context.Background(), model.APIKey{Name: "acme"})
+	for _, ctx := range []context.Context{ctx, context.Background()} {
+		if _, err := fb.GenerateFizzBuzz(ctx, request); err != nil {
+			t.Fatalf("GenerateFizzBuzz() error = %v", err)
+		}
+	}
+}
+
+func TestService_GenerateFizzBuzz_Spans(t *testing.T) {
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	statsErr := errors.New("stats down")
+	tests := []struct {
+		name      string
+		cached    bool
+		statsErr  error
+		wantSpans []string
+	}{
+		{
+			name:      "Cache miss",
+			wantSpans: []string{"fizzbuzz.cache.get", "fizzbuzz.calculate", "fizzbuzz.cache.set", "fizzbuzz.stats.increment", "fizzbuzz.generate"},
+		},
+		{
+			name:      "Cache hit",
+			cached:    true,
+			wantSpans: []string{"fizzbuzz.cache.get", "fizzbuzz.stats.increment", "fizzbuzz.generate"},
+		},
+		{
+			name:      "Stats failure",
+			cached:    true,
+			statsErr:  statsErr,
+			wantSpans: []string{"fizzbuzz.cache.get", "fizzbuzz.stats.increment", "fizzbuzz.generate"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			stats := adapters.NewMockStatsRepository(ctrl)
+			stats.EXPECT().IncrementRequestCount(gomock.Any(), request).Return(tt.statsErr)
+			cache := repository.NewCacheMemory(0)
+			if tt.cached {
+				_ = cache.Set(context.Background(), request.Key(), "1,2,Fizz")
+			}
+			recorder := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+			fb := NewFizzBuzzService(stats, WithCache(cache))
+			_, err := fb.GenerateFizzBuzz(context.Background(), request)
+			if !errors.Is(err, tt.statsErr) {
+				t.Fatalf("GenerateFizzBuzz() error = %v, want %v", err, tt.statsErr)
+			}
+
+			spans := recorder.Ended()
+			var names []string
+			for _, span := range spans {
+				names = append(names, span.Name())
+			}
+			if !reflect.DeepEqual(names, tt.wantSpans) {
+				t.Fatalf("spans got = %v, want %v", names, tt.wantSpans)
+			}
+			root := spans[len(spans)-1]
+			for _, span := range spans[:len(spans)-1] {
+				if span.Parent().SpanID() != root.SpanContext().SpanID() {
+					t.Errorf("parent of %s got = %v, want the generate span", span.Name(), span.Parent().SpanID())
+				}
+			}
+			if gotErr := root.Status().Code == codes.Error; gotErr != (tt.statsErr != nil) {
+				t.Errorf("status of the generate span got = %+v, want error %v", root.Status(), tt.statsErr != nil)
+			}
+		})
+	}
+}
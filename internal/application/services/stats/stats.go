@@ -1,24 +1,56 @@
 package stats
 
 import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
 	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
 	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
 )
 
 type StatsService struct {
 	repository adapters.StatsRepository
+	keyStats   adapters.APIKeyStatsRepository
+}
+
+type Option func(*StatsService)
+
+// WithAPIKeyStats allows reading the statistics of each API key
+func WithAPIKeyStats(keyStats adapters.APIKeyStatsRepository) Option {
+	return func(s *StatsService) {
+		s.keyStats = keyStats
+	}
 }
 
 // NewStats creates a new Stats instance
-func NewStats(repo adapters.StatsRepository) *StatsService {
-	return &StatsService{
+func NewStats(repo adapters.StatsRepository, opts ...Option) *StatsService {
+	service := &StatsService{
 		repository: repo,
 	}
+	for _, opt := range opts {
+		opt(service)
+	}
+	return service
 }
 
 // GetStats returns the statistics of the application
-func (s *StatsService) GetStats() (stats *model.StatsResult, err error) {
-	stats, err = s.repository.GetMostFrequentRequest()
+func (s *StatsService) GetStats(ctx context.Context) (stats *model.StatsResult, err error) {
+	stats, err = s.repository.GetMostFrequentRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if stats == nil {
+		return nil, model.ErrNoRequestsFound
+	}
+	return stats, nil
+}
+
+// GetStatsBetween returns the statistics of the application between from and to
+func (s *StatsService) GetStatsBetween(ctx context.Context, from, to time.Time) (stats *model.StatsResult, err error) {
+	stats, err = s.repository.GetMostFrequentRequestBetween(ctx, from, to)
 	if err != nil {
 		return nil, err
 	}
@@ -29,7 +61,66 @@ func (s *StatsService) GetStats() (stats *model.StatsResult, err error) {
 	return stats, nil
 }
 
-// ResetStats resets the statistics data
-func (s *StatsService) ResetStats() error {
-	return s.repository.ResetStats()
+// GetKeyStats returns the statistics of the requests authenticated with the API key named key
+func (s *StatsService) GetKeyStats(ctx context.Context, key string) (*model.StatsResult, error) {
+	if s.keyStats == nil {
+		return nil, model.ErrNoRequestsFound
+	}
+	return NewStats(s.keyStats.ForAPIKey(key)).GetStats(ctx)
+}
+
+// GetKeyStatsBetween returns the statistics of the requests authenticated with the API key named key
+// between from and to
+func (s *StatsService) GetKeyStatsBetween(ctx context.Context, key string, from, to time.Time) (*model.StatsResult, error) {
+	if s.keyStats == nil {
+		return nil, model.ErrNoRequestsFound
+	}
+	return NewStats(s.keyStats.ForAPIKey(key)).GetStatsBetween(ctx, from, to)
+}
+
+// GetTopRequests returns the n most frequent requests
+func (s *StatsService) GetTopRequests(ctx context.Context, n int) (*model.StatsPage, error) {
+	return s.repository.GetRequestsByHits(ctx, 0, n)
+}
+
+// GetRequests returns a page of the requests ordered by hit count, pages starting at 1. The pages whose
+// offset does not fit an int are past the last request, they are read from the largest offset.
+func (s *StatsService) GetRequests(ctx context.Context, page, pageSize int) (*model.StatsPage, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, fmt.Errorf("page and page size must be greater than zero")
+	}
+	offset := math.MaxInt - pageSize
+	if page-1 <= offset/pageSize {
+		offset = (page - 1) * pageSize
+	}
+	return s.repository.GetRequestsByHits(ctx, offset, pageSize)
+}
+
+// RemoveRequest removes the statistics of a specific request parameters, from the statistics of each API key too
+func (s *StatsService) RemoveRequest(ctx context.Context, request model.FizzBuzzRequest) error {
+	removed, err := s.repository.RemoveRequest(ctx, request)
+	if err != nil {
+		return err
+	}
+	if s.keyStats != nil {
+		if err := s.keyStats.RemoveRequest(ctx, request); err != nil {
+			return err
+		}
+	}
+
+	if !removed {
+		return model.ErrRequestNotFound
+	}
+	return nil
+}
+
+// ResetStats resets the statistics data, the statistics of each API key included
+func (s *StatsService) ResetStats(ctx context.Context) error {
+	if err := s.repository.ResetStats(ctx); err != nil {
+		return err
+	}
+	if s.keyStats != nil {
+		return s.keyStats.ResetStats(ctx)
+	}
+	return nil
 }
@@ -1,8 +1,11 @@
 package stats
 
 import (
+	"context"
 	"errors"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/niltonkummer/fizzbuzz-api/internal/application/adapters"
 	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
@@ -32,7 +35,7 @@ func TestStatsService_GetStats(t *testing.T) {
 			fields: fields{
 				repository: func() adapters.StatsRepository {
 					m := adapters.NewMockStatsRepository(ctrl)
-					m.EXPECT().GetMostFrequentRequest().Return(
+					m.EXPECT().GetMostFrequentRequest(gomock.Any()).Return(
 						&model.StatsResult{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 10}, nil).
 						Times(1)
 					return m
@@ -51,7 +54,7 @@ func TestStatsService_GetStats(t *testing.T) {
 			fields: fields{
 				repository: func() adapters.StatsRepository {
 					m := adapters.NewMockStatsRepository(ctrl)
-					m.EXPECT().GetMostFrequentRequest().Return(nil, errors.New("failed")).Times(1)
+					m.EXPECT().GetMostFrequentRequest(gomock.Any()).Return(nil, errors.New("failed")).Times(1)
 					return m
 				},
 			},
@@ -68,7 +71,7 @@ func TestStatsService_GetStats(t *testing.T) {
 			fields: fields{
 				repository: func() adapters.StatsRepository {
 					m := adapters.NewMockStatsRepository(ctrl)
-					m.EXPECT().GetMostFrequentRequest().Return(nil, nil).Times(1)
+					m.EXPECT().GetMostFrequentRequest(gomock.Any()).Return(nil, nil).Times(1)
 					return m
 				},
 			},
@@ -87,7 +90,7 @@ func TestStatsService_GetStats(t *testing.T) {
 				tt.fields.repository(),
 			)
 
-			sts, err := s.GetStats()
+			sts, err := s.GetStats(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetStats() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -121,6 +124,7 @@ func TestStatsService_ResetStats(t *testing.T) {
 	defer ctrl.Finish()
 	type fields struct {
 		repository func() adapters.StatsRepository
+		keyStats   func() adapters.APIKeyStatsRepository
 	}
 	tests := []struct {
 		name    string
@@ -132,20 +136,362 @@ func TestStatsService_ResetStats(t *testing.T) {
 			fields: fields{
 				repository: func() adapters.StatsRepository {
 					m := adapters.NewMockStatsRepository(ctrl)
-					m.EXPECT().ResetStats().Return(nil).Times(1)
+					m.EXPECT().ResetStats(gomock.Any()).Return(nil).Times(1)
 					return m
 				},
+				keyStats: func() adapters.APIKeyStatsRepository { return nil },
 			},
 		},
+		{
+			name: "statistics of the keys reset",
+			fields: fields{
+				repository: func() adapters.StatsRepository {
+					m := adapters.NewMockStatsRepository(ctrl)
+					m.EXPECT().ResetStats(gomock.Any()).Return(nil).Times(1)
+					return m
+				},
+				keyStats: func() adapters.APIKeyStatsRepository {
+					m := adapters.NewMockAPIKeyStatsRepository(ctrl)
+					m.EXPECT().ResetStats(gomock.Any()).Return(nil).Times(1)
+					return m
+				},
+			},
+		},
+		{
+			name: "statistics of the keys failing",
+			fields: fields{
+				repository: func() adapters.StatsRepository {
+					m := adapters.NewMockStatsRepository(ctrl)
+					m.EXPECT().ResetStats(gomock.Any()).Return(nil).Times(1)
+					return m
+				},
+				keyStats: func() adapters.APIKeyStatsRepository {
+					m := adapters.NewMockAPIKeyStatsRepository(ctrl)
+					m.EXPECT().ResetStats(gomock.Any()).Return(errors.New("redis down")).Times(1)
+					return m
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "global statistics failing",
+			fields: fields{
+				repository: func() adapters.StatsRepository {
+					m := adapters.NewMockStatsRepository(ctrl)
+					m.EXPECT().ResetStats(gomock.Any()).Return(errors.New("redis down")).Times(1)
+					return m
+				},
+				keyStats: func() adapters.APIKeyStatsRepository {
+					return adapters.NewMockAPIKeyStatsRepository(ctrl)
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := &StatsService{
-				repository: tt.fields.repository(),
+			var opts []Option
+			if keyStats := tt.fields.keyStats(); keyStats != nil {
+				opts = append(opts, WithAPIKeyStats(keyStats))
 			}
-			if err := s.ResetStats(); (err != nil) != tt.wantErr {
+			s := NewStats(tt.fields.repository(), opts...)
+			if err := s.ResetStats(context.Background()); (err != nil) != tt.wantErr {
 				t.Errorf("ResetStats() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestStatsService_GetRequests(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	page := &model.StatsPage{
+		Results: []model.StatsResult{{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 10}},
+		Total:   21,
+	}
+	pastLast := &model.StatsPage{Results: []model.StatsResult{}, Total: 21}
+	tests := []struct {
+		name       string
+		repository func() adapters.StatsRepository
+		page       int
+		pageSize   int
+		want       *model.StatsPage
+		wantErr    bool
+	}{
+		{
+			name: "first page",
+			repository: func() adapters.StatsRepository {
+				m := adapters.NewMockStatsRepository(ctrl)
+				m.EXPECT().GetRequestsByHits(gomock.Any(), 0, 20).Return(page, nil).Times(1)
+				return m
+			},
+			page:     1,
+			pageSize: 20,
+			want:     page,
+		},
+		{
+			name: "second page",
+			repository: func() adapters.StatsRepository {
+				m := adapters.NewMockStatsRepository(ctrl)
+				m.EXPECT().GetRequestsByHits(gomock.Any(), 20, 20).Return(page, nil).Times(1)
+				return m
+			},
+			page:     2,
+			pageSize: 20,
+			want:     page,
+		},
+		{
+			name: "page past the largest offset",
+			repository: func() adapters.StatsRepository {
+				m := adapters.NewMockStatsRepository(ctrl)
+				m.EXPECT().GetRequestsByHits(gomock.Any(), math.MaxInt-20, 20).Return(pastLast, nil).Times(1)
+				return m
+			},
+			page:     math.MaxInt/10 + 1,
+			pageSize: 20,
+			want:     pastLast,
+		},
+		{
+			name: "invalid page",
+			repository: func() adapters.StatsRepository {
+				return adapters.NewMockStatsRepository(ctrl)
+			},
+			page:     0,
+			pageSize: 20,
+			wantErr:  true,
+		},
+		{
+			name: "repository error",
+			repository: func() adapters.StatsRepository {
+				m := adapters.NewMockStatsRepository(ctrl)
+				m.EXPECT().GetRequestsByHits(gomock.Any(), 0, 20).Return(nil, errors.New("failed")).Times(1)
+				return m
+			},
+			page:     1,
+			pageSize: 20,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStats(tt.repository())
+			got, err := s.GetRequests(context.Background(), tt.page, tt.pageSize)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetRequests() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("GetRequests() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsService_GetTopRequests(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	page := &model.StatsPage{
+		Results: []model.StatsResult{{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 10}},
+		Total:   1,
+	}
+	m := adapters.NewMockStatsRepository(ctrl)
+	m.EXPECT().GetRequestsByHits(gomock.Any(), 0, 10).Return(page, nil).Times(1)
+
+	got, err := NewStats(m).GetTopRequests(context.Background(), 10)
+	if err != nil {
+		t.Errorf("GetTopRequests() error = %v", err)
+	}
+	if got != page {
+		t.Errorf("GetTopRequests() got = %v, want %v", got, page)
+	}
+}
+
+func TestStatsService_RemoveRequest(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	request := model.FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"}
+	errRepository := errors.New("repository unavailable")
+	errKeyStats := errors.New("key stats unavailable")
+	tests := []struct {
+		name       string
+		repository func() adapters.StatsRepository
+		keyStats   func() adapters.APIKeyStatsRepository
+		wantErr    error
+	}{
+		{
+			name: "request removed",
+			repository: func() adapters.StatsRepository {
+				m := adapters.NewMockStatsRepository(ctrl)
+				m.EXPECT().RemoveRequest(gomock.Any(), request).Return(true, nil).Times(1)
+				return m
+			},
+			keyStats: func() adapters.APIKeyStatsRepository { return nil },
+		},
+		{
+			name: "request not found",
+			repository: func() adapters.StatsRepository {
+				m := adapters.NewMockStatsRepository(ctrl)
+				m.EXPECT().RemoveRequest(gomock.Any(), request).Return(false, nil).Times(1)
+				return m
+			},
+			keyStats: func() adapters.APIKeyStatsRepository { return nil },
+			wantErr:  model.ErrRequestNotFound,
+		},
+		{
+			name: "request removed from the API keys",
+			repository: func() adapters.StatsRepository {
+				m := adapters.NewMockStatsRepository(ctrl)
+				m.EXPECT().RemoveRequest(gomock.Any(), request).Return(true, nil).Times(1)
+				return m
+			},
+			keyStats: func() adapters.APIKeyStatsRepository {
+				m := adapters.NewMockAPIKeyStatsRepository(ctrl)
+				m.EXPECT().RemoveRequest(gomock.Any(), request).Return(nil).Times(1)
+				return m
+			},
+		},
+		{
+			name: "API keys removal error",
+			repository: func() adapters.StatsRepository {
+				m := adapters.NewMockStatsRepository(ctrl)
+				m.EXPECT().RemoveRequest(gomock.Any(), request).Return(true, nil).Times(1)
+				return m
+			},
+			keyStats: func() adapters.APIKeyStatsRepository {
+				m := adapters.NewMockAPIKeyStatsRepository(ctrl)
+				m.EXPECT().RemoveRequest(gomock.Any(), request).Return(errKeyStats).Times(1)
+				return m
+			},
+			wantErr: errKeyStats,
+		},
+		{
+			name: "global removal error skips the API keys",
+			repository: func() adapters.StatsRepository {
+				m := adapters.NewMockStatsRepository(ctrl)
+				m.EXPECT().RemoveRequest(gomock.Any(), request).Return(false, errRepository).Times(1)
+				return m
+			},
+			keyStats: func() adapters.APIKeyStatsRepository {
+				return adapters.NewMockAPIKeyStatsRepository(ctrl)
+			},
+			wantErr: errRepository,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if keyStats := tt.keyStats(); keyStats != nil {
+				opts = append(opts, WithAPIKeyStats(keyStats))
+			}
+			s := NewStats(tt.repository(), opts...)
+			if err := s.RemoveRequest(context.Background(), request); !errors.Is(err, tt.wantErr) {
+				t.Errorf("RemoveRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStatsService_GetStatsBetween(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	from := time.Date(2024, 5, 9, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	stats := &model.StatsResult{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 10}
+	tests := []struct {
+		name       string
+		repository func() adapters.StatsRepository
+		want       *model.StatsResult
+		wantErr    error
+	}{
+		{
+			name: "requests in range",
+			repository: func() adapters.StatsRepository {
+				m := adapters.NewMockStatsRepository(ctrl)
+				m.EXPECT().GetMostFrequentRequestBetween(gomock.Any(), from, to).Return(stats, nil).Times(1)
+				return m
+			},
+			want: stats,
+		},
+		{
+			name: "no requests in range",
+			repository: func() adapters.StatsRepository {
+				m := adapters.NewMockStatsRepository(ctrl)
+				m.EXPECT().GetMostFrequentRequestBetween(gomock.Any(), from, to).Return(nil, nil).Times(1)
+				return m
+			},
+			wantErr: model.ErrNoRequestsFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewStats(tt.repository()).GetStatsBetween(context.Background(), from, to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetStatsBetween() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetStatsBetween() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsService_GetKeyStats(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stats := &model.StatsResult{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz", Hits: 2}
+	tests := []struct {
+		name     string
+		keyStats func() adapters.APIKeyStatsRepository
+		want     *model.StatsResult
+		wantErr  error
+	}{
+		{
+			name: "requests of the key",
+			keyStats: func() adapters.APIKeyStatsRepository {
+				repo := adapters.NewMockStatsRepository(ctrl)
+				repo.EXPECT().GetMostFrequentRequest(gomock.Any()).Return(stats, nil).Times(1)
+				m := adapters.NewMockAPIKeyStatsRepository(ctrl)
+				m.EXPECT().ForAPIKey("acme").Return(repo).Times(1)
+				return m
+			},
+			want: stats,
+		},
+		{
+			name: "no requests of the key",
+			keyStats: func() adapters.APIKeyStatsRepository {
+				repo := adapters.NewMockStatsRepository(ctrl)
+				repo.EXPECT().GetMostFrequentRequest(gomock.Any()).Return(nil, nil).Times(1)
+				m := adapters.NewMockAPIKeyStatsRepository(ctrl)
+				m.EXPECT().ForAPIKey("acme").Return(repo).Times(1)
+				return m
+			},
+			wantErr: model.ErrNoRequestsFound,
+		},
+		{
+			name:     "statistics per key disabled",
+			keyStats: func() adapters.APIKeyStatsRepository { return nil },
+			wantErr:  model.ErrNoRequestsFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if keyStats := tt.keyStats(); keyStats != nil {
+				opts = append(opts, WithAPIKeyStats(keyStats))
+			}
+			s := NewStats(adapters.NewMockStatsRepository(ctrl), opts...)
+			got, err := s.GetKeyStats(context.Background(), "acme")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetKeyStats() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetKeyStats() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,18 @@
 package fizzbuzz
 
 import (
+	"context"
 	"fmt"
+	"iter"
+	"strconv"
 	"strings"
+
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
 )
 
+// cancellationCheckTerms is the number of terms generated between two checks of the context
+const cancellationCheckTerms = 1024
+
 type FizzBuzz struct {
 }
 
@@ -13,27 +21,70 @@ func NewFizzBuzz() *FizzBuzz {
 	return &FizzBuzz{}
 }
 
-func (fb *FizzBuzz) Calculate(int1, int2, limit int, str1, str2 string) (string, error) {
-	if int1 <= 0 || int2 <= 0 || limit <= 0 {
-		return "", fmt.Errorf("int1, int2, and limit must be greater than zero")
-	}
-	product := int1 * int2
-	if int1 == int2 {
-		product = int1
+// Calculate returns the comma separated sequence from 1 to limit. Every number is
+// replaced by the concatenation, in rule order, of the words of the rules dividing it.
+// It stops with the context error when ctx is cancelled.
+func (fb *FizzBuzz) Calculate(ctx context.Context, rules []model.Rule, limit int) (string, error) {
+	terms, err := fb.Terms(rules, limit)
+	if err != nil {
+		return "", err
 	}
 
 	str := strings.Builder{}
-	for i := 1; i <= limit; i++ {
-		if i%product == 0 {
-			str.WriteString(str1 + str2)
-		} else if i%int1 == 0 {
-			str.WriteString(str1)
-		} else if i%int2 == 0 {
-			str.WriteString(str2)
-		} else {
-			str.WriteString(fmt.Sprintf("%d", i))
+	i := 0
+	for t := range terms {
+		if i%cancellationCheckTerms == 0 {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+		}
+		if i > 0 {
+			str.WriteString(",")
+		}
+		str.WriteString(t)
+		i++
+	}
+	return str.String(), nil
+}
+
+// Terms returns an iterator over the terms of the sequence from 1 to limit. Terms are
+// generated one at a time, so memory use does not grow with limit.
+func (fb *FizzBuzz) Terms(rules []model.Rule, limit int) (iter.Seq[string], error) {
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("at least one rule is required")
+	}
+	for _, rule := range rules {
+		if rule.Divisor <= 0 {
+			return nil, fmt.Errorf("divisors and limit must be greater than zero")
 		}
-		str.WriteString(",")
 	}
-	return str.String()[:str.Len()-1], nil
+	if limit <= 0 {
+		return nil, fmt.Errorf("divisors and limit must be greater than zero")
+	}
+
+	return func(yield func(string) bool) {
+		for i := 1; i <= limit; i++ {
+			if !yield(term(rules, i)) {
+				return
+			}
+		}
+	}, nil
+}
+
+// term returns the value of the number i of the sequence
+func term(rules []model.Rule, i int) string {
+	var (
+		word    string
+		matched bool
+	)
+	for _, rule := range rules {
+		if i%rule.Divisor == 0 {
+			word += rule.Word
+			matched = true
+		}
+	}
+	if !matched {
+		return strconv.Itoa(i)
+	}
+	return word
 }
@@ -1,8 +1,10 @@
 package fizzbuzz
 
 import (
+	"context"
 	"testing"
 
+	"github.com/niltonkummer/fizzbuzz-api/internal/domain/model"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -88,7 +90,10 @@ func TestFizzBuzz_Calculate(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			fb := NewFizzBuzz()
-			got, err := fb.Calculate(tt.args.int1, tt.args.int2, tt.args.limit, tt.args.str1, tt.args.str2)
+			got, err := fb.Calculate(context.Background(), []model.Rule{
+				{Divisor: tt.args.int1, Word: tt.args.str1},
+				{Divisor: tt.args.int2, Word: tt.args.str2},
+			}, tt.args.limit)
 			tt.error(t, err)
 			if tt.skip {
 				t.Skip()
@@ -98,3 +103,92 @@ func TestFizzBuzz_Calculate(t *testing.T) {
 		})
 	}
 }
+
+func TestFizzBuzz_CalculateRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []model.Rule
+		limit int
+		want  string
+		error assert.ErrorAssertionFunc
+	}{
+		{
+			name:  "no rules",
+			rules: nil,
+			limit: 10,
+			want:  "",
+			error: assert.Error,
+		},
+		{
+			name:  "zero divisor",
+			rules: []model.Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 0, Word: "Buzz"}},
+			limit: 10,
+			want:  "",
+			error: assert.Error,
+		},
+		{
+			name:  "single rule",
+			rules: []model.Rule{{Divisor: 2, Word: "Even"}},
+			limit: 5,
+			want:  "1,Even,3,Even,5",
+			error: assert.NoError,
+		},
+		{
+			name:  "fizzbuzzbazz",
+			rules: []model.Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 5, Word: "Buzz"}, {Divisor: 7, Word: "Bazz"}},
+			limit: 21,
+			want:  "1,2,Fizz,4,Buzz,Fizz,Bazz,8,Fizz,Buzz,11,Fizz,13,Bazz,FizzBuzz,16,17,Fizz,19,Buzz,FizzBazz",
+			error: assert.NoError,
+		},
+		{
+			name:  "words follow rule order",
+			rules: []model.Rule{{Divisor: 5, Word: "Buzz"}, {Divisor: 3, Word: "Fizz"}},
+			limit: 15,
+			want:  "1,2,Fizz,4,Buzz,Fizz,7,8,Fizz,Buzz,11,Fizz,13,14,BuzzFizz",
+			error: assert.NoError,
+		},
+		{
+			name:  "divisors with common factors",
+			rules: []model.Rule{{Divisor: 2, Word: "a"}, {Divisor: 4, Word: "b"}},
+			limit: 8,
+			want:  "1,a,3,ab,5,a,7,ab",
+			error: assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fb := NewFizzBuzz()
+			got, err := fb.Calculate(context.Background(), tt.rules, tt.limit)
+			tt.error(t, err)
+			assert.Equal(t, tt.want, got, "Calculate() = %v, want %v", got, tt.want)
+		})
+	}
+}
+
+func TestFizzBuzz_Terms(t *testing.T) {
+	fb := NewFizzBuzz()
+
+	_, err := fb.Terms([]model.Rule{{Divisor: 3, Word: "Fizz"}}, 0)
+	assert.Error(t, err)
+
+	terms, err := fb.Terms([]model.Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 5, Word: "Buzz"}}, 500000)
+	assert.NoError(t, err)
+
+	var got []string
+	for term := range terms {
+		got = append(got, term)
+		if len(got) == 5 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"1", "2", "Fizz", "4", "Buzz"}, got)
+}
+
+func TestFizzBuzz_CalculateCancelled(t *testing.T) {
+	fb := NewFizzBuzz()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := fb.Calculate(ctx, []model.Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 5, Word: "Buzz"}}, 500000)
+	assert.ErrorIs(t, err, context.Canceled)
+}
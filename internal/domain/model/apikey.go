@@ -0,0 +1,64 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// Scope is a permission granted to an API key
+type Scope string
+
+const (
+	// ScopeFizzBuzzGenerate allows generating FizzBuzz sequences
+	ScopeFizzBuzzGenerate Scope = "fizzbuzz:generate"
+	// ScopeStatsRead allows reading the statistics, and the statistics of the key itself
+	ScopeStatsRead Scope = "stats:read"
+	// ScopeAdmin allows the admin routes, the statistics of every key, and implies the other scopes
+	ScopeAdmin Scope = "admin"
+)
+
+// apiKeyName matches the names of the API keys, which are part of the Redis keys of their statistics
+var apiKeyName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// Scopes lists the known scopes
+var Scopes = []Scope{ScopeFizzBuzzGenerate, ScopeStatsRead, ScopeAdmin}
+
+// APIKey is a client of the API authenticated by a key. The key itself is not held, only its name.
+type APIKey struct {
+	// Name identifies the client in the statistics and the rate limits
+	Name   string  `json:"name"`
+	Scopes []Scope `json:"scopes"`
+}
+
+// HasScope reports whether the key is granted scope, every scope being granted to the admin keys
+func (k APIKey) HasScope(scope Scope) bool {
+	return slices.Contains(k.Scopes, scope) || slices.Contains(k.Scopes, ScopeAdmin)
+}
+
+// Validate checks that the key has a valid name, of up to 64 letters, digits, '-', '_' or '.', and only known scopes
+func (k APIKey) Validate() error {
+	if !apiKeyName.MatchString(k.Name) {
+		return fmt.Errorf("invalid API key name %q", k.Name)
+	}
+	for _, scope := range k.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("API key %q has an unknown scope %q", k.Name, scope)
+		}
+	}
+	return nil
+}
+
+type apiKeyContextKey struct{}
+
+// ContextWithAPIKey returns a copy of ctx carrying the API key authenticating the request
+func ContextWithAPIKey(ctx context.Context, key APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+// APIKeyFromContext returns the API key authenticating the request of ctx, if any
+func APIKeyFromContext(ctx context.Context) (APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey{}).(APIKey)
+	return key, ok
+}
@@ -0,0 +1,44 @@
+package model
+
+import "testing"
+
+func TestAPIKey_HasScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []Scope
+		scope  Scope
+		want   bool
+	}{
+		{name: "granted scope", scopes: []Scope{ScopeStatsRead}, scope: ScopeStatsRead, want: true},
+		{name: "missing scope", scopes: []Scope{ScopeStatsRead}, scope: ScopeFizzBuzzGenerate, want: false},
+		{name: "admin implies every scope", scopes: []Scope{ScopeAdmin}, scope: ScopeFizzBuzzGenerate, want: true},
+		{name: "no scopes", scope: ScopeStatsRead, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (APIKey{Name: "acme", Scopes: tt.scopes}).HasScope(tt.scope); got != tt.want {
+				t.Errorf("HasScope() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKey_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     APIKey
+		wantErr bool
+	}{
+		{name: "valid key", key: APIKey{Name: "acme-prod_1.0", Scopes: []Scope{ScopeFizzBuzzGenerate, ScopeAdmin}}},
+		{name: "empty name", key: APIKey{}, wantErr: true},
+		{name: "name with a colon", key: APIKey{Name: "acme:prod"}, wantErr: true},
+		{name: "unknown scope", key: APIKey{Name: "acme", Scopes: []Scope{"stats:write"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.key.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package model
+
+// FizzBuzzBatchResult is the outcome of a request of a batch, its sequence or the error which prevented it
+type FizzBuzzBatchResult struct {
+	Response string
+	Err      error
+}
+
+// FizzBuzzBatchItem is the response to a request of a batch, with the status it would have been answered
+// alone. Response is set when it succeeded, Error otherwise.
+type FizzBuzzBatchItem struct {
+	// Index is the position of the request in the batch
+	Index    int      `json:"index"`
+	Status   int      `json:"status"`
+	Response string   `json:"response,omitempty"`
+	Error    *Problem `json:"error,omitempty"`
+}
+
+// FizzBuzzBatchResponse holds the responses to the requests of a batch, in the order of the requests
+type FizzBuzzBatchResponse struct {
+	Results   []FizzBuzzBatchItem `json:"results"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+}
+
+// NewFizzBuzzBatchResponse creates the response of a batch, counting its failed items
+func NewFizzBuzzBatchResponse(items []FizzBuzzBatchItem) FizzBuzzBatchResponse {
+	resp := FizzBuzzBatchResponse{Results: items}
+	for _, item := range items {
+		if item.Error != nil {
+			resp.Failed++
+		} else {
+			resp.Succeeded++
+		}
+	}
+	return resp
+}
@@ -1,17 +1,138 @@
 package model
 
+import "slices"
+
+// Error is an error of the catalog below, identified by its code. The errors of a code share its title,
+// the message details an occurrence.
 type Error struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
+	// Title is the short summary of the errors of the code
+	Title string `json:"-"`
+	// Fields lists the request parameters which failed their validation
+	Fields []FieldError `json:"-"`
 }
 
 func (err *Error) Error() string {
 	return err.Message
 }
 
+// Is reports whether target is an error of the same code, so the occurrences built from a catalog error
+// match it with errors.Is
+func (err *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == err.Code
+}
+
+// WithMessage returns an occurrence of the error detailed by message
+func (err *Error) WithMessage(message string) *Error {
+	occurrence := *err
+	occurrence.Message = message
+	return &occurrence
+}
+
+// WithFields returns an occurrence of the error caused by the failed validation of fields
+func (err *Error) WithFields(fields ...FieldError) *Error {
+	occurrence := *err
+	occurrence.Fields = slices.Clone(fields)
+	return &occurrence
+}
+
+// FieldError is the failed validation of a request parameter
+type FieldError struct {
+	// Field is the path of the parameter, such as limit or rules[1].divisor
+	Field string `json:"field"`
+	// Rule is the validation which failed, such as required, min or max
+	Rule string `json:"rule"`
+	// Param is the parameter of the rule, such as the bound of min or max
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
 var (
+	ErrInvalidPayload = &Error{
+		Code:    "invalid_payload",
+		Title:   "Invalid payload",
+		Message: "The request parameters could not be read",
+	}
+	ErrInvalidRequest = &Error{
+		Code:    "invalid_request",
+		Title:   "Invalid request",
+		Message: "The request parameters are invalid",
+	}
+	ErrInvalidFormat = &Error{
+		Code:    "invalid_format",
+		Title:   "Invalid format",
+		Message: "The response format is not supported",
+	}
 	ErrNoRequestsFound = &Error{
 		Code:    "no_requests_found",
+		Title:   "No requests found",
 		Message: "No requests found in the statistics",
 	}
+	ErrRequestNotFound = &Error{
+		Code:    "request_not_found",
+		Title:   "Request not found",
+		Message: "Request parameters not found in the statistics",
+	}
+	ErrRouteNotFound = &Error{
+		Code:    "not_found",
+		Title:   "Not found",
+		Message: "No route matches the request path",
+	}
+	ErrMethodNotAllowed = &Error{
+		Code:    "method_not_allowed",
+		Title:   "Method not allowed",
+		Message: "The route does not allow the request method",
+	}
+	ErrStatsUnavailable = &Error{
+		Code:    "stats_unavailable",
+		Title:   "Statistics unavailable",
+		Message: "Statistics are temporarily unavailable",
+	}
+	ErrCacheUnavailable = &Error{
+		Code:    "cache_unavailable",
+		Title:   "Cache unavailable",
+		Message: "Cache is temporarily unavailable",
+	}
+	ErrInvalidAPIKey = &Error{
+		Code:    "unauthorized",
+		Title:   "Unauthorized",
+		Message: "Invalid or missing API key",
+	}
+	ErrForbidden = &Error{
+		Code:    "forbidden",
+		Title:   "Forbidden",
+		Message: "The API key is not allowed to make the request",
+	}
+	ErrAuthUnavailable = &Error{
+		Code:    "auth_unavailable",
+		Title:   "Authentication unavailable",
+		Message: "API keys are temporarily unavailable",
+	}
+	ErrRateLimited = &Error{
+		Code:    "rate_limited",
+		Title:   "Rate limited",
+		Message: "Too many requests, retry later",
+	}
+	ErrPayloadTooLarge = &Error{
+		Code:    "payload_too_large",
+		Title:   "Payload too large",
+		Message: "Request body too large",
+	}
+	ErrBatchTooLarge = &Error{
+		Code:    "batch_too_large",
+		Title:   "Batch too large",
+		Message: "The batch has too many requests or too large limits",
+	}
+	ErrRequestTimeout = &Error{
+		Code:    "request_timeout",
+		Title:   "Request timeout",
+		Message: "The request took too long to be served",
+	}
+	ErrInternal = &Error{
+		Code:    "internal_error",
+		Title:   "Internal error",
+		Message: "Internal server error",
+	}
 )
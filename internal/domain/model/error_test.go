@@ -1,6 +1,10 @@
 package model
 
-import "testing"
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
 
 func TestError_Error(t *testing.T) {
 	type fields struct {
@@ -33,3 +37,37 @@ func TestError_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestError_WithMessage(t *testing.T) {
+	err := ErrForbidden.WithMessage("The API key may only read its own statistics")
+	if err.Message != "The API key may only read its own statistics" || err.Code != ErrForbidden.Code {
+		t.Errorf("WithMessage() = %+v", err)
+	}
+	if ErrForbidden.Message != "The API key is not allowed to make the request" {
+		t.Errorf("WithMessage() changed the catalog error: %+v", ErrForbidden)
+	}
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("errors.Is(%v, ErrForbidden) = false, want true", err)
+	}
+	if errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("errors.Is(%v, ErrInvalidAPIKey) = true, want false", err)
+	}
+}
+
+func TestNewProblem(t *testing.T) {
+	fields := []FieldError{{Field: "limit", Rule: "required", Message: "limit is required"}}
+	got := NewProblem(ErrInvalidRequest.WithMessage("limit is required").WithFields(fields...), 400, "/fizzbuzz")
+	want := Problem{
+		Type:     "urn:fizzbuzz-api:problem:invalid_request",
+		Title:    "Invalid request",
+		Status:   400,
+		Detail:   "limit is required",
+		Instance: "/fizzbuzz",
+		Code:     "invalid_request",
+		Message:  "limit is required",
+		Errors:   fields,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NewProblem() = %+v, want %+v", got, want)
+	}
+}
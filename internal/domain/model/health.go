@@ -0,0 +1,63 @@
+package model
+
+// HealthStatus is the health status of the service or of one of its components
+type HealthStatus string
+
+const (
+	// HealthStatusOK means the component works normally
+	HealthStatusOK HealthStatus = "ok"
+	// HealthStatusDegraded means the component is unavailable and the service works without it
+	HealthStatusDegraded HealthStatus = "degraded"
+	// HealthStatusUnavailable means the component cannot serve requests
+	HealthStatusUnavailable HealthStatus = "unavailable"
+)
+
+// ComponentHealth is the health of a component of the service
+type ComponentHealth struct {
+	Name   string       `json:"name"`
+	Status HealthStatus `json:"status"`
+	// Breaker is the state of the circuit breaker protecting the component, if any
+	Breaker string `json:"breaker,omitempty"`
+	// BufferedRequests is the number of distinct requests whose hits wait to be written
+	BufferedRequests int `json:"buffered_requests,omitempty"`
+	// DroppedHits is the number of hits lost because the buffer was full
+	DroppedHits uint64 `json:"dropped_hits,omitempty"`
+	// Error is the reason the component is unavailable
+	Error string `json:"error,omitempty"`
+}
+
+// HealthResponse is the health of the service, degraded when one of its components is
+type HealthResponse struct {
+	Status     HealthStatus      `json:"status"`
+	Components []ComponentHealth `json:"components"`
+}
+
+// NewHealthResponse creates a HealthResponse from the health of the components
+func NewHealthResponse(components []ComponentHealth) HealthResponse {
+	resp := HealthResponse{
+		Status:     HealthStatusOK,
+		Components: components,
+	}
+	if resp.Components == nil {
+		resp.Components = []ComponentHealth{}
+	}
+	for _, component := range components {
+		if component.Status != HealthStatusOK {
+			resp.Status = HealthStatusDegraded
+		}
+	}
+	return resp
+}
+
+// NewReadinessResponse creates a HealthResponse from the readiness of the dependencies,
+// unavailable when one of them is
+func NewReadinessResponse(components []ComponentHealth) HealthResponse {
+	resp := NewHealthResponse(components)
+	resp.Status = HealthStatusOK
+	for _, component := range resp.Components {
+		if component.Status == HealthStatusUnavailable {
+			resp.Status = HealthStatusUnavailable
+		}
+	}
+	return resp
+}
@@ -0,0 +1,32 @@
+package model
+
+// ProblemTypePrefix starts the type of the problems, followed by their code
+const ProblemTypePrefix = "urn:fizzbuzz-api:problem:"
+
+// Problem is an RFC 7807 problem details body. It keeps the code and the message members of the previous
+// error bodies, the message being the detail of the problem.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	// Errors lists the request parameters which failed their validation
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem creates the Problem of err, answered with status to the request of the instance path
+func NewProblem(err *Error, status int, instance string) Problem {
+	return Problem{
+		Type:     ProblemTypePrefix + err.Code,
+		Title:    err.Title,
+		Status:   status,
+		Detail:   err.Message,
+		Instance: instance,
+		Code:     err.Code,
+		Message:  err.Message,
+		Errors:   err.Fields,
+	}
+}
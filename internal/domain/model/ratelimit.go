@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// RateLimit is the state of the token bucket of a client once a request was taken from it
+type RateLimit struct {
+	// Allowed reports whether the bucket held enough tokens for the request
+	Allowed bool
+	// Limit is the capacity of the bucket, in tokens
+	Limit int
+	// Remaining is the number of tokens left in the bucket
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the bucket holds enough tokens for a refused request
+	RetryAfter time.Duration
+}
@@ -1,11 +1,52 @@
 package model
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Rule replaces every multiple of Divisor with Word
+type Rule struct {
+	Divisor int    `json:"divisor" validate:"min=1"`
+	Word    string `json:"word"`
+}
+
+// FizzBuzzRequest holds the parameters of a FizzBuzz sequence. The rule list form
+// (Rules) and the two-rule shorthand (Int1, Int2, Str1, Str2) are mutually exclusive,
+// only the shorthand may be sent as query parameters.
 type FizzBuzzRequest struct {
-	Int1  int    `json:"int1" validate:"min=1"`
-	Int2  int    `json:"int2" validate:"min=1"`
-	Limit int    `json:"limit" validate:"min=0,max=500000"`
-	Str1  string `json:"str1"`
-	Str2  string `json:"str2"`
+	Int1  int    `json:"int1" query:"int1"`
+	Int2  int    `json:"int2" query:"int2"`
+	Limit int    `json:"limit" query:"limit" validate:"min=0,max=500000"`
+	Str1  string `json:"str1" query:"str1"`
+	Str2  string `json:"str2" query:"str2"`
+	Rules []Rule `json:"rules,omitempty" validate:"omitempty,max=100,dive"`
+}
+
+// HasRules reports whether the request uses the rule list form
+func (r FizzBuzzRequest) HasRules() bool {
+	return len(r.Rules) > 0
+}
+
+// GetRules returns the rules of the request, expanding the two-rule shorthand
+func (r FizzBuzzRequest) GetRules() []Rule {
+	if r.HasRules() {
+		return r.Rules
+	}
+	return []Rule{
+		{Divisor: r.Int1, Word: r.Str1},
+		{Divisor: r.Int2, Word: r.Str2},
+	}
+}
+
+// Key returns a string identifying the request parameters, keying the cache and the statistics.
+// It is the JSON encoding of the request, which stays unambiguous whatever the words contain.
+func (r FizzBuzzRequest) Key() string {
+	// The request only holds numbers, strings and a slice of them, which always encode
+	key, _ := json.Marshal(r)
+	return string(key)
 }
 
 type FizzBuzzResponse struct {
@@ -18,5 +59,75 @@ type StatsResponse struct {
 	Limit int    `json:"limit"`
 	Str1  string `json:"str1"`
 	Str2  string `json:"str2"`
+	Rules []Rule `json:"rules,omitempty"`
 	Hits  int    `json:"hits"`
 }
+
+// StatsWindows are the durations accepted by the window parameter of StatsRequest
+var StatsWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+// StatsRequest restricts the statistics to a time range, either the last Window
+// or the range between From and To in RFC 3339 format, fractional seconds allowed. Both forms are optional.
+type StatsRequest struct {
+	Window string `json:"window" query:"window" validate:"omitempty,oneof=1h 24h 7d"`
+	From   string `json:"from" query:"from" validate:"omitempty,rfc3339"`
+	To     string `json:"to" query:"to" validate:"omitempty,rfc3339"`
+	// Key restricts the statistics to the requests authenticated with the API key of this name
+	Key string `json:"key" query:"key" validate:"omitempty,max=64"`
+}
+
+// IsWindowed reports whether the request restricts the statistics to a time range
+func (r StatsRequest) IsWindowed() bool {
+	return r.Window != "" || r.From != "" || r.To != ""
+}
+
+// Range returns the time range of the request relative to now. A missing From covers
+// all the recorded statistics and a missing To ends the range at now.
+func (r StatsRequest) Range(now time.Time) (from, to time.Time, err error) {
+	if r.Window != "" {
+		if r.From != "" || r.To != "" {
+			return from, to, errors.New("window cannot be combined with from and to")
+		}
+		window, ok := StatsWindows[r.Window]
+		if !ok {
+			return from, to, fmt.Errorf("invalid window: %q", r.Window)
+		}
+		return now.Add(-window), now, nil
+	}
+
+	to = now
+	if r.From != "" {
+		if from, err = time.Parse(time.RFC3339Nano, r.From); err != nil {
+			return from, to, fmt.Errorf("failed to parse from: %w", err)
+		}
+	}
+	if r.To != "" {
+		if to, err = time.Parse(time.RFC3339Nano, r.To); err != nil {
+			return from, to, fmt.Errorf("failed to parse to: %w", err)
+		}
+	}
+	if !from.Before(to) {
+		return from, to, errors.New("from must be before to")
+	}
+	return from, to, nil
+}
+
+type StatsTopRequest struct {
+	N int `json:"n" query:"n" validate:"min=1,max=100"`
+}
+
+type StatsPageRequest struct {
+	Page     int `json:"page" query:"page" validate:"min=1"`
+	PageSize int `json:"page_size" query:"page_size" validate:"min=1,max=100"`
+}
+
+type StatsListResponse struct {
+	Requests []StatsResponse `json:"requests"`
+	Total    int             `json:"total"`
+	Page     int             `json:"page,omitempty"`
+	PageSize int             `json:"page_size,omitempty"`
+}
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFizzBuzzRequest_GetRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		request FizzBuzzRequest
+		want    []Rule
+	}{
+		{
+			name:    "two-rule shorthand",
+			request: FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"},
+			want:    []Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 5, Word: "Buzz"}},
+		},
+		{
+			name:    "rule list",
+			request: FizzBuzzRequest{Limit: 15, Rules: []Rule{{Divisor: 7, Word: "Bazz"}}},
+			want:    []Rule{{Divisor: 7, Word: "Bazz"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.request.GetRules(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetRules() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFizzBuzzRequest_Key(t *testing.T) {
+	tests := []struct {
+		name    string
+		request FizzBuzzRequest
+		want    string
+	}{
+		{
+			name:    "two-rule shorthand",
+			request: FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"},
+			want:    `{"int1":3,"int2":5,"limit":15,"str1":"Fizz","str2":"Buzz"}`,
+		},
+		{
+			name: "rule list",
+			request: FizzBuzzRequest{
+				Limit: 21,
+				Rules: []Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 5, Word: "Buzz"}, {Divisor: 7, Word: "Bazz"}},
+			},
+			want: `{"int1":0,"int2":0,"limit":21,"str1":"","str2":"",` +
+				`"rules":[{"divisor":3,"word":"Fizz"},{"divisor":5,"word":"Buzz"},{"divisor":7,"word":"Bazz"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.request.Key(); got != tt.want {
+				t.Errorf("Key() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFizzBuzzRequest_Key_Distinct(t *testing.T) {
+	tests := []struct {
+		name  string
+		left  FizzBuzzRequest
+		right FizzBuzzRequest
+	}{
+		{
+			name:  "words containing the separators of the rules",
+			left:  FizzBuzzRequest{Limit: 15, Rules: []Rule{{Divisor: 3, Word: "a,5:b"}}},
+			right: FizzBuzzRequest{Limit: 15, Rules: []Rule{{Divisor: 3, Word: "a"}, {Divisor: 5, Word: "b"}}},
+		},
+		{
+			name:  "words containing commas",
+			left:  FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "a,b", Str2: "c"},
+			right: FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "a", Str2: "b,c"},
+		},
+		{
+			name:  "shorthand and the same rules",
+			left:  FizzBuzzRequest{Int1: 3, Int2: 5, Limit: 15, Str1: "Fizz", Str2: "Buzz"},
+			right: FizzBuzzRequest{Limit: 15, Rules: []Rule{{Divisor: 3, Word: "Fizz"}, {Divisor: 5, Word: "Buzz"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.left.Key() == tt.right.Key() {
+				t.Errorf("Key() = %v for both requests", tt.left.Key())
+			}
+		})
+	}
+}
+
+func TestStatsRequest_Range(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		request  StatsRequest
+		wantFrom time.Time
+		wantTo   time.Time
+		wantErr  bool
+	}{
+		{
+			name:     "last day window",
+			request:  StatsRequest{Window: "24h"},
+			wantFrom: now.Add(-24 * time.Hour),
+			wantTo:   now,
+		},
+		{
+			name:     "from and to",
+			request:  StatsRequest{From: "2024-05-09T10:00:00Z", To: "2024-05-09T14:00:00+02:00"},
+			wantFrom: time.Date(2024, 5, 9, 10, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2024, 5, 9, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "from and to with fractional seconds",
+			request:  StatsRequest{From: "2025-01-01T00:00:00.5Z", To: "2025-01-01T00:00:01.000000001Z"},
+			wantFrom: time.Date(2025, 1, 1, 0, 0, 0, 500000000, time.UTC),
+			wantTo:   time.Date(2025, 1, 1, 0, 0, 1, 1, time.UTC),
+		},
+		{
+			name:    "from not before to",
+			request: StatsRequest{From: "2024-05-09T12:00:00Z", To: "2024-05-09T14:00:00+02:00"},
+			wantErr: true,
+		},
+		{
+			name:     "from only ends at now",
+			request:  StatsRequest{From: "2024-05-09T10:00:00Z"},
+			wantFrom: time.Date(2024, 5, 9, 10, 0, 0, 0, time.UTC),
+			wantTo:   now,
+		},
+		{
+			name:    "window combined with from",
+			request: StatsRequest{Window: "1h", From: "2024-05-09T10:00:00Z"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown window",
+			request: StatsRequest{Window: "2h"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := tt.request.Range(now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Range() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("Range() = %v, %v, want %v, %v", from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
@@ -6,5 +6,44 @@ type StatsResult struct {
 	Limit int    `json:"limit"`
 	Str1  string `json:"str1"`
 	Str2  string `json:"str2"`
+	Rules []Rule `json:"rules,omitempty"`
 	Hits  int    `json:"hits"`
 }
+
+// StatsPage is a page of statistics entries ordered by hits
+type StatsPage struct {
+	Results []StatsResult
+	// Total is the number of distinct requests in the statistics
+	Total int
+}
+
+// NewStatsResult creates a StatsResult for the given request parameters and hit count
+func NewStatsResult(request FizzBuzzRequest, hits int) *StatsResult {
+	return &StatsResult{
+		Int1:  request.Int1,
+		Int2:  request.Int2,
+		Limit: request.Limit,
+		Str1:  request.Str1,
+		Str2:  request.Str2,
+		Rules: request.Rules,
+		Hits:  hits,
+	}
+}
+
+// Request returns the request parameters of the statistics entry
+func (s *StatsResult) Request() FizzBuzzRequest {
+	return FizzBuzzRequest{
+		Int1:  s.Int1,
+		Int2:  s.Int2,
+		Limit: s.Limit,
+		Str1:  s.Str1,
+		Str2:  s.Str2,
+		Rules: s.Rules,
+	}
+}
+
+// RequestCount holds a number of hits of a request parameters
+type RequestCount struct {
+	Request FizzBuzzRequest
+	Count   int64
+}
@@ -34,7 +34,20 @@ Feature: FizzBuzz API
     And the response payload should match json:
         """
         {
+            "type": "urn:fizzbuzz-api:problem:invalid_request",
+            "title": "Invalid request",
+            "status": 400,
+            "detail": "int1 must be greater than 1",
+            "instance": "/fizzbuzz",
             "code": "invalid_request",
-            "message": "int1 must be greater than 1"
+            "message": "int1 must be greater than 1",
+            "errors": [
+                {
+                    "field": "int1",
+                    "rule": "min",
+                    "param": "1",
+                    "message": "int1 must be greater than 1"
+                }
+            ]
         }
         """
\ No newline at end of file
@@ -22,6 +22,11 @@ Feature: Stats API
     And the response payload should match json:
         """
          {
+         "type": "urn:fizzbuzz-api:problem:no_requests_found",
+         "title": "No requests found",
+         "status": 404,
+         "detail": "No requests found in the statistics",
+         "instance": "/stats",
          "code": "no_requests_found",
          "message":"No requests found in the statistics"
          }
@@ -40,7 +40,7 @@ func (a *apiFeature) resetResponse(sc *godog.Scenario) {
 	a.resp = httptest.NewRecorder()
 
 	if strings.Contains(sc.Name, "reset stats") {
-		if err := a.repo.ResetStats(); err != nil {
+		if err := a.repo.ResetStats(context.Background()); err != nil {
 			panic(fmt.Sprintf("failed to reset stats: %v", err))
 		}
 	}
@@ -69,9 +69,10 @@ func (a *apiFeature) iSendrequestToRequestBody(ctx context.Context, method, rout
 	var resp any
 
 	if method == "POST" && route == "/fizzbuzz" {
-		err := a.router.GetHandler().HandleFizzBuzzRequest(app.NewContext(req, a.resp))
-		if err != nil {
-			return ctx, fmt.Errorf("error handling request: %w", err)
+		// The errors are answered by the error handler of the router, as when served by the router
+		echoCtx := app.NewContext(req, a.resp)
+		if err := a.router.GetHandler().HandleFizzBuzzRequest(echoCtx); err != nil {
+			echoCtx.Error(err)
 		}
 		var fizzBuzzResponse model.FizzBuzzResponse
 		a.body, _ = io.ReadAll(a.resp.Body)
@@ -79,9 +80,10 @@ func (a *apiFeature) iSendrequestToRequestBody(ctx context.Context, method, rout
 		resp = fizzBuzzResponse.Response
 	}
 	if method == "GET" && route == "/stats" {
-		err := a.router.GetHandler().HandleGetStats(app.NewContext(req, a.resp))
-		if err != nil {
-			return ctx, fmt.Errorf("error handling request: %w", err)
+		// The errors are answered by the error handler of the router, as when served by the router
+		echoCtx := app.NewContext(req, a.resp)
+		if err := a.router.GetHandler().HandleGetStats(echoCtx); err != nil {
+			echoCtx.Error(err)
 		}
 		var statsResponse model.StatsResponse
 		a.body, _ = io.ReadAll(a.resp.Body)
@@ -144,7 +146,7 @@ func InitializeScenario(ctx *godog.ScenarioContext) {
 	repo := repository.NewRedisStatsRepository(redisClient)
 
 	api := &apiFeature{
-		router: application.InitServices(context.Background(), repo, fizzbuzz.WithCache(func() adapters.CacheFizzbuzz {
+		router: application.InitServices(context.Background(), repo, nil, nil, nil, fizzbuzz.WithCache(func() adapters.CacheFizzbuzz {
 			if config.UseFizzbuzzCache {
 				return repository.NewCacheRedis(redisClient)
 			}